@@ -1,18 +1,34 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	_ "embed"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
+	"os/signal"
+	"runtime"
+	"slices"
 	"strings"
+	"syscall"
 	"text/template"
 
+	"github.com/vitorhrmiranda/jbdecoder/internal/config"
 	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+	"github.com/vitorhrmiranda/jbdecoder/internal/diff"
 	errs "github.com/vitorhrmiranda/jbdecoder/internal/errors"
+	"github.com/vitorhrmiranda/jbdecoder/internal/files"
+	"github.com/vitorhrmiranda/jbdecoder/internal/follow"
+	"github.com/vitorhrmiranda/jbdecoder/internal/learn"
+	"github.com/vitorhrmiranda/jbdecoder/internal/lsp"
+	"github.com/vitorhrmiranda/jbdecoder/internal/query"
+	"github.com/vitorhrmiranda/jbdecoder/internal/server"
+	"github.com/vitorhrmiranda/jbdecoder/internal/tui"
 )
 
 //go:embed help.md
@@ -23,6 +39,18 @@ const (
 	One
 )
 
+// serveCommand is the subcommand that starts the HTTP server
+const serveCommand = "serve"
+
+// lspCommand is the subcommand that starts the language server
+const lspCommand = "lsp"
+
+// diffCommand is the subcommand that compares two decoded documents
+const diffCommand = "diff"
+
+// learnCommand is the subcommand that infers codec rules from samples
+const learnCommand = "learn"
+
 // showUsage displays the help message
 func showUsage() {
 	tmpl, err := template.New("help").Parse(helpTemplate)
@@ -47,13 +75,31 @@ func isStdinEmpty() bool {
 	return stat.Mode()&os.ModeCharDevice != Zero
 }
 
+// readLimited reads r to EOF, failing once more than limit bytes were read.
+// A limit of zero reads without bound
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	if limit <= Zero {
+		return io.ReadAll(r)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, limit+One))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, errs.NewLimitError(errs.LimitInputSize, limit)
+	}
+
+	return data, nil
+}
+
 // readFromStdin reads and validates input from standard input
-func readFromStdin() ([]byte, error) {
+func readFromStdin(maxInput int64) ([]byte, error) {
 	if isStdinEmpty() {
 		return nil, errs.ErrNoInputProvided
 	}
 
-	data, err := io.ReadAll(os.Stdin)
+	data, err := readLimited(os.Stdin, maxInput)
 	if err != nil {
 		return nil, err
 	}
@@ -65,13 +111,19 @@ func readFromStdin() ([]byte, error) {
 	return data, nil
 }
 
+// isJSONArgument reports whether an argument is a JSON string rather than
+// a file name (starts with { or [)
+func isJSONArgument(arg string) bool {
+	arg = strings.TrimSpace(arg)
+	return strings.HasPrefix(arg, "{") || strings.HasPrefix(arg, "[")
+}
+
 // processArgument handles a single command-line argument (JSON string or file)
-func processArgument(arg string) ([]byte, error) {
+func processArgument(arg string, maxInput int64) ([]byte, error) {
 	arg = strings.TrimSpace(arg)
 
-	// Check if the argument looks like a JSON string (starts with { or [)
-	if strings.HasPrefix(arg, "{") || strings.HasPrefix(arg, "[") {
-		return []byte(arg), nil
+	if isJSONArgument(arg) {
+		return readLimited(strings.NewReader(arg), maxInput)
 	}
 
 	// Otherwise, treat it as a filename
@@ -81,26 +133,979 @@ func processArgument(arg string) ([]byte, error) {
 	}
 	defer file.Close()
 
-	return io.ReadAll(file)
+	return readLimited(file, maxInput)
+}
+
+// getJSONInput reads JSON input from various sources, up to maxInput bytes
+func getJSONInput(maxInput int64) ([]byte, error) {
+	args := flag.Args()
+
+	switch len(args) {
+	case Zero:
+		return readFromStdin(maxInput)
+	case One:
+		return processArgument(args[Zero], maxInput)
+	default:
+		return nil, errors.New("too many arguments provided")
+	}
+}
+
+// limitedReader fails with an input size LimitError once more than limit
+// bytes were read, so streamed input is bounded like buffered input
+type limitedReader struct {
+	r     io.Reader
+	read  int64
+	limit int64
+}
+
+// Read implements io.Reader, never returning bytes beyond the limit
+func (l *limitedReader) Read(p []byte) (int, error) {
+	remaining := l.limit - l.read
+	if remaining <= Zero {
+		// Probe for a byte past the limit to tell an exact fit from overflow
+		n, err := l.r.Read(p[:One])
+		if n > Zero {
+			return Zero, errs.NewLimitError(errs.LimitInputSize, l.limit)
+		}
+		return Zero, err
+	}
+
+	if int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	return n, err
 }
 
-// getJSONInput reads JSON input from various sources
-func getJSONInput() ([]byte, error) {
+// openInput opens the JSON input for streaming from the same sources as
+// getJSONInput, without reading it into memory
+func openInput(maxInput int64) (io.ReadCloser, error) {
 	args := flag.Args()
 
+	var input io.ReadCloser
 	switch len(args) {
 	case Zero:
-		return readFromStdin()
+		if isStdinEmpty() {
+			return nil, errs.ErrNoInputProvided
+		}
+		input = io.NopCloser(os.Stdin)
 	case One:
-		return processArgument(args[Zero])
+		arg := strings.TrimSpace(args[Zero])
+		if isJSONArgument(arg) {
+			input = io.NopCloser(strings.NewReader(arg))
+			break
+		}
+
+		file, err := os.Open(arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file '%s': %w", arg, err)
+		}
+		input = file
 	default:
 		return nil, errors.New("too many arguments provided")
 	}
+
+	if maxInput <= Zero {
+		return input, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{&limitedReader{r: input, limit: maxInput}, input}, nil
+}
+
+// streamInput decodes the input token by token, writing output as it goes.
+// Diagnostics are printed as soon as they are found
+func streamInput(opts decoder.Options, maxInput int64) {
+	input, err := openInput(maxInput)
+	if err != nil {
+		var argErr errs.ArgumentError
+		if errors.As(err, &argErr) {
+			showUsage()
+			return
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+		os.Exit(One)
+	}
+	defer input.Close()
+
+	opts.OnDiagnostic = func(diagnostic decoder.Diagnostic) {
+		_, _ = fmt.Fprintln(os.Stderr, diagnostic.Error())
+	}
+	d, err := decoder.New(opts)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(One)
+	}
+
+	err = d.Stream(input, os.Stdout)
+	if errors.Is(err, decoder.ErrEmptyStream) {
+		showUsage()
+		return
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error decoding JSON: %v\n", err)
+		os.Exit(One)
+	}
+}
+
+// fileOptions controls how multiple input files are decoded and written
+type fileOptions struct {
+	maxInput int64
+	jobs     int
+	annotate bool
+	pretty   bool
+	ndjson   bool
+	inPlace  bool
+	backup   bool
+}
+
+// fileResult is the outcome of decoding one input file, with its output
+// ready to be printed
+type fileResult struct {
+	path        string
+	output      []byte
+	diagnostics []decoder.Diagnostic
+	changed     bool
+	err         error
+}
+
+// fileRecord is printed for each file with --ndjson
+type fileRecord struct {
+	File        string               `json:"file"`
+	Result      any                  `json:"result,omitempty"`
+	Annotations []decoder.Annotation `json:"annotations,omitempty"`
+	Diagnostics []decoder.Diagnostic `json:"diagnostics,omitempty"`
+	Error       string               `json:"error,omitempty"`
+}
+
+// isMultiInput reports whether the arguments call for processing files one
+// by one rather than reading a single document
+func isMultiInput(args []string, opts fileOptions) bool {
+	switch {
+	case opts.inPlace || opts.ndjson || len(args) > One:
+		return true
+	case len(args) == One:
+		return !isJSONArgument(args[Zero]) && files.IsPattern(args[Zero])
+	default:
+		return false
+	}
+}
+
+// decodeFile reads, parses and decodes a single input file, rewriting it
+// with --in-place. Numbers are kept as written, and the file counts as
+// changed when decoding or redaction changed any value in it
+func decodeFile(d *decoder.Decoder, path string, opts fileOptions) fileResult {
+	file, err := os.Open(path)
+	if err != nil {
+		return fileResult{path: path, err: err}
+	}
+	defer file.Close()
+
+	data, err := readLimited(file, opts.maxInput)
+	if err != nil {
+		return fileResult{path: path, err: err}
+	}
+
+	value, err := parseExact(data)
+	if err != nil {
+		return fileResult{path: path, err: fmt.Errorf("parsing JSON: %w", err)}
+	}
+
+	// The decoder updates the value in place, so it is marshalled first to
+	// tell whether anything changed
+	original, err := json.Marshal(value)
+	if err != nil {
+		return fileResult{path: path, err: err}
+	}
+
+	result, err := d.Decode(value)
+	if err != nil {
+		return fileResult{path: path, err: err}
+	}
+
+	output, err := json.Marshal(result.Value)
+	if err != nil {
+		return fileResult{path: path, err: err}
+	}
+
+	decoded := fileResult{
+		path:        path,
+		diagnostics: result.Diagnostics,
+		changed:     !bytes.Equal(original, output),
+	}
+	var printed any = result.Value
+	switch {
+	case opts.ndjson:
+		record := fileRecord{File: path, Result: result.Value}
+		if opts.annotate {
+			record.Annotations, record.Diagnostics = result.Annotations, result.Diagnostics
+		}
+		printed = record
+	case opts.annotate:
+		printed = annotatedOutput{
+			Result:      result.Value,
+			Annotations: result.Annotations,
+			Diagnostics: result.Diagnostics,
+		}
+	}
+
+	// NDJSON records stay on one line even with --pretty
+	if opts.pretty && !opts.ndjson {
+		decoded.output, err = json.MarshalIndent(printed, "", "  ")
+	} else {
+		decoded.output, err = json.Marshal(printed)
+	}
+	if err == nil && opts.inPlace && decoded.changed {
+		err = files.Rewrite(path, append(decoded.output, '\n'), opts.backup)
+	}
+	if err != nil {
+		return fileResult{path: path, err: err}
+	}
+	return decoded
+}
+
+// parseExact parses a single JSON document, keeping numbers as
+// json.Number so that they are written back exactly as in the input
+func parseExact(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("invalid character after top-level value")
+	}
+	return value, nil
+}
+
+// decodeFiles decodes files concurrently with up to opts.jobs workers and
+// hands the results to emit in input order as soon as they are available.
+// A file is only started once fewer than opts.jobs results are pending, so
+// that a slow file does not leave every later result held in memory
+func decodeFiles(d *decoder.Decoder, paths []string, opts fileOptions, emit func(fileResult)) {
+	pending := make(chan chan fileResult, max(opts.jobs, One)-One)
+
+	go func() {
+		defer close(pending)
+		for _, path := range paths {
+			result := make(chan fileResult, One)
+			pending <- result
+			go func() { result <- decodeFile(d, path, opts) }()
+		}
+	}()
+
+	for result := range pending {
+		emit(<-result)
+	}
+}
+
+// processFiles decodes every file the arguments stand for, printing each
+// result under a header, as NDJSON records or rewriting the file in place,
+// followed by a summary on stderr. It reports whether all files succeeded
+func processFiles(d *decoder.Decoder, args []string, opts fileOptions) bool {
+	paths, err := files.Expand(args)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+		return false
+	}
+
+	changed, failed := Zero, Zero
+	decodeFiles(d, paths, opts, func(result fileResult) {
+		output := result.output
+		if result.err != nil {
+			failed++
+			_, _ = fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", result.path, result.err)
+			if opts.ndjson {
+				output, _ = json.Marshal(fileRecord{File: result.path, Error: result.err.Error()})
+				_, _ = fmt.Println(string(output))
+			}
+			return
+		}
+
+		if result.changed {
+			changed++
+		}
+		switch {
+		case opts.inPlace:
+			if result.changed {
+				_, _ = fmt.Fprintf(os.Stderr, "Rewrote %s\n", result.path)
+			}
+		case opts.ndjson:
+			_, _ = fmt.Println(string(output))
+		default:
+			_, _ = fmt.Printf("==> %s <==\n%s\n", result.path, output)
+		}
+
+		for _, diagnostic := range result.diagnostics {
+			_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", result.path, diagnostic.Error())
+		}
+	})
+
+	_, _ = fmt.Fprintf(os.Stderr, "Processed %d files: %d changed, %d unchanged, %d failed\n",
+		len(paths), changed, len(paths)-changed-failed, failed)
+	return failed == Zero
+}
+
+// lineOptions controls how lines of a followed file are decoded
+type lineOptions struct {
+	text     bool
+	logfmt   bool
+	annotate bool
+	markers  decoder.Markers
+}
+
+// decodeLine decodes one line of a followed file. JSON lines are decoded
+// like a document, while other lines and --text or --logfmt input are
+// decoded as text
+func decodeLine(d *decoder.Decoder, line []byte, opts lineOptions) (string, []decoder.Diagnostic, error) {
+	var data any
+	if opts.text || opts.logfmt || json.Unmarshal(line, &data) != nil {
+		output, err := decodeText(d, line, opts.logfmt, opts.markers)
+		return output, nil, err
+	}
+
+	result, err := d.Decode(data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var processedData any = result.Value
+	if opts.annotate {
+		processedData = annotatedOutput{
+			Result:      result.Value,
+			Annotations: result.Annotations,
+			Diagnostics: result.Diagnostics,
+		}
+	}
+
+	output, err := json.Marshal(processedData)
+	return string(output), result.Diagnostics, err
+}
+
+// followFile decodes lines appended to a file until interrupted, printing
+// each one as soon as it is complete. Lines that fail to decode are
+// reported without stopping
+func followFile(d *decoder.Decoder, path string, fromStart bool, opts lineOptions) error {
+	follower, err := follow.Open(path, follow.Options{FromStart: fromStart})
+	if err != nil {
+		return err
+	}
+	defer follower.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return follower.Lines(ctx, func(line []byte) error {
+		if len(bytes.TrimSpace(line)) == Zero {
+			return nil
+		}
+
+		output, diagnostics, err := decodeLine(d, line, opts)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error decoding line: %v\n", err)
+			return nil
+		}
+
+		_, _ = fmt.Println(output)
+		for _, diagnostic := range diagnostics {
+			_, _ = fmt.Fprintln(os.Stderr, diagnostic.Error())
+		}
+		return nil
+	})
+}
+
+// runTUI decodes the JSON input and shows it in the interactive view. The
+// input is decoded into a copy, so that the view can show the original of
+// every decoded value
+func runTUI(opts decoder.Options, maxInput int64) error {
+	jsonData, err := getJSONInput(maxInput)
+	if err != nil {
+		var argErr errs.ArgumentError
+		if errors.As(err, &argErr) {
+			showUsage()
+			return nil
+		}
+		return fmt.Errorf("reading input: %w", err)
+	}
+
+	// The view holds one document, so NDJSON records are rejected instead of
+	// showing the first one only
+	dec := json.NewDecoder(bytes.NewReader(jsonData))
+	var data, next any
+	if err := dec.Decode(&data); err != nil {
+		return fmt.Errorf("parsing JSON: %w", err)
+	}
+	if err := dec.Decode(&next); err == nil {
+		return errors.New("--tui shows a single JSON document, but the input holds several; decode NDJSON with --ndjson instead")
+	} else if !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parsing JSON: %w", err)
+	}
+
+	opts.InPlace = false
+	d, err := decoder.New(opts)
+	if err != nil {
+		return err
+	}
+	result, err := d.Decode(data)
+	if err != nil {
+		return fmt.Errorf("decoding JSON: %w", err)
+	}
+
+	return tui.RunTerminal(tui.Document{Original: data, Result: result})
+}
+
+// runServe implements the serve command, exposing the decoder over HTTP
+// until interrupted
+func runServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flags.Usage = showUsage
+	addr := flags.String("addr", "127.0.0.1:8080", "Address to listen on")
+	maxBody := flags.Int64("max-body", server.DefaultMaxBodySize, "Maximum request body size in bytes")
+	timeout := flags.Duration("timeout", server.DefaultTimeout, "Maximum time to handle a request")
+	shutdownTimeout := flags.Duration("shutdown-timeout", server.DefaultShutdownTimeout, "Time in-flight requests get to finish on shutdown")
+	charset := flags.String("charset", "", "Force the charset of decoded bytes")
+	maxDepth := flags.Int("max-depth", decoder.DefaultMaxDepth, "Maximum number of nested encoding layers")
+	maxField := flags.Int64("max-field", Zero, "Maximum decoded size of a single value in bytes (0 for no limit)")
+	maxOutput := flags.Int64("max-output", Zero, "Maximum total decoded size in bytes (0 for no limit)")
+	truncate := flags.Bool("truncate", false, "Leave values over a limit undecoded instead of failing")
+	accessLog := flags.Bool("access-log", true, "Write an access log entry for every request to stderr")
+	logFormat := flags.String("log-format", "json", "Access log format: json or text")
+	cfg := addConfigFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := cfg.apply(flags); err != nil {
+		return err
+	}
+
+	var logger *slog.Logger
+	if *accessLog {
+		switch *logFormat {
+		case "json":
+			logger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+		case "text":
+			logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+		default:
+			return fmt.Errorf("unknown log format %q", *logFormat)
+		}
+	}
+
+	opts := decoder.Options{
+		Charset:       *charset,
+		MaxDepth:      *maxDepth,
+		MaxFieldSize:  *maxField,
+		MaxOutputSize: *maxOutput,
+		Truncate:      *truncate,
+		InPlace:       true,
+	}
+	cfg.options(&opts)
+
+	srv := server.New(server.Options{
+		Decoder:         opts,
+		MaxBodySize:     *maxBody,
+		Timeout:         *timeout,
+		ShutdownTimeout: *shutdownTimeout,
+		Logger:          logger,
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	_, _ = fmt.Fprintf(os.Stderr, "Listening on http://%s\n", *addr)
+	return srv.ListenAndServe(ctx, *addr)
+}
+
+// runLSP implements the lsp command, serving the Language Server Protocol
+// on stdin and stdout until the editor exits
+func runLSP(args []string) error {
+	flags := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	flags.Usage = showUsage
+	charset := flags.String("charset", "", "Force the charset of decoded bytes")
+	maxDepth := flags.Int("max-depth", decoder.DefaultMaxDepth, "Maximum number of nested encoding layers")
+	maxField := flags.Int64("max-field", Zero, "Maximum decoded size of a single value in bytes (0 for no limit)")
+	maxOutput := flags.Int64("max-output", Zero, "Maximum total decoded size of a value in bytes (0 for no limit)")
+	truncate := flags.Bool("truncate", false, "Leave values over a limit undecoded instead of failing")
+	cfg := addConfigFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := cfg.apply(flags); err != nil {
+		return err
+	}
+
+	opts := decoder.Options{
+		Charset:       *charset,
+		MaxDepth:      *maxDepth,
+		MaxFieldSize:  *maxField,
+		MaxOutputSize: *maxOutput,
+		Truncate:      *truncate,
+	}
+	cfg.options(&opts)
+	srv, err := lsp.New(opts)
+	if err != nil {
+		return err
+	}
+	return srv.Serve(os.Stdin, os.Stdout)
+}
+
+// writePretty writes value as indented JSON with object members sorted by
+// name and without HTML escaping, a stable form for line-based diffs
+func writePretty(w io.Writer, value any) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(value)
+}
+
+// runTextconv prints the decoded input in the stable form of writePretty,
+// for git to diff decoded content. Input that is not JSON is printed as it
+// is, so that git can still diff it
+func runTextconv(opts decoder.Options, maxInput int64) error {
+	jsonData, err := getJSONInput(maxInput)
+	if err != nil {
+		var argErr errs.ArgumentError
+		if errors.As(err, &argErr) {
+			showUsage()
+			return nil
+		}
+		return fmt.Errorf("reading input: %w", err)
+	}
+
+	var data any
+	if err := json.Unmarshal(jsonData, &data); err != nil {
+		_, err = os.Stdout.Write(jsonData)
+		return err
+	}
+
+	opts.InPlace = true
+	d, err := decoder.New(opts)
+	if err != nil {
+		return err
+	}
+	result, err := d.Decode(data)
+	if err != nil {
+		return fmt.Errorf("decoding JSON: %w", err)
+	}
+	return writePretty(os.Stdout, result.Value)
+}
+
+// decodeDocument reads and decodes a diff input, a file or a JSON string.
+// Empty inputs, such as the /dev/null git passes for created and deleted
+// files, stand for a missing document and yield nil
+func decodeDocument(d *decoder.Decoder, arg string, maxInput int64) (*decoder.Result, error) {
+	data, err := processArgument(arg, maxInput)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) == Zero {
+		return nil, nil
+	}
+
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", arg, err)
+	}
+	result, err := d.Decode(value)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", arg, err)
+	}
+	return &result, nil
+}
+
+// configFlags are the flags selecting a configuration, along with the
+// decoder options only a configuration could set otherwise. They are shared
+// by the default command and the diff, serve, lsp and learn commands
+type configFlags struct {
+	path      *string
+	profile   *string
+	codecs    *string
+	minLength *int
+	include   []string
+	exclude   []string
+	redact    []string
+	rules     []decoder.Rule
+}
+
+// addConfigFlags registers the configFlags on flags
+func addConfigFlags(flags *flag.FlagSet) *configFlags {
+	c := &configFlags{
+		path:      flags.String("config", "", "Read settings from this file instead of .jbdecoder.yaml"),
+		profile:   flags.String("profile", "", "Apply a named profile of the configuration"),
+		codecs:    flags.String("codecs", "", "Comma-separated codecs to try (default all)"),
+		minLength: flags.Int("min-length", decoder.DefaultMinLength, "Length below which strings are not decoded as Base64"),
+	}
+	repeatable := func(name, usage string, dst *[]string) {
+		flags.Func(name, usage, func(s string) error {
+			*dst = append(*dst, s)
+			return nil
+		})
+	}
+	repeatable("include", "Only decode at or below paths matching a JSONPath pattern (repeatable)", &c.include)
+	repeatable("exclude", "Leave paths matching a JSONPath pattern undecoded (repeatable)", &c.exclude)
+	repeatable("redact", "Replace values at paths matching a JSONPath pattern with "+decoder.Redacted+" (repeatable)", &c.redact)
+	flags.Func("rule", "Decode values at paths matching PATH with exactly the codecs of PATH=CODECS (repeatable)", func(s string) error {
+		path, codecs, err := config.SplitRule(s)
+		if err != nil {
+			return err
+		}
+		c.rules = append(c.rules, decoder.Rule{Path: path, Codecs: codecs})
+		return nil
+	})
+	return c
+}
+
+// apply loads the configuration and sets the flags that were not given on
+// the command line from it, so that flags win over environment variables,
+// which win over the profile and the top-level settings. Settings the
+// command has no flag for are reported on stderr and ignored
+func (c *configFlags) apply(flags *flag.FlagSet) error {
+	settings, err := config.Load(config.Options{Path: *c.path, Profile: *c.profile})
+	if err != nil {
+		return fmt.Errorf("loading configuration: %w", err)
+	}
+
+	visited := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { visited[f.Name] = true })
+	var ignored []string
+	for name, values := range settings.Flags() {
+		if visited[name] {
+			continue
+		}
+		if flags.Lookup(name) == nil {
+			ignored = append(ignored, strings.ReplaceAll(name, "-", "_"))
+			continue
+		}
+		for _, value := range values {
+			if err := flags.Set(name, value); err != nil {
+				return fmt.Errorf("configuration setting %s: %w", name, err)
+			}
+		}
+	}
+	if len(ignored) > Zero {
+		slices.Sort(ignored)
+		_, _ = fmt.Fprintf(os.Stderr, "Warning: %s ignores the configuration settings %s\n", flags.Name(), strings.Join(ignored, ", "))
+	}
+	return nil
+}
+
+// options sets the decoder options held by the configFlags in opts
+func (c *configFlags) options(opts *decoder.Options) {
+	if *c.codecs != "" {
+		opts.Codecs = strings.Split(*c.codecs, ",")
+	}
+	opts.MinLength = *c.minLength
+	opts.Include = c.include
+	opts.Exclude = c.exclude
+	opts.Redact = c.redact
+	opts.Rules = c.rules
+}
+
+// runDiff implements the diff command, printing the changes between the
+// decoded content of two documents. It reports whether there were any, or
+// always false when invoked as a git diff driver, since git takes a failing
+// exit status for a broken driver
+func runDiff(args []string) (bool, error) {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	flags.Usage = showUsage
+	charset := flags.String("charset", "", "Force the charset of decoded bytes")
+	maxDepth := flags.Int("max-depth", decoder.DefaultMaxDepth, "Maximum number of nested encoding layers")
+	maxInput := flags.Int64("max-input", Zero, "Maximum input size in bytes (0 for no limit)")
+	maxField := flags.Int64("max-field", Zero, "Maximum decoded size of a single value in bytes (0 for no limit)")
+	maxOutput := flags.Int64("max-output", Zero, "Maximum total decoded size in bytes (0 for no limit)")
+	truncate := flags.Bool("truncate", false, "Leave values over a limit undecoded instead of failing")
+	format := flags.String("format", "text", "Output format: text, json or patch")
+	var ignore []decoder.Pattern
+	flags.Func("ignore", "Leave out changes at or below paths matching a JSONPath pattern (repeatable)", func(s string) error {
+		p, err := decoder.ParsePattern(s)
+		if err != nil {
+			return err
+		}
+		ignore = append(ignore, p)
+		return nil
+	})
+	cfg := addConfigFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return false, err
+	}
+	if err := cfg.apply(flags); err != nil {
+		return false, err
+	}
+
+	write := diff.WriteText
+	switch *format {
+	case "text":
+	case "json":
+		write = diff.WriteJSON
+	case "patch":
+		write = diff.WritePatch
+	default:
+		return false, fmt.Errorf("unknown diff format %q", *format)
+	}
+
+	// git runs diff drivers with the path, then the file, hex and mode of
+	// each side, and for renames the new path and rename details
+	inputs := flags.Args()
+	var oldFile, newFile, oldLabel, newLabel string
+	driver := false
+	switch len(inputs) {
+	case 2:
+		oldFile, newFile = inputs[0], inputs[1]
+		oldLabel, newLabel = oldFile, newFile
+	case 7, 9:
+		driver = true
+		oldFile, newFile = inputs[1], inputs[4]
+		oldLabel, newLabel = "a/"+inputs[0], "b/"+inputs[0]
+		if len(inputs) == 9 {
+			newLabel = "b/" + inputs[7]
+		}
+	default:
+		return false, errors.New("diff needs two inputs, or the arguments git passes to a diff driver")
+	}
+
+	opts := decoder.Options{
+		Charset:       *charset,
+		MaxDepth:      *maxDepth,
+		MaxFieldSize:  *maxField,
+		MaxOutputSize: *maxOutput,
+		Truncate:      *truncate,
+		InPlace:       true,
+	}
+	cfg.options(&opts)
+	d, err := decoder.New(opts)
+	if err != nil {
+		return false, err
+	}
+	old, err := decodeDocument(d, oldFile, *maxInput)
+	if err != nil {
+		return false, err
+	}
+	new, err := decodeDocument(d, newFile, *maxInput)
+	if err != nil {
+		return false, err
+	}
+
+	changes := diff.Compare(old, new, diff.Options{Ignore: ignore})
+	if *format == "text" {
+		// Like diff(1), text output is empty when nothing changed and
+		// starts with the labels of both sides otherwise
+		if len(changes) == Zero {
+			return false, nil
+		}
+		if driver && old == nil {
+			oldLabel = os.DevNull
+		}
+		if driver && new == nil {
+			newLabel = os.DevNull
+		}
+		if _, err := fmt.Printf("--- %s\n+++ %s\n", oldLabel, newLabel); err != nil {
+			return false, err
+		}
+	}
+	if err := write(os.Stdout, changes); err != nil {
+		return false, err
+	}
+	return len(changes) > Zero && !driver, nil
+}
+
+// runLearn implements the learn command, decoding sample documents by
+// guessing and writing the codec rules inferred from them as a profile
+func runLearn(args []string) error {
+	flags := flag.NewFlagSet("learn", flag.ContinueOnError)
+	flags.Usage = showUsage
+	name := flags.String("name", "learned", "Name of the written profile")
+	output := flags.String("output", "", "Write the profile to this file instead of stdout")
+	minSamples := flags.Int("min-samples", learn.DefaultMinSamples, "Number of values a path needs for a rule")
+	minRatio := flags.Float64("min-ratio", 1, "Share of the values at a path that must decode with the same codecs")
+	charset := flags.String("charset", "", "Force the charset of decoded bytes")
+	maxDepth := flags.Int("max-depth", decoder.DefaultMaxDepth, "Maximum number of nested encoding layers")
+	maxInput := flags.Int64("max-input", Zero, "Maximum size of each input file in bytes (0 for no limit)")
+	maxField := flags.Int64("max-field", Zero, "Maximum decoded size of a single value in bytes (0 for no limit)")
+	maxOutput := flags.Int64("max-output", Zero, "Maximum total decoded size in bytes (0 for no limit)")
+	cfg := addConfigFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := cfg.apply(flags); err != nil {
+		return err
+	}
+
+	if flags.NArg() == Zero {
+		return errors.New("learn needs sample files, directories or globs")
+	}
+	paths, err := files.Expand(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	opts := decoder.Options{
+		Charset:       *charset,
+		MaxDepth:      *maxDepth,
+		MaxFieldSize:  *maxField,
+		MaxOutputSize: *maxOutput,
+		Truncate:      true,
+		InPlace:       true,
+	}
+	cfg.options(&opts)
+	d, err := decoder.New(opts)
+	if err != nil {
+		return err
+	}
+
+	// Samples may hold several documents each, such as NDJSON captures
+	l := learn.New(learn.Options{MinSamples: *minSamples, MinRatio: *minRatio})
+	for _, path := range paths {
+		data, err := processArgument(path, *maxInput)
+		if err != nil {
+			return err
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		for {
+			var value any
+			if err := dec.Decode(&value); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return fmt.Errorf("parsing %s: %w", path, err)
+			}
+			result, err := d.Decode(value)
+			if err != nil {
+				return fmt.Errorf("decoding %s: %w", path, err)
+			}
+			if err := l.Add(result); err != nil {
+				return err
+			}
+		}
+	}
+
+	findings := l.Findings()
+	var b bytes.Buffer
+	if err := learn.WriteProfile(&b, *name, l.Documents(), findings); err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(b.Bytes())
+	} else {
+		err = os.WriteFile(*output, b.Bytes(), 0o644)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(os.Stderr, "Learned from %d documents in %d files: %d rules\n", l.Documents(), len(paths), len(findings))
+	return nil
+}
+
+// annotatedOutput is printed instead of the bare result with --annotate
+type annotatedOutput struct {
+	Result      any                  `json:"result"`
+	Annotations []decoder.Annotation `json:"annotations"`
+	Diagnostics []decoder.Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// decodeText decodes Base64 embedded in free-form text, either scanning each
+// line as plain text or parsing it as logfmt and emitting one JSON object
+// per line
+func decodeText(d *decoder.Decoder, input []byte, logfmt bool, markers decoder.Markers) (string, error) {
+	if !logfmt {
+		return d.DecodeText(strings.TrimRight(string(input), "\n"), markers)
+	}
+
+	lines := strings.Split(strings.TrimRight(string(input), "\n"), "\n")
+	output := make([]string, Zero, len(lines))
+
+	for _, line := range lines {
+		fields, err := d.DecodeLogfmt(line, markers)
+		if errors.Is(err, decoder.ErrInvalidLogfmt) {
+			// Lines that are not logfmt (e.g. stack traces) are scanned as text
+			text, err := d.DecodeText(line, markers)
+			if err != nil {
+				return "", err
+			}
+			output = append(output, text)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		encoded, err := json.Marshal(fields)
+		if err != nil {
+			return "", err
+		}
+		output = append(output, string(encoded))
+	}
+
+	return strings.Join(output, "\n"), nil
 }
 
 func main() {
+	if args := os.Args[One:]; len(args) > Zero && args[Zero] == serveCommand {
+		if err := runServe(args[One:]); err != nil && !errors.Is(err, flag.ErrHelp) {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(One)
+		}
+		return
+	}
+	if args := os.Args[One:]; len(args) > Zero && args[Zero] == lspCommand {
+		if err := runLSP(args[One:]); err != nil && !errors.Is(err, flag.ErrHelp) {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(One)
+		}
+		return
+	}
+	if args := os.Args[One:]; len(args) > Zero && args[Zero] == diffCommand {
+		// Like diff(1), exit with 1 when the documents differ and 2 on errors
+		differ, err := runDiff(args[One:])
+		if err != nil && !errors.Is(err, flag.ErrHelp) {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		if differ {
+			os.Exit(One)
+		}
+		return
+	}
+
+	if args := os.Args[One:]; len(args) > Zero && args[Zero] == learnCommand {
+		if err := runLearn(args[One:]); err != nil && !errors.Is(err, flag.ErrHelp) {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(One)
+		}
+		return
+	}
+
 	help := flag.Bool("h", false, "Show help message")
 	flag.BoolVar(help, "help", false, "Show help message")
+	text := flag.Bool("text", false, "Decode Base64 embedded in free-form text")
+	logfmt := flag.Bool("logfmt", false, "Parse input lines as logfmt")
+	markerOpen := flag.String("marker-open", decoder.DefaultMarkers.Open, "Marker placed before decoded text")
+	markerClose := flag.String("marker-close", decoder.DefaultMarkers.Close, "Marker placed after decoded text")
+	charset := flag.String("charset", "", "Force the charset of decoded bytes")
+	annotate := flag.Bool("annotate", false, "Include annotations describing decoded values")
+	pretty := flag.Bool("pretty", false, "Indent the JSON output")
+	maxDepth := flag.Int("max-depth", decoder.DefaultMaxDepth, "Maximum number of nested encoding layers")
+	maxInput := flag.Int64("max-input", Zero, "Maximum input size in bytes (0 for no limit)")
+	maxField := flag.Int64("max-field", Zero, "Maximum decoded size of a single value in bytes (0 for no limit)")
+	maxOutput := flag.Int64("max-output", Zero, "Maximum total decoded size in bytes (0 for no limit)")
+	truncate := flag.Bool("truncate", false, "Leave values over a limit undecoded instead of failing")
+	explain := flag.Bool("explain", false, "Explain why values were not decoded")
+	jobs := flag.Int("jobs", runtime.NumCPU(), "Number of goroutines decoding large arrays")
+	blobThreshold := flag.Int64("blob-threshold", Zero, "Length above which Base64 values are decoded to a blob sink (0 disables)")
+	blobDir := flag.String("blob-dir", "", "Directory where blobs are stored, named after their SHA-256")
+	ndjson := flag.Bool("ndjson", false, "Print one JSON record per input file")
+	inPlace := flag.Bool("in-place", false, "Rewrite input files with their decoded content")
+	backup := flag.Bool("backup", false, "Keep a .bak copy of files rewritten with --in-place")
+	followFlag := flag.Bool("follow", false, "Decode JSON lines appended to a file until interrupted")
+	fromStart := flag.Bool("from-start", false, "With --follow, decode the lines already in the file first")
+	stream := flag.Bool("stream", false, "Decode the input token by token with bounded memory")
+	tuiFlag := flag.Bool("tui", false, "Explore the decoded document in an interactive view")
+	textconv := flag.Bool("textconv", false, "Print the decoded input as sorted, indented JSON for git diff")
+	expr := flag.String("q", "", "Print the outputs of a jq-like query over the decoded document")
+	flag.StringVar(expr, "query", "", "Print the outputs of a jq-like query over the decoded document")
+	cfg := addConfigFlags(flag.CommandLine)
 	flag.Usage = showUsage
 	flag.Parse()
 
@@ -108,8 +1113,139 @@ func main() {
 		showUsage()
 		return
 	}
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	if err := cfg.apply(flag.CommandLine); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(One)
+	}
+	// Annotations of the configuration only change the default output, so
+	// they give way to the modes that do not support them
+	if !given["annotate"] && (*expr != "" || *textconv || *stream || *inPlace) {
+		*annotate = false
+	}
+
+	opts := decoder.Options{
+		Charset:       *charset,
+		MaxDepth:      *maxDepth,
+		MaxFieldSize:  *maxField,
+		MaxOutputSize: *maxOutput,
+		Truncate:      *truncate,
+		Explain:       *explain,
+		Jobs:          *jobs,
+		BlobThreshold: *blobThreshold,
+		BlobDir:       *blobDir,
+	}
+	cfg.options(&opts)
+
+	var q *query.Query
+	if *expr != "" {
+		if *followFlag || *tuiFlag || *textconv || *stream || *text || *logfmt || *annotate || *inPlace || *ndjson {
+			_, _ = fmt.Fprintln(os.Stderr, "Error: --query cannot be combined with --follow, --tui, --textconv, --stream, --text, --logfmt, --annotate, --in-place or --ndjson")
+			os.Exit(One)
+		}
+
+		var err error
+		if q, err = query.Compile(*expr); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(One)
+		}
+	}
+
+	if *followFlag {
+		if len(flag.Args()) != One || *stream || *inPlace || *ndjson {
+			_, _ = fmt.Fprintln(os.Stderr, "Error: --follow needs a single file and cannot be combined with --stream, --in-place or --ndjson")
+			os.Exit(One)
+		}
+
+		d, err := decoder.New(opts)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(One)
+		}
+		err = followFile(d, flag.Arg(Zero), *fromStart, lineOptions{
+			text:     *text,
+			logfmt:   *logfmt,
+			annotate: *annotate,
+			markers:  decoder.Markers{Open: *markerOpen, Close: *markerClose},
+		})
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+			os.Exit(One)
+		}
+		return
+	}
 
-	jsonData, err := getJSONInput()
+	if *tuiFlag {
+		if len(flag.Args()) > One || *stream || *text || *logfmt || *inPlace || *ndjson {
+			_, _ = fmt.Fprintln(os.Stderr, "Error: --tui needs a single JSON input and cannot be combined with --stream, --text, --logfmt, --in-place or --ndjson")
+			os.Exit(One)
+		}
+		if err := runTUI(opts, *maxInput); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(One)
+		}
+		return
+	}
+
+	if *textconv {
+		if len(flag.Args()) > One || *stream || *text || *logfmt || *inPlace || *ndjson || *annotate {
+			_, _ = fmt.Fprintln(os.Stderr, "Error: --textconv needs a single input and cannot be combined with --stream, --text, --logfmt, --in-place, --ndjson or --annotate")
+			os.Exit(One)
+		}
+		if err := runTextconv(opts, *maxInput); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(One)
+		}
+		return
+	}
+
+	fileOpts := fileOptions{
+		maxInput: *maxInput,
+		jobs:     *jobs,
+		annotate: *annotate,
+		pretty:   *pretty,
+		ndjson:   *ndjson,
+		inPlace:  *inPlace,
+		backup:   *backup,
+	}
+	if isMultiInput(flag.Args(), fileOpts) {
+		if q != nil {
+			_, _ = fmt.Fprintln(os.Stderr, "Error: --query needs a single JSON input")
+			os.Exit(One)
+		}
+		if *stream || *text || *logfmt {
+			_, _ = fmt.Fprintln(os.Stderr, "Error: multiple inputs cannot be combined with --stream, --text or --logfmt")
+			os.Exit(One)
+		}
+		if *inPlace && *annotate {
+			_, _ = fmt.Fprintln(os.Stderr, "Error: --in-place cannot be combined with --annotate")
+			os.Exit(One)
+		}
+
+		// Decoded documents are discarded once written
+		opts.InPlace = true
+		d, err := decoder.New(opts)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(One)
+		}
+		if !processFiles(d, flag.Args(), fileOpts) {
+			os.Exit(One)
+		}
+		return
+	}
+
+	if *stream {
+		if *text || *logfmt || *annotate {
+			_, _ = fmt.Fprintln(os.Stderr, "Error: --stream cannot be combined with --text, --logfmt or --annotate")
+			os.Exit(One)
+		}
+		streamInput(opts, *maxInput)
+		return
+	}
+
+	jsonData, err := getJSONInput(*maxInput)
 	if err != nil {
 		// Check if error is an ArgumentError - show help instead of error
 		var argErr errs.ArgumentError
@@ -121,19 +1257,79 @@ func main() {
 		os.Exit(One)
 	}
 
+	// The parsed input is not needed afterwards, so decode it in place
+	opts.InPlace = true
+	d, err := decoder.New(opts)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(One)
+	}
+
+	if *text || *logfmt {
+		markers := decoder.Markers{Open: *markerOpen, Close: *markerClose}
+		output, err := decodeText(d, jsonData, *logfmt, markers)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error generating output JSON: %v\n", err)
+			os.Exit(One)
+		}
+		_, _ = fmt.Println(output)
+		return
+	}
+
 	var data any
 	if parseErr := json.Unmarshal(jsonData, &data); parseErr != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "Error parsing JSON: %v\n", parseErr)
 		os.Exit(One)
 	}
 
-	processedData := decoder.DecodeBase64Fields(data)
+	result, err := d.Decode(data)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error decoding JSON: %v\n", err)
+		os.Exit(One)
+	}
 
-	output, err := json.Marshal(processedData)
+	if q != nil {
+		outputs, err := q.Run(result)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error running query: %v\n", err)
+			os.Exit(One)
+		}
+		for _, output := range outputs {
+			encoded, err := json.Marshal(output)
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Error generating output JSON: %v\n", err)
+				os.Exit(One)
+			}
+			_, _ = fmt.Println(string(encoded))
+		}
+		for _, diagnostic := range result.Diagnostics {
+			_, _ = fmt.Fprintln(os.Stderr, diagnostic.Error())
+		}
+		return
+	}
+
+	var processedData any = result.Value
+	if *annotate {
+		processedData = annotatedOutput{
+			Result:      result.Value,
+			Annotations: result.Annotations,
+			Diagnostics: result.Diagnostics,
+		}
+	}
+
+	marshal := json.Marshal
+	if *pretty {
+		marshal = func(v any) ([]byte, error) { return json.MarshalIndent(v, "", "  ") }
+	}
+	output, err := marshal(processedData)
 	if err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "Error generating output JSON: %v\n", err)
 		os.Exit(One)
 	}
 
 	_, _ = fmt.Println(string(output))
+
+	for _, diagnostic := range result.Diagnostics {
+		_, _ = fmt.Fprintln(os.Stderr, diagnostic.Error())
+	}
 }
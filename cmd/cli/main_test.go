@@ -38,7 +38,29 @@ const testJSON = `{
   }
 }`
 
+// isolateHome points HOME at an empty directory, so that the configuration
+// of whoever runs the tests is not loaded, while keeping the Go caches and
+// settings go run relies on
+func isolateHome(t *testing.T) {
+	t.Helper()
+	names := []string{"GOCACHE", "GOMODCACHE", "GOPATH", "GOENV"}
+	output, err := exec.Command("go", append([]string{"env"}, names...)...).Output()
+	if err != nil {
+		t.Fatalf("go env failed: %v", err)
+	}
+	values := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(values) != len(names) {
+		t.Fatalf("Expected %d values from go env, Got: %q", len(names), values)
+	}
+	for i, name := range names {
+		t.Setenv(name, values[i])
+	}
+	t.Setenv("HOME", t.TempDir())
+}
+
 func TestMain(t *testing.T) {
+	isolateHome(t)
+
 	testCases := []struct {
 		name   string
 		cmd    func(t *testing.T) *exec.Cmd
@@ -469,6 +491,795 @@ func TestMain(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "text mode log line",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				cmd := exec.CommandContext(ctx, "go", "run", "main.go", "--text", "--marker-open", "[", "--marker-close", "]")
+				cmd.Stdin = strings.NewReader(`msg="payload=eyJ1c2VyIjoiam9obiJ9" level=info`)
+				return cmd
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err != nil {
+					t.Errorf("Command failed: %v", err)
+					return
+				}
+				expected := `msg="payload=[{"user":"john"}]" level=info`
+				actual := strings.TrimSpace(string(output))
+				if actual != expected {
+					t.Errorf("Expected: %s, Got: %s", expected, actual)
+				}
+			},
+		},
+		{
+			name: "logfmt mode",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				cmd := exec.CommandContext(ctx, "go", "run", "main.go", "--logfmt")
+				cmd.Stdin = strings.NewReader("level=info body=eyJ1c2VyIjoiam9obiJ9\npanic: not logfmt\n")
+				return cmd
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err != nil {
+					t.Errorf("Command failed: %v", err)
+					return
+				}
+				expected := "{\"body\":{\"user\":\"john\"},\"level\":\"info\"}\npanic: not logfmt"
+				actual := strings.TrimSpace(string(output))
+				if actual != expected {
+					t.Errorf("Expected: %s, Got: %s", expected, actual)
+				}
+			},
+		},
+		{
+			name: "data URI and encoded-word fields",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				return exec.CommandContext(ctx, "go", "run", "main.go",
+					`{"body": "data:application/json;base64,eyJrZXkiOiJ2YWx1ZSJ9", "subject": "=?UTF-8?B?SGVsbG8gV29ybGQ=?="}`)
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err != nil {
+					t.Errorf("Command failed: %v", err)
+					return
+				}
+				expected := `{"body":{"key":"value"},"subject":"Hello World"}`
+				actual := strings.TrimSpace(string(output))
+				if actual != expected {
+					t.Errorf("Expected: %s, Got: %s", expected, actual)
+				}
+			},
+		},
+		{
+			name: "annotate decoded values",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				return exec.CommandContext(ctx, "go", "run", "main.go", "--annotate",
+					`{"user": {"bio": "Y2Fm6SBjcuhtZSBicvts6WU="}, "plain": "text"}`)
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err != nil {
+					t.Errorf("Command failed: %v", err)
+					return
+				}
+				expected := `{"result":{"plain":"text","user":{"bio":"café crème brûlée"}},` +
+					`"annotations":[{"path":"$.user.bio","codec":"base64","charset":"iso-8859-1"}]}`
+				actual := strings.TrimSpace(string(output))
+				if actual != expected {
+					t.Errorf("Expected: %s, Got: %s", expected, actual)
+				}
+			},
+		},
+		{
+			name: "unknown charset",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				return exec.CommandContext(ctx, "go", "run", "main.go", "--charset", "klingon", "{}")
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err == nil {
+					t.Errorf("Expected command to fail with an unknown charset")
+					return
+				}
+				if !strings.Contains(string(stderr), "unsupported charset") {
+					t.Errorf("Expected error message about the charset, got: %s", stderr)
+				}
+			},
+		},
+		{
+			name: "max input size",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				cmd := exec.CommandContext(ctx, "go", "run", "main.go", "--max-input", "16")
+				cmd.Stdin = strings.NewReader(`{"data": "SGVsbG8gV29ybGQ="}`)
+				return cmd
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err == nil {
+					t.Errorf("Expected command to fail with oversized input")
+					return
+				}
+				if !strings.Contains(string(stderr), "max input size of 16 exceeded") {
+					t.Errorf("Expected error message about the input size, got: %s", stderr)
+				}
+			},
+		},
+		{
+			name: "tui rejects several documents",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				cmd := exec.CommandContext(ctx, "go", "run", "main.go", "--tui")
+				cmd.Stdin = strings.NewReader(`{"a": "SGVsbG8gV29ybGQ="}` + "\n" + `{"b": "SGVsbG8gV29ybGQ="}` + "\n")
+				return cmd
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err == nil {
+					t.Errorf("Expected command to fail with several documents")
+					return
+				}
+				if !strings.Contains(string(stderr), "--tui shows a single JSON document") {
+					t.Errorf("Expected error message about several documents, got: %s", stderr)
+				}
+			},
+		},
+		{
+			name: "max depth exceeded",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				// {"next": base64 of {"next": "hello world, nested"}}
+				return exec.CommandContext(ctx, "go", "run", "main.go", "--max-depth", "1",
+					`{"data": "eyJuZXh0IjoiZXlKdVpYaDBJam9pYUdWc2JHOGdkMjl5YkdRc0lHNWxjM1JsWkNKOSJ9"}`)
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err == nil {
+					t.Errorf("Expected command to fail when exceeding the depth")
+					return
+				}
+				if !strings.Contains(string(stderr), "max depth of 1 exceeded at $.data.next") {
+					t.Errorf("Expected error message about the depth, got: %s", stderr)
+				}
+			},
+		},
+		{
+			name: "max depth truncated",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				return exec.CommandContext(ctx, "go", "run", "main.go", "--max-depth", "1", "--truncate",
+					`{"data": "eyJuZXh0IjoiZXlKdVpYaDBJam9pYUdWc2JHOGdkMjl5YkdRc0lHNWxjM1JsWkNKOSJ9"}`)
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err != nil {
+					t.Errorf("Command failed: %v", err)
+					return
+				}
+				expected := `{"data":{"next":"eyJuZXh0IjoiaGVsbG8gd29ybGQsIG5lc3RlZCJ9"}}`
+				actual := strings.TrimSpace(string(output))
+				if actual != expected {
+					t.Errorf("Expected: %s, Got: %s", expected, actual)
+				}
+			},
+		},
+		{
+			name: "explain rejected values",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				return exec.CommandContext(ctx, "go", "run", "main.go", "--explain",
+					`{"short": "abc", "broken": "SGVs!G8gV29ybGQgd29ybGQ=", "ok": "SGVsbG8gV29ybGQgd29ybGQ="}`)
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err != nil {
+					t.Errorf("Command failed: %v", err)
+					return
+				}
+				expected := `{"broken":"SGVs!G8gV29ybGQgd29ybGQ=","ok":"Hello World world","short":"abc"}`
+				actual := strings.TrimSpace(string(output))
+				if actual != expected {
+					t.Errorf("Expected: %s, Got: %s", expected, actual)
+				}
+				stderrOutput := string(stderr)
+				if !strings.Contains(stderrOutput, "$.broken: base64: invalid encoding: illegal base64 data at input byte 4") {
+					t.Errorf("Expected a diagnostic for $.broken, got: %s", stderrOutput)
+				}
+				if !strings.Contains(stderrOutput, "$.short: base64: shorter than the minimum Base64 length") {
+					t.Errorf("Expected a diagnostic for $.short, got: %s", stderrOutput)
+				}
+			},
+		},
+		{
+			name: "stream mode",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				cmd := exec.CommandContext(ctx, "go", "run", "main.go", "--stream")
+				cmd.Stdin = strings.NewReader("{\"z\": \"SGVsbG8gV29ybGQ=\", \"a\": 1.50}\n[\"VGhpcyBpcyBhIHRlc3QgbWVzc2FnZQ==\"]\n")
+				return cmd
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err != nil {
+					t.Errorf("Command failed: %v", err)
+					return
+				}
+				expected := "{\"z\":\"Hello World\",\"a\":1.50}\n[\"This is a test message\"]"
+				actual := strings.TrimSpace(string(output))
+				if actual != expected {
+					t.Errorf("Expected: %s, Got: %s", expected, actual)
+				}
+			},
+		},
+		{
+			name: "parallel jobs",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				records := make([]string, 100)
+				for i := range records {
+					records[i] = fmt.Sprintf(`"%s"`, base64.StdEncoding.EncodeToString(fmt.Appendf(nil, `{"index":%d}`, i)))
+				}
+				cmd := exec.CommandContext(ctx, "go", "run", "main.go", "--jobs", "4")
+				cmd.Stdin = strings.NewReader("[" + strings.Join(records, ",") + "]")
+				return cmd
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err != nil {
+					t.Errorf("Command failed: %v", err)
+					return
+				}
+				records := make([]string, 100)
+				for i := range records {
+					records[i] = fmt.Sprintf(`{"index":%d}`, i)
+				}
+				expected := "[" + strings.Join(records, ",") + "]"
+				actual := strings.TrimSpace(string(output))
+				if actual != expected {
+					t.Errorf("Expected: %s, Got: %s", expected, actual)
+				}
+			},
+		},
+		{
+			name: "blob threshold",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				payload := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0xFF, 0x00}, 512))
+				return exec.CommandContext(ctx, "go", "run", "main.go", "--stream",
+					"--blob-threshold", "64", "--blob-dir", t.TempDir(),
+					`{"attachment": "`+payload+`"}`)
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err != nil {
+					t.Errorf("Command failed: %v, stderr: %s", err, stderr)
+					return
+				}
+				var result struct {
+					Attachment struct {
+						Size int    `json:"size"`
+						Path string `json:"path"`
+					} `json:"attachment"`
+				}
+				if err := json.Unmarshal(output, &result); err != nil {
+					t.Fatalf("Invalid output %s: %v", output, err)
+				}
+				stored, err := os.ReadFile(result.Attachment.Path)
+				if result.Attachment.Size != 1024 || err != nil || !bytes.Equal(stored, bytes.Repeat([]byte{0xFF, 0x00}, 512)) {
+					t.Errorf("Expected the blob to be stored, Got: %s", output)
+				}
+			},
+		},
+		{
+			name: "multiple inputs with headers",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				// Create a temporary directory tree of test files
+				const tmpDir = "test_inputs"
+				if err := os.MkdirAll(tmpDir+"/nested", 0o755); err != nil {
+					t.Fatalf("Failed to create test directory: %v", err)
+				}
+				t.Cleanup(func() { os.RemoveAll(tmpDir) })
+				for name, content := range map[string]string{
+					"a.json":        `{"a": "SGVsbG8gV29ybGQgd29ybGQ="}`,
+					"nested/b.json": `{"b": 1}`,
+				} {
+					if err := os.WriteFile(tmpDir+"/"+name, []byte(content), testFilePerms); err != nil {
+						t.Fatalf("Failed to create test file: %v", err)
+					}
+				}
+
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				return exec.CommandContext(ctx, "go", "run", "main.go", tmpDir+"/*.json", tmpDir+"/nested")
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err != nil {
+					t.Errorf("Command failed: %v, stderr: %s", err, stderr)
+					return
+				}
+				expected := "==> test_inputs/a.json <==\n{\"a\":\"Hello World world\"}\n" +
+					"==> test_inputs/nested/b.json <==\n{\"b\":1}"
+				actual := strings.TrimSpace(string(output))
+				if actual != expected {
+					t.Errorf("Expected: %s, Got: %s", expected, actual)
+				}
+				if !strings.Contains(string(stderr), "Processed 2 files: 1 changed, 1 unchanged, 0 failed") {
+					t.Errorf("Expected a summary, got: %s", stderr)
+				}
+			},
+		},
+		{
+			name: "multiple inputs with pretty output",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				dir := t.TempDir()
+				for name, content := range map[string]string{
+					"a.json": `{"a": "SGVsbG8gV29ybGQgd29ybGQ="}`,
+					"b.json": `{"b": [1]}`,
+				} {
+					if err := os.WriteFile(dir+"/"+name, []byte(content), testFilePerms); err != nil {
+						t.Fatalf("Failed to create test file: %v", err)
+					}
+				}
+
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				return exec.CommandContext(ctx, "go", "run", "main.go", "--pretty", dir+"/a.json", dir+"/b.json")
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err != nil {
+					t.Fatalf("Command failed: %v, stderr: %s", err, stderr)
+				}
+				for _, expected := range []string{"a.json <==\n{\n  \"a\": \"Hello World world\"\n}\n", "b.json <==\n{\n  \"b\": [\n    1\n  ]\n}\n"} {
+					if !strings.Contains(string(output), expected) {
+						t.Errorf("Expected indented output %q, Got: %s", expected, output)
+					}
+				}
+			},
+		},
+		{
+			name: "rewrite in place with backup",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				const tmpFile = "test_rewrite.json"
+				err := os.WriteFile(tmpFile, []byte(`{"a": "SGVsbG8gV29ybGQgd29ybGQ="}`), testFilePerms)
+				if err != nil {
+					t.Fatalf("Failed to create test file: %v", err)
+				}
+				t.Cleanup(func() { os.Remove(tmpFile); os.Remove(tmpFile + ".bak") })
+
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				return exec.CommandContext(ctx, "go", "run", "main.go", "--in-place", "--backup", tmpFile)
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err != nil {
+					t.Errorf("Command failed: %v, stderr: %s", err, stderr)
+					return
+				}
+				if data, _ := os.ReadFile("test_rewrite.json"); strings.TrimSpace(string(data)) != `{"a":"Hello World world"}` {
+					t.Errorf("Expected the file to be rewritten, Got: %s", data)
+				}
+				if data, _ := os.ReadFile("test_rewrite.json.bak"); string(data) != `{"a": "SGVsbG8gV29ybGQgd29ybGQ="}` {
+					t.Errorf("Expected a backup of the original, Got: %s", data)
+				}
+			},
+		},
+		{
+			name: "rewrite in place keeping numbers and redactions",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				inputs := map[string]string{
+					"test_numbers.json":  `{"n": 12345678901234567891, "f": 1.50, "a": "SGVsbG8gV29ybGQgd29ybGQ="}`,
+					"test_redacted.json": `{"password": "hunter2", "n": 1e3}`,
+				}
+				for name, content := range inputs {
+					if err := os.WriteFile(name, []byte(content), testFilePerms); err != nil {
+						t.Fatalf("Failed to create test file: %v", err)
+					}
+					t.Cleanup(func() { os.Remove(name) })
+				}
+
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				return exec.CommandContext(ctx, "go", "run", "main.go", "--in-place", "--redact", "$..password",
+					"test_numbers.json", "test_redacted.json")
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err != nil {
+					t.Errorf("Command failed: %v, stderr: %s", err, stderr)
+					return
+				}
+				expected := map[string]string{
+					"test_numbers.json":  `{"a":"Hello World world","f":1.50,"n":12345678901234567891}`,
+					"test_redacted.json": `{"n":1e3,"password":"[REDACTED]"}`,
+				}
+				for name, content := range expected {
+					if data, _ := os.ReadFile(name); strings.TrimSpace(string(data)) != content {
+						t.Errorf("Expected %s to hold %s, Got: %s", name, content, data)
+					}
+				}
+				if !strings.Contains(string(stderr), "2 changed") {
+					t.Errorf("Expected both files to be rewritten, Got: %s", stderr)
+				}
+			},
+		},
+		{
+			name: "language server",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+
+				var script strings.Builder
+				for _, message := range []string{
+					`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{}}}`,
+					`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+					`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///a.json","languageId":"json","version":1,"text":"{\"data\": \"SGVsbG8gV29ybGQ=\"}"}}}`,
+					`{"jsonrpc":"2.0","id":2,"method":"textDocument/hover","params":{"textDocument":{"uri":"file:///a.json"},"position":{"line":0,"character":12}}}`,
+					`{"jsonrpc":"2.0","id":3,"method":"shutdown"}`,
+					`{"jsonrpc":"2.0","method":"exit"}`,
+				} {
+					fmt.Fprintf(&script, "Content-Length: %d\r\n\r\n%s", len(message), message)
+				}
+
+				cmd := exec.CommandContext(ctx, "go", "run", "main.go", "lsp")
+				cmd.Stdin = strings.NewReader(script.String())
+				return cmd
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err != nil {
+					t.Errorf("Command failed: %v, stderr: %s", err, stderr)
+					return
+				}
+				if !strings.Contains(string(output), `"hoverProvider":true`) {
+					t.Errorf("Expected the server capabilities, Got: %s", output)
+				}
+				if !strings.Contains(string(output), "```text\\nHello World\\n```") {
+					t.Errorf("Expected the decoded value in the hover, Got: %s", output)
+				}
+			},
+		},
+		{
+			name: "textconv",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				return exec.CommandContext(ctx, "go", "run", "main.go", "--textconv",
+					`{"z": "<b>", "a": "eyJyb2xlIjoidmlld2VyIn0="}`)
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err != nil {
+					t.Errorf("Command failed: %v, stderr: %s", err, stderr)
+					return
+				}
+				expected := "{\n  \"a\": {\n    \"role\": \"viewer\"\n  },\n  \"z\": \"<b>\"\n}\n"
+				if string(output) != expected {
+					t.Errorf("Expected sorted, indented output:\n%s\nGot:\n%s", expected, output)
+				}
+			},
+		},
+		{
+			name: "diff",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				return exec.CommandContext(ctx, "go", "run", "main.go", "diff",
+					`{"id": 1, "event": "eyJyb2xlIjoidmlld2VyIn0="}`,
+					`{"id": 1, "event": "eyJyb2xlIjoiYWRtaW4ifQ=="}`)
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err == nil {
+					t.Errorf("Expected a failing exit status for differing documents")
+				}
+				expected := `~ $.event.role: "viewer" → "admin"  (in $.event base64|json)`
+				if !strings.Contains(string(output), expected) {
+					t.Errorf("Expected %s, Got: %s, stderr: %s", expected, output, stderr)
+				}
+			},
+		},
+		{
+			name: "query",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				return exec.CommandContext(ctx, "go", "run", "main.go", "-q", `.event.role, (.. | select(decoded) | jsonpath)`,
+					`{"id": 1, "event": "eyJyb2xlIjoidmlld2VyIn0="}`)
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err != nil {
+					t.Errorf("Command failed: %v, stderr: %s", err, stderr)
+					return
+				}
+				expected := "\"viewer\"\n\"$.event\"\n"
+				if string(output) != expected {
+					t.Errorf("Expected %s, Got: %s", expected, output)
+				}
+			},
+		},
+		{
+			name: "diff as JSON Patch ignoring paths",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				return exec.CommandContext(ctx, "go", "run", "main.go", "diff", "--format", "patch", "--ignore", "$.ts",
+					`{"ts": 1, "event": "eyJyb2xlIjoidmlld2VyIn0="}`,
+					`{"ts": 2, "event": "eyJyb2xlIjoiYWRtaW4ifQ=="}`)
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err == nil {
+					t.Errorf("Expected a failing exit status for differing documents")
+				}
+				expected := `[{"op":"replace","path":"/event/role","value":"admin"}]` + "\n"
+				if string(output) != expected {
+					t.Errorf("Expected %s, Got: %s, stderr: %s", expected, output, stderr)
+				}
+			},
+		},
+		{
+			name: "configuration profile with environment override",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				configFile := t.TempDir() + "/jbdecoder.yaml"
+				content := "min_length: 40\nprofiles:\n  safe:\n    redact: [\"$..password\"]\n"
+				if err := os.WriteFile(configFile, []byte(content), testFilePerms); err != nil {
+					t.Fatalf("Failed to create config file: %v", err)
+				}
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				cmd := exec.CommandContext(ctx, "go", "run", "main.go", "--config", configFile, "--profile", "safe",
+					`{"id": "SGVsbG8=", "auth": "eyJwYXNzd29yZCI6InNlY3JldCIsInVzZXIiOiJhbmEifQ=="}`)
+				cmd.Env = append(os.Environ(), "JBDECODER_MIN_LENGTH=8")
+				return cmd
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err != nil {
+					t.Fatalf("Command failed: %v, stderr: %s", err, stderr)
+				}
+				expected := `{"auth":{"password":"[REDACTED]","user":"ana"},"id":"Hello"}` + "\n"
+				if string(output) != expected {
+					t.Errorf("Expected %s, Got: %s", expected, output)
+				}
+			},
+		},
+		{
+			name: "codec rules",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				return exec.CommandContext(ctx, "go", "run", "main.go", "--rule", "$.id=hex", "--rule", "$.body=base64url|json",
+					`{"id": "6f726465722d31", "body": "eyJ1c2VyIjoiam9obiJ9"}`)
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err != nil {
+					t.Fatalf("Command failed: %v, stderr: %s", err, stderr)
+				}
+				expected := `{"body":{"user":"john"},"id":"order-1"}` + "\n"
+				if string(output) != expected {
+					t.Errorf("Expected %s, Got: %s", expected, output)
+				}
+			},
+		},
+		{
+			name: "learn rules from samples",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				samples := t.TempDir() + "/samples.json"
+				content := `{"events": [{"body": "eyJ1c2VyIjoiam9obiJ9"}], "id": 1}` + "\n" +
+					`{"events": [{"body": "eyJ1c2VyIjoibWFyeSJ9"}, {"body": "eyJ1c2VyIjoiYW5hIn0="}]}` + "\n"
+				if err := os.WriteFile(samples, []byte(content), testFilePerms); err != nil {
+					t.Fatalf("Failed to create samples: %v", err)
+				}
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				return exec.CommandContext(ctx, "go", "run", "main.go", "learn", "--name", "events", samples)
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err != nil {
+					t.Fatalf("Command failed: %v, stderr: %s", err, stderr)
+				}
+				expected := "# Rules learned from 2 documents. Review them before use\n" +
+					"profiles:\n  events:\n    rules:\n      - path: $.events[*].body\n        codecs: base64|json # 3 of 3 values\n"
+				if string(output) != expected {
+					t.Errorf("Expected %s, Got: %s", expected, output)
+				}
+				if !strings.Contains(string(stderr), "Learned from 2 documents in 1 files: 1 rules") {
+					t.Errorf("Expected a summary, Got: %s", stderr)
+				}
+			},
+		},
+		{
+			name: "stream overrides annotations of the configuration",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				home := t.TempDir()
+				if err := os.WriteFile(home+"/.jbdecoder.yaml", []byte("annotate: true\n"), testFilePerms); err != nil {
+					t.Fatalf("Failed to create config file: %v", err)
+				}
+				t.Setenv("HOME", home)
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				cmd := exec.CommandContext(ctx, "go", "run", "main.go", "--stream")
+				cmd.Stdin = strings.NewReader(`{"data": "eyJ1c2VyIjoiam9obiJ9"}`)
+				return cmd
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err != nil {
+					t.Fatalf("Command failed: %v, stderr: %s", err, stderr)
+				}
+				expected := `{"data":{"user":"john"}}`
+				if actual := strings.TrimSpace(string(output)); actual != expected {
+					t.Errorf("Expected %s, Got: %s", expected, actual)
+				}
+			},
+		},
+		{
+			name: "query overrides annotations of the configuration",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				home := t.TempDir()
+				if err := os.WriteFile(home+"/.jbdecoder.yaml", []byte("annotate: true\n"), testFilePerms); err != nil {
+					t.Fatalf("Failed to create config file: %v", err)
+				}
+				t.Setenv("HOME", home)
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				return exec.CommandContext(ctx, "go", "run", "main.go", "-q", ".data.user", `{"data": "eyJ1c2VyIjoiam9obiJ9"}`)
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err != nil {
+					t.Fatalf("Command failed: %v, stderr: %s", err, stderr)
+				}
+				expected := `"john"`
+				if actual := strings.TrimSpace(string(output)); actual != expected {
+					t.Errorf("Expected %s, Got: %s", expected, actual)
+				}
+			},
+		},
+		{
+			name: "learn reports the configuration settings it ignores",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				home := t.TempDir()
+				if err := os.WriteFile(home+"/.jbdecoder.yaml", []byte("pretty: true\nannotate: true\n"), testFilePerms); err != nil {
+					t.Fatalf("Failed to create config file: %v", err)
+				}
+				t.Setenv("HOME", home)
+				samples := t.TempDir() + "/samples.json"
+				if err := os.WriteFile(samples, []byte(`{"body": "eyJ1c2VyIjoiam9obiJ9"}`), testFilePerms); err != nil {
+					t.Fatalf("Failed to create samples: %v", err)
+				}
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				return exec.CommandContext(ctx, "go", "run", "main.go", "learn", samples)
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err != nil {
+					t.Fatalf("Command failed: %v, stderr: %s", err, stderr)
+				}
+				if !strings.Contains(string(stderr), "Warning: learn ignores the configuration settings annotate, pretty") {
+					t.Errorf("Expected a warning, Got: %s", stderr)
+				}
+			},
+		},
+		{
+			name: "learn applies the configuration in the home directory",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				home := t.TempDir()
+				if err := os.WriteFile(home+"/.jbdecoder.yaml", []byte("exclude: [\"$.events[*].body\"]\n"), testFilePerms); err != nil {
+					t.Fatalf("Failed to create config file: %v", err)
+				}
+				t.Setenv("HOME", home)
+				samples := t.TempDir() + "/samples.json"
+				content := `{"events": [{"body": "eyJ1c2VyIjoiam9obiJ9"}]}` + "\n" + `{"events": [{"body": "eyJ1c2VyIjoibWFyeSJ9"}]}` + "\n"
+				if err := os.WriteFile(samples, []byte(content), testFilePerms); err != nil {
+					t.Fatalf("Failed to create samples: %v", err)
+				}
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				return exec.CommandContext(ctx, "go", "run", "main.go", "learn", samples)
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err != nil {
+					t.Fatalf("Command failed: %v, stderr: %s", err, stderr)
+				}
+				if !strings.Contains(string(stderr), "Learned from 2 documents in 1 files: 0 rules") {
+					t.Errorf("Expected excluded values to be left out, Got: %s%s", output, stderr)
+				}
+			},
+		},
+		{
+			name: "serve loads the configuration",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				return exec.CommandContext(ctx, "go", "run", "main.go", "serve", "--profile", "missing")
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err == nil {
+					t.Fatal("Expected an unknown profile to be rejected")
+				}
+				if !strings.Contains(string(stderr), `unknown profile "missing"`) {
+					t.Errorf("Expected an unknown profile error, Got: %s", stderr)
+				}
+			},
+		},
+		{
+			name: "lsp loads the configuration",
+			cmd: func(t *testing.T) *exec.Cmd {
+				t.Helper()
+				ctx, cancel := context.WithTimeout(t.Context(), testTimeout)
+				t.Cleanup(cancel)
+				cmd := exec.CommandContext(ctx, "go", "run", "main.go", "lsp")
+				cmd.Env = append(os.Environ(), "JBDECODER_PROFILE=missing")
+				return cmd
+			},
+			assert: func(t *testing.T, output []byte, stderr []byte, err error) {
+				t.Helper()
+				if err == nil {
+					t.Fatal("Expected an unknown profile to be rejected")
+				}
+				if !strings.Contains(string(stderr), `unknown profile "missing"`) {
+					t.Errorf("Expected an unknown profile error, Got: %s", stderr)
+				}
+			},
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -0,0 +1,40 @@
+//go:build cgo
+
+package main_test
+
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// Test_Harness builds the shared library and runs the C harness against
+// its generated header
+func Test_Harness(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the harness is linked for Linux")
+	}
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("C compiler not available")
+	}
+
+	dir := t.TempDir()
+	run := func(name string, args ...string) string {
+		t.Helper()
+		output, err := exec.Command(name, args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s failed: %v\n%s", filepath.Base(name), err, output)
+		}
+		return string(output)
+	}
+
+	run("go", "build", "-buildmode=c-shared", "-o", filepath.Join(dir, "libjbdecoder.so"), ".")
+
+	harness := filepath.Join(dir, "harness")
+	run(cc, "-Wall", "-Werror", "-o", harness, filepath.Join("testdata", "harness.c"),
+		"-I", dir, "-L", dir, "-ljbdecoder", "-Wl,-rpath,"+dir)
+
+	t.Log(run(harness))
+}
@@ -0,0 +1,101 @@
+// Command cshared is jbdecoder as a C shared library, for Python, Node and
+// other FFI consumers that want the decoding of the CLI. Build it with
+//
+//	go build -buildmode=c-shared -o libjbdecoder.so ./cmd/cshared
+//
+// which also generates libjbdecoder.h declaring the functions and return
+// codes below. Options and errors are the JSON shapes of package api
+package main
+
+/*
+#include <stdlib.h>
+
+// Return codes of jbd_decode
+enum {
+	JBD_OK = 0,
+	JBD_INVALID_ARGUMENT = 1,
+	JBD_INVALID_OPTIONS = 2,
+	JBD_INVALID_JSON = 3,
+	JBD_LIMIT_EXCEEDED = 4,
+	JBD_INTERNAL = 5,
+};
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"errors"
+	"unsafe"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/api"
+)
+
+// codeInvalidArgument is the error code of NULL arguments, which package
+// api cannot receive
+const codeInvalidArgument = "invalid_argument"
+
+// codes maps the error codes of package api to return codes
+var codes = map[string]C.int{
+	codeInvalidArgument:    C.JBD_INVALID_ARGUMENT,
+	api.CodeInvalidOptions: C.JBD_INVALID_OPTIONS,
+	api.CodeInvalidJSON:    C.JBD_INVALID_JSON,
+	api.CodeLimitExceeded:  C.JBD_LIMIT_EXCEEDED,
+}
+
+// jbd_decode decodes the NUL-terminated JSON document input with the
+// options given as a JSON object, e.g. {"codecs": ["base64"], "pretty":
+// true}, or NULL for the defaults. On JBD_OK *output receives the decoded
+// document as JSON text; otherwise it receives the error object
+// {"code", "message", ...}. *output is allocated with malloc and must be
+// released with jbd_free
+//
+//export jbd_decode
+func jbd_decode(input, options *C.char, output **C.char) C.int {
+	if output == nil {
+		return C.JBD_INVALID_ARGUMENT
+	}
+	if input == nil {
+		return failure(&api.Error{Code: codeInvalidArgument, Message: "input must not be NULL"}, output)
+	}
+
+	var opts api.Options
+	if options != nil {
+		var err error
+		if opts, err = api.ParseOptions([]byte(C.GoString(options))); err != nil {
+			return failure(err, output)
+		}
+	}
+
+	response, err := api.Decode([]byte(C.GoString(input)), opts)
+	if err != nil {
+		return failure(err, output)
+	}
+
+	*output = C.CString(response.Output)
+	return C.JBD_OK
+}
+
+// jbd_free releases a string returned by jbd_decode. NULL is ignored
+//
+//export jbd_free
+func jbd_free(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+// failure stores the error object in output and returns its code
+func failure(err error, output **C.char) C.int {
+	var apiErr *api.Error
+	if !errors.As(err, &apiErr) {
+		apiErr = &api.Error{Code: api.CodeInternal, Message: err.Error()}
+	}
+
+	encoded, _ := json.Marshal(apiErr)
+	*output = C.CString(string(encoded))
+	if code, ok := codes[apiErr.Code]; ok {
+		return code
+	}
+	return C.JBD_INTERNAL
+}
+
+// main is required by c-shared builds and never called
+func main() {}
@@ -0,0 +1,46 @@
+// harness exercises libjbdecoder through its generated header, the way FFI
+// consumers call it. It exits with a non-zero status on the first failure
+#include <stdio.h>
+#include <string.h>
+
+#include "libjbdecoder.h"
+
+static int failures = 0;
+
+// check decodes input with options and compares the return code and output
+static void check(const char *name, char *input, char *options, int code, const char *expected) {
+	char *output = NULL;
+	int got = jbd_decode(input, options, &output);
+
+	if (got != code || output == NULL || strstr(output, expected) == NULL) {
+		fprintf(stderr, "FAIL %s: got %d %s, expected %d containing %s\n",
+			name, got, output ? output : "(null)", code, expected);
+		failures++;
+	} else {
+		printf("ok   %s\n", name);
+	}
+	jbd_free(output);
+}
+
+int main(void) {
+	check("decode", "{\"token\": \"eyJ1c2VyIjoiam9obiJ9\"}", NULL,
+		JBD_OK, "{\"token\":{\"user\":\"john\"}}");
+	check("options", "{\"token\": \"eyJ1c2VyIjoiam9obiJ9\"}", "{\"codecs\": [\"base64\"]}",
+		JBD_OK, "{\"token\":\"{\\\"user\\\":\\\"john\\\"}\"}");
+	check("invalid json", "{\"token\": ", NULL,
+		JBD_INVALID_JSON, "\"code\":\"invalid_json\"");
+	check("invalid options", "{}", "{\"colour\": true}",
+		JBD_INVALID_OPTIONS, "\"code\":\"invalid_options\"");
+	check("limit exceeded", "{\"deep\": \"eyJuZXh0IjoiZXlKdVpYaDBJam9pYUdWc2JHOGdkMjl5YkdRaGZRPT0ifQ==\"}", "{\"depth\": 1}",
+		JBD_LIMIT_EXCEEDED, "\"code\":\"limit_exceeded\"");
+	check("null input", NULL, NULL,
+		JBD_INVALID_ARGUMENT, "\"code\":\"invalid_argument\"");
+
+	if (jbd_decode("{}", NULL, NULL) != JBD_INVALID_ARGUMENT) {
+		fprintf(stderr, "FAIL null output: expected JBD_INVALID_ARGUMENT\n");
+		failures++;
+	}
+	jbd_free(NULL);
+
+	return failures == 0 ? 0 : 1;
+}
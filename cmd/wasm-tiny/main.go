@@ -0,0 +1,188 @@
+//go:build js && wasm
+
+// Command wasm-tiny is the WebAssembly module built with TinyGo for a
+// smaller download. It exposes decodeJSON and jbdecoder.decode like
+// cmd/wasm, without locating values in the input and without decodeAsync,
+// and reads and writes JSON with jsonlite instead of encoding/json
+package main
+
+import (
+	"errors"
+	"syscall/js"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/api"
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+	"github.com/vitorhrmiranda/jbdecoder/internal/jsonlite"
+)
+
+// decodeJSON is the main function exposed to JavaScript
+func decodeJSON(this js.Value, args []js.Value) any {
+	if len(args) != 1 {
+		return map[string]any{
+			"error": "Expected exactly one argument (JSON string)",
+		}
+	}
+
+	data, err := jsonlite.Parse([]byte(args[0].String()))
+	if err != nil {
+		return map[string]any{
+			"error": "Invalid JSON: " + err.Error(),
+		}
+	}
+
+	output, err := jsonlite.Marshal(decoder.DecodeBase64Fields(data))
+	if err != nil {
+		return map[string]any{
+			"error": "Error generating output JSON: " + err.Error(),
+		}
+	}
+
+	return map[string]any{
+		"result": string(output),
+	}
+}
+
+// decode is exposed to JavaScript as jbdecoder.decode(input, options). It
+// takes the options of cmd/wasm and returns {result, output, annotations,
+// diagnostics}, or {error: {code, message}} where invalid_json errors carry
+// the byte offset and limit_exceeded errors the limit and path
+func decode(this js.Value, args []js.Value) any {
+	if len(args) < 1 || len(args) > 2 || args[0].Type() != js.TypeString {
+		return failure(&api.Error{
+			Code:    api.CodeInvalidOptions,
+			Message: "expected a JSON string and an optional options object",
+		})
+	}
+
+	opts, err := options(args[1:])
+	if err != nil {
+		return failure(err)
+	}
+
+	d, err := decoder.New(opts.DecoderOptions())
+	if err != nil {
+		return failure(&api.Error{Code: api.CodeInvalidOptions, Message: err.Error()})
+	}
+
+	data, err := jsonlite.Parse([]byte(args[0].String()))
+	if err != nil {
+		return syntaxFailure(err)
+	}
+
+	result, err := d.Decode(data)
+	if err != nil {
+		return failure(api.NewDecodeError(err))
+	}
+
+	var output []byte
+	if opts.Pretty {
+		output, err = jsonlite.MarshalIndent(result.Value, "", "  ")
+	} else {
+		output, err = jsonlite.Marshal(result.Value)
+	}
+	if err != nil {
+		return failure(err)
+	}
+
+	annotations := make([]any, 0, len(result.Annotations))
+	for _, annotation := range result.Annotations {
+		annotations = append(annotations, annotationObject(annotation))
+	}
+
+	response := js.ValueOf(map[string]any{
+		"result":      js.Global().Get("JSON").Call("parse", string(output)),
+		"output":      string(output),
+		"annotations": annotations,
+	})
+	if len(result.Diagnostics) > 0 {
+		diagnostics := make([]any, 0, len(result.Diagnostics))
+		for _, diagnostic := range result.Diagnostics {
+			encoded, _ := diagnostic.MarshalJSON()
+			diagnostics = append(diagnostics, js.Global().Get("JSON").Call("parse", string(encoded)))
+		}
+		response.Set("diagnostics", diagnostics)
+	}
+	return response
+}
+
+// options reads the options object passed from JavaScript through its JSON
+// form, with the same rules as cmd/wasm
+func options(args []js.Value) (api.Options, error) {
+	if len(args) == 0 || args[0].IsUndefined() || args[0].IsNull() {
+		return api.Options{}, nil
+	}
+	if args[0].Type() != js.TypeObject {
+		return api.Options{}, &api.Error{Code: api.CodeInvalidOptions, Message: "options must be an object"}
+	}
+
+	encoded := js.Global().Get("JSON").Call("stringify", args[0]).String()
+	value, err := jsonlite.Parse([]byte(encoded))
+	if err != nil {
+		return api.Options{}, &api.Error{Code: api.CodeInvalidOptions, Message: "invalid options: " + err.Error()}
+	}
+	return api.OptionsFrom(value)
+}
+
+// syntaxFailure reports invalid input along with the offset of the
+// offending byte
+func syntaxFailure(err error) any {
+	object := errorObject(&api.Error{Code: api.CodeInvalidJSON, Message: "invalid JSON: " + err.Error()})
+
+	var syntax *jsonlite.SyntaxError
+	if errors.As(err, &syntax) {
+		object["offset"] = max(syntax.Offset-1, 0)
+	}
+	return js.ValueOf(map[string]any{"error": object})
+}
+
+// annotationObject converts an annotation, omitting empty fields like the
+// JSON form of decoder.Annotation
+func annotationObject(annotation decoder.Annotation) map[string]any {
+	object := map[string]any{"path": annotation.Path}
+	if annotation.Codec != "" {
+		object["codec"] = annotation.Codec
+	}
+	if annotation.Charset != "" {
+		object["charset"] = annotation.Charset
+	}
+	if annotation.Limit != "" {
+		object["limit"] = annotation.Limit
+	}
+	return object
+}
+
+// failure converts an error to the {error} object returned to JavaScript
+func failure(err error) any {
+	var apiErr *api.Error
+	if !errors.As(err, &apiErr) {
+		apiErr = &api.Error{Code: api.CodeInternal, Message: err.Error()}
+	}
+	return js.ValueOf(map[string]any{"error": errorObject(apiErr)})
+}
+
+// errorObject converts an error to its object, omitting empty fields like
+// the JSON form of api.Error
+func errorObject(e *api.Error) map[string]any {
+	object := map[string]any{"code": e.Code, "message": e.Message}
+	if e.Limit != "" {
+		object["limit"] = e.Limit
+	}
+	if e.Path != "" {
+		object["path"] = e.Path
+	}
+	return object
+}
+
+// main function registers the WebAssembly functions
+func main() {
+	js.Global().Set("decodeJSON", js.FuncOf(decodeJSON))
+	js.Global().Set("jbdecoder", js.ValueOf(map[string]any{
+		"decode": js.FuncOf(decode),
+	}))
+
+	// Signal that WASM is ready
+	js.Global().Set("wasmReady", js.ValueOf(true))
+
+	// Keep the program running
+	select {}
+}
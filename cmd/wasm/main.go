@@ -4,9 +4,14 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"sync"
 	"syscall/js"
+	"time"
 
+	"github.com/vitorhrmiranda/jbdecoder/internal/api"
 	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
 )
 
@@ -44,16 +49,282 @@ func decodeJSON(this js.Value, args []js.Value) any {
 	}
 }
 
+// decode is exposed to JavaScript as jbdecoder.decode(input, options). It
+// returns {result, output, annotations, diagnostics} on success and
+// {error: {code, message, limit, path, range}} on failure, see package api
+func decode(this js.Value, args []js.Value) any {
+	if len(args) < 1 || len(args) > 2 || args[0].Type() != js.TypeString {
+		return failure(&api.Error{
+			Code:    api.CodeInvalidOptions,
+			Message: "expected a JSON string and an optional options object",
+		})
+	}
+
+	opts, err := options(args[1:])
+	if err != nil {
+		return failure(err)
+	}
+
+	response, err := api.Decode([]byte(args[0].String()), opts)
+	if err != nil {
+		return failure(err)
+	}
+	return responseObject(response)
+}
+
+// options reads the optional options object passed from JavaScript. The
+// signal and onProgress members of decodeAsync are not decoder options and
+// are skipped
+func options(args []js.Value) (api.Options, error) {
+	if len(args) == 0 || args[0].IsUndefined() || args[0].IsNull() {
+		return api.Options{}, nil
+	}
+	if args[0].Type() != js.TypeObject {
+		return api.Options{}, &api.Error{Code: api.CodeInvalidOptions, Message: "options must be an object"}
+	}
+
+	object := js.Global().Get("Object")
+	copied := object.Call("assign", object.New(), args[0])
+	copied.Delete("signal")
+	copied.Delete("onProgress")
+
+	encoded := js.Global().Get("JSON").Call("stringify", copied).String()
+	return api.ParseOptions([]byte(encoded))
+}
+
+// yieldInterval is how long decodeAsync may keep the JavaScript thread busy
+// before handing it back to the event loop
+const yieldInterval = 50 * time.Millisecond
+
+// calls tracks the pending decodeAsync calls so that shutdown can cancel
+// them and wait for their promises to settle
+var calls struct {
+	sync.Mutex
+	cancel  map[int]context.CancelFunc
+	next    int
+	closed  bool
+	pending sync.WaitGroup
+}
+
+// decodeAsync is exposed to JavaScript as jbdecoder.decodeAsync(input,
+// options). It returns a Promise of the decode result that rejects with the
+// error object. The options may hold an AbortSignal as signal to cancel
+// decoding and an onProgress({done, total}) callback. Decoding regularly
+// hands the thread back to the event loop, so that pages stay responsive
+// and abort events are delivered even without a Web Worker
+func decodeAsync(this js.Value, args []js.Value) any {
+	executor := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+		start(args, promise[0], promise[1])
+		return nil
+	})
+	defer executor.Release()
+
+	return js.Global().Get("Promise").New(executor)
+}
+
+// start begins an asynchronous decoding settling resolve or reject
+func start(args []js.Value, resolve, reject js.Value) {
+	if len(args) < 1 || len(args) > 2 || args[0].Type() != js.TypeString {
+		reject.Invoke(errorObject(&api.Error{
+			Code:    api.CodeInvalidOptions,
+			Message: "expected a JSON string and an optional options object",
+		}))
+		return
+	}
+
+	opts, err := options(args[1:])
+	if err != nil {
+		reject.Invoke(errorObject(err))
+		return
+	}
+
+	signal, onProgress := js.Undefined(), js.Undefined()
+	if len(args) == 2 && args[1].Type() == js.TypeObject {
+		signal, onProgress = args[1].Get("signal"), args[1].Get("onProgress")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	id, ok := track(cancel)
+	if !ok {
+		cancel()
+		reject.Invoke(errorObject(&api.Error{Code: api.CodeCancelled, Message: "jbdecoder was shut down"}))
+		return
+	}
+
+	abort := js.FuncOf(func(js.Value, []js.Value) any {
+		cancel()
+		return nil
+	})
+	if signal.Type() == js.TypeObject {
+		if signal.Get("aborted").Truthy() {
+			cancel()
+		}
+		signal.Call("addEventListener", "abort", abort)
+	}
+
+	input := []byte(args[0].String())
+	go func() {
+		defer func() {
+			if signal.Type() == js.TypeObject {
+				signal.Call("removeEventListener", "abort", abort)
+			}
+			abort.Release()
+			untrack(id)
+		}()
+
+		// Let the caller continue before the thread is taken over
+		yield()
+
+		last := time.Now()
+		progress := func(p decoder.Progress) {
+			if p.Done < p.Total && time.Since(last) < yieldInterval {
+				return
+			}
+			if onProgress.Type() == js.TypeFunction {
+				onProgress.Invoke(toJS(p))
+			}
+			yield()
+			last = time.Now()
+		}
+
+		response, err := api.DecodeContext(ctx, input, opts, progress)
+		if err != nil {
+			reject.Invoke(errorObject(err))
+			return
+		}
+		resolve.Invoke(responseObject(response))
+	}()
+}
+
+// yield hands the thread back to the JavaScript event loop for a moment.
+// The Go runtime returns control to JavaScript while all goroutines sleep
+func yield() {
+	time.Sleep(time.Millisecond)
+}
+
+// track registers a pending call, failing once shut down
+func track(cancel context.CancelFunc) (int, bool) {
+	calls.Lock()
+	defer calls.Unlock()
+
+	if calls.closed {
+		return 0, false
+	}
+	if calls.cancel == nil {
+		calls.cancel = make(map[int]context.CancelFunc)
+	}
+
+	calls.next++
+	calls.cancel[calls.next] = cancel
+	calls.pending.Add(1)
+	return calls.next, true
+}
+
+// untrack removes a settled call
+func untrack(id int) {
+	calls.Lock()
+	defer calls.Unlock()
+
+	calls.cancel[id]()
+	delete(calls.cancel, id)
+	calls.pending.Done()
+}
+
+// funcs are the functions registered by main, released on shutdown
+var funcs []js.Func
+
+// register exposes fn to JavaScript, keeping it to be released on shutdown
+func register(fn func(this js.Value, args []js.Value) any) js.Func {
+	f := js.FuncOf(fn)
+	funcs = append(funcs, f)
+	return f
+}
+
+// done is closed by shutdown to let main return
+var done = make(chan struct{})
+
+// shutdown is exposed to JavaScript as jbdecoder.shutdown(). It cancels the
+// pending decodeAsync calls, removes the exported functions and releases
+// them, and lets the program exit once the pending promises have settled
+func shutdown(this js.Value, args []js.Value) any {
+	calls.Lock()
+	if calls.closed {
+		calls.Unlock()
+		return nil
+	}
+	calls.closed = true
+	for _, cancel := range calls.cancel {
+		cancel()
+	}
+	calls.Unlock()
+
+	global := js.Global()
+	global.Delete("decodeJSON")
+	global.Delete("jbdecoder")
+	global.Set("wasmReady", js.ValueOf(false))
+
+	for _, f := range funcs {
+		f.Release()
+	}
+	funcs = nil
+
+	close(done)
+	return nil
+}
+
+// failure converts an error to the {error} object returned to JavaScript
+func failure(err error) any {
+	return js.ValueOf(map[string]any{"error": errorObject(err)})
+}
+
+// errorObject converts an error to the error object of package api
+func errorObject(err error) any {
+	var apiErr *api.Error
+	if !errors.As(err, &apiErr) {
+		apiErr = &api.Error{Code: api.CodeInternal, Message: err.Error()}
+	}
+	return toJS(apiErr)
+}
+
+// responseObject converts a response to JavaScript. The result is parsed
+// from the output instead of serializing the document a second time
+func responseObject(response api.Response) js.Value {
+	output := response.Output
+	response.Result = nil
+
+	object := toJS(response)
+	object.Set("result", js.Global().Get("JSON").Call("parse", output))
+	return object
+}
+
+// toJS converts a JSON-serializable value to a JavaScript value by parsing
+// its JSON form, which is cheaper than building it with js.ValueOf call by
+// call
+func toJS(v any) js.Value {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return js.ValueOf(map[string]any{"error": map[string]any{"code": api.CodeInternal, "message": err.Error()}})
+	}
+	return js.Global().Get("JSON").Call("parse", string(encoded))
+}
+
 // main function registers the WebAssembly functions
 func main() {
-	c := make(chan struct{})
-
 	// Register the decodeJSON function to be called from JavaScript
-	js.Global().Set("decodeJSON", js.FuncOf(decodeJSON))
+	js.Global().Set("decodeJSON", register(decodeJSON))
+
+	// Register the structured API under the jbdecoder namespace
+	js.Global().Set("jbdecoder", js.ValueOf(map[string]any{
+		"decode":      register(decode),
+		"decodeAsync": register(decodeAsync),
+		"shutdown":    register(shutdown),
+	}))
 
 	// Signal that WASM is ready
 	js.Global().Set("wasmReady", js.ValueOf(true))
 
-	// Keep the program running
-	<-c
+	// Keep the program running until shutdown, then let the pending calls
+	// settle their promises
+	<-done
+	calls.pending.Wait()
 }
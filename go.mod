@@ -1,3 +1,11 @@
 module github.com/vitorhrmiranda/jbdecoder
 
 go 1.25.0
+
+require (
+	golang.org/x/term v0.38.0
+	golang.org/x/text v0.41.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.39.0 // indirect
@@ -0,0 +1,234 @@
+//go:build !tinygo
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+)
+
+// ParseOptions reads Options from a JSON object like OptionsFrom. Empty
+// input yields the defaults
+func ParseOptions(data []byte) (Options, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return Options{}, nil
+	}
+
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return Options{}, &Error{Code: CodeInvalidOptions, Message: "invalid options: " + err.Error()}
+	}
+	return OptionsFrom(value)
+}
+
+// Annotation describes a decoded value. Range locates the value in the
+// input, or the encoded string holding it for values nested in decoded
+// content
+type Annotation struct {
+	decoder.Annotation
+	Range *Range `json:"range,omitempty"`
+}
+
+// Diagnostic explains why a value was not decoded
+type Diagnostic struct {
+	Path    string `json:"path"`
+	Codec   string `json:"codec"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+	Detail  string `json:"detail,omitempty"`
+	Range   *Range `json:"range,omitempty"`
+}
+
+// Response is the outcome of a successful call
+type Response struct {
+	// Result is the decoded document
+	Result any `json:"result"`
+
+	// Output is the decoded document as JSON text
+	Output string `json:"output"`
+
+	Annotations []Annotation `json:"annotations"`
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// Decode decodes the encoded values of a JSON document. Failures are
+// reported as *Error
+func Decode(input []byte, opts Options) (Response, error) {
+	return DecodeContext(context.Background(), input, opts, nil)
+}
+
+// DecodeContext decodes like Decode, failing with CodeCancelled once ctx is
+// done. progress, when set, is called periodically while decoding
+func DecodeContext(ctx context.Context, input []byte, opts Options, progress func(decoder.Progress)) (Response, error) {
+	decoderOpts := opts.DecoderOptions()
+	decoderOpts.OnProgress = progress
+	d, err := decoder.New(decoderOpts)
+	if err != nil {
+		return Response{}, &Error{Code: CodeInvalidOptions, Message: err.Error()}
+	}
+
+	var data any
+	if err := json.Unmarshal(input, &data); err != nil {
+		return Response{}, syntaxError(input, err)
+	}
+
+	result, err := d.DecodeContext(ctx, data)
+	if err != nil {
+		return Response{}, decodeError(input, err)
+	}
+
+	var output []byte
+	if opts.Pretty {
+		output, err = json.MarshalIndent(result.Value, "", "  ")
+	} else {
+		output, err = json.Marshal(result.Value)
+	}
+	if err != nil {
+		return Response{}, &Error{Code: CodeInternal, Message: err.Error()}
+	}
+
+	l := newLocator(input)
+	response := Response{
+		Result:      result.Value,
+		Output:      string(output),
+		Annotations: make([]Annotation, 0, len(result.Annotations)),
+	}
+	for _, annotation := range result.Annotations {
+		response.Annotations = append(response.Annotations, Annotation{
+			Annotation: annotation,
+			Range:      l.locate(annotation.Path),
+		})
+	}
+	for _, diagnostic := range result.Diagnostics {
+		detail := ""
+		if diagnostic.Err != nil {
+			detail = diagnostic.Err.Error()
+		}
+		response.Diagnostics = append(response.Diagnostics, Diagnostic{
+			Path:    diagnostic.Path,
+			Codec:   diagnostic.Codec,
+			Reason:  diagnostic.Reason.Code(),
+			Message: diagnostic.Reason.Error(),
+			Detail:  detail,
+			Range:   l.locate(diagnostic.Path),
+		})
+	}
+
+	return response, nil
+}
+
+// syntaxError reports invalid input along with where parsing stopped
+func syntaxError(input []byte, err error) *Error {
+	e := &Error{Code: CodeInvalidJSON, Message: "invalid JSON: " + err.Error()}
+
+	// The offset counts the bytes read up to and including the offending one
+	var syntax *json.SyntaxError
+	if errors.As(err, &syntax) {
+		e.Range = &Range{
+			Start: position(input, syntax.Offset-1),
+			End:   position(input, syntax.Offset),
+		}
+	}
+	return e
+}
+
+// decodeError reports a failed decoding, locating exceeded limits
+func decodeError(input []byte, err error) *Error {
+	e := NewDecodeError(err)
+	if e.Code == CodeLimitExceeded {
+		e.Range = newLocator(input).locate(e.Path)
+	}
+	return e
+}
+
+// locator resolves JSONPaths to ranges of the input. The spans of the
+// input values and their positions are computed on first use, in a single
+// pass over the input
+type locator struct {
+	input     []byte
+	located   bool
+	spans     map[string]decoder.Span
+	positions map[int64]Position
+}
+
+// newLocator creates a locator for input
+func newLocator(input []byte) *locator {
+	return &locator{input: input}
+}
+
+// locate returns the range of the value at path, or of its closest
+// ancestor present in the input for values found in decoded content
+func (l *locator) locate(path string) *Range {
+	if !l.located {
+		l.located = true
+		if spans, err := decoder.Locate(l.input); err == nil {
+			l.spans = spans
+			l.positions = positions(l.input, spans)
+		}
+	}
+
+	for candidate := path; candidate != ""; {
+		if span, ok := l.spans[candidate]; ok {
+			return &Range{Start: l.positions[span.Start], End: l.positions[span.End]}
+		}
+
+		cut := strings.LastIndexAny(candidate, ".[")
+		if cut < 0 {
+			break
+		}
+		candidate = candidate[:cut]
+	}
+	return nil
+}
+
+// positions converts the offsets of all spans to positions, sweeping the
+// input once in offset order
+func positions(input []byte, spans map[string]decoder.Span) map[int64]Position {
+	offsets := make([]int64, 0, 2*len(spans))
+	for _, span := range spans {
+		offsets = append(offsets, span.Start, span.End)
+	}
+	slices.Sort(offsets)
+
+	result := make(map[int64]Position, len(offsets))
+	current := Position{Line: 1, Column: 1}
+	for _, offset := range slices.Compact(offsets) {
+		current = advance(input, current, offset)
+		result[offset] = current
+	}
+	return result
+}
+
+// position converts a byte offset of input to a Position
+func position(input []byte, offset int64) Position {
+	return advance(input, Position{Line: 1, Column: 1}, offset)
+}
+
+// advance moves a position forward to offset
+func advance(input []byte, from Position, offset int64) Position {
+	offset = min(max(offset, from.Offset), int64(len(input)))
+
+	for rest := input[from.Offset:offset]; len(rest) > 0; {
+		r, size := utf8.DecodeRune(rest)
+		rest = rest[size:]
+
+		switch {
+		case r == '\n':
+			from.Line, from.Column = from.Line+1, 1
+		case r >= 0x10000:
+			from.Column += 2
+		default:
+			from.Column++
+		}
+	}
+
+	from.Offset = offset
+	return from
+}
@@ -0,0 +1,199 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/api"
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+)
+
+func Test_ParseOptions(t *testing.T) {
+	opts, err := api.ParseOptions([]byte(`{"codecs":["base64"],"filters":{"include":["$.a"]},"depth":2,"pretty":true}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(opts.Codecs) != 1 || len(opts.Filters.Include) != 1 || opts.Depth != 2 || !opts.Pretty {
+		t.Errorf("Unexpected options: %+v", opts)
+	}
+
+	if _, err := api.ParseOptions(nil); err != nil {
+		t.Errorf("Expected empty options to be accepted, Got: %v", err)
+	}
+
+	var apiErr *api.Error
+	if _, err := api.ParseOptions([]byte(`{"prety":true}`)); !errors.As(err, &apiErr) || apiErr.Code != api.CodeInvalidOptions {
+		t.Errorf("Expected an invalid_options error, Got: %v", err)
+	}
+}
+
+func Test_OptionsFrom(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   any
+		message string
+	}{
+		{name: "null members", value: map[string]any{"depth": nil, "pretty": true}},
+		{name: "not an object", value: []any{}, message: "invalid options: options must be an object"},
+		{name: "codec type", value: map[string]any{"codecs": []any{1.0}}, message: "invalid options: codecs must be an array of strings"},
+		{name: "fractional depth", value: map[string]any{"depth": 1.5}, message: "invalid options: depth must be an integer"},
+		{name: "unknown filter", value: map[string]any{"filters": map[string]any{"includes": []any{}}}, message: `invalid options: unknown field "filters.includes"`},
+		{name: "unknown field", value: map[string]any{"prety": true}, message: `invalid options: unknown field "prety"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := api.OptionsFrom(tt.value)
+			if tt.message == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+
+			var apiErr *api.Error
+			if !errors.As(err, &apiErr) || apiErr.Code != api.CodeInvalidOptions || apiErr.Message != tt.message {
+				t.Errorf("Expected invalid_options %q, Got: %v", tt.message, err)
+			}
+		})
+	}
+}
+
+func Test_Decode(t *testing.T) {
+	input := `{
+  "id": 1,
+  "data": "eyJ1c2VyIjoiam9obiJ9",
+  "note": "SGVsbG8gV29ybGQgd29ybGQ="
+}`
+
+	response, err := api.Decode([]byte(input), api.Options{Filters: api.Filters{Include: []string{"$.data"}}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := `{"data":{"user":"john"},"id":1,"note":"SGVsbG8gV29ybGQgd29ybGQ="}`
+	if response.Output != expected {
+		t.Errorf("Expected: %s, Got: %s", expected, response.Output)
+	}
+
+	if len(response.Annotations) != 1 {
+		t.Fatalf("Expected one annotation, Got: %+v", response.Annotations)
+	}
+	annotation := response.Annotations[0]
+	if annotation.Path != "$.data" || annotation.Codec != "base64|json" || annotation.Range == nil {
+		t.Fatalf("Unexpected annotation: %+v", annotation)
+	}
+	start, end := annotation.Range.Start, annotation.Range.End
+	if start.Line != 3 || start.Column != 11 || input[start.Offset:end.Offset] != `"eyJ1c2VyIjoiam9obiJ9"` {
+		t.Errorf("Unexpected range: %+v", annotation.Range)
+	}
+
+	pretty, _ := api.Decode([]byte(`{"a":1}`), api.Options{Pretty: true})
+	if pretty.Output != "{\n  \"a\": 1\n}" {
+		t.Errorf("Expected indented output, Got: %q", pretty.Output)
+	}
+}
+
+func Test_Decode_Nested(t *testing.T) {
+	// {"token":"SGVsbG8gV29ybGQgd29ybGQ="} wrapped in Base64
+	input := `{"outer": "eyJ0b2tlbiI6IlNHVnNiRzhnVjI5eWJHUWdkMjl5YkdRPSJ9"}`
+
+	response, err := api.Decode([]byte(input), api.Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(response.Annotations) != 2 {
+		t.Fatalf("Expected two annotations, Got: %+v", response.Annotations)
+	}
+	outer, nested := response.Annotations[0], response.Annotations[1]
+	if nested.Path != "$.outer.token" || nested.Range == nil || *nested.Range != *outer.Range {
+		t.Errorf("Expected the nested value to be located at its encoded ancestor, Got: %+v", nested)
+	}
+}
+
+func Test_Decode_Errors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		input   string
+		options api.Options
+		code    string
+		line    int
+		column  int
+	}{
+		{
+			name:   "syntax error",
+			input:  "{\n  \"a\": trux\n}",
+			code:   api.CodeInvalidJSON,
+			line:   2,
+			column: 11,
+		},
+		{
+			name:    "limit",
+			input:   `{"ok": 1, "deep": "eyJuZXh0IjoiZXlKdVpYaDBJam9pYUdWc2JHOGdkMjl5YkdRaGZRPT0ifQ=="}`,
+			options: api.Options{Depth: 1},
+			code:    api.CodeLimitExceeded,
+			line:    1,
+			column:  19,
+		},
+		{
+			name:    "unsupported codec",
+			input:   `{}`,
+			options: api.Options{Codecs: []string{"rot13"}},
+			code:    api.CodeInvalidOptions,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := api.Decode([]byte(testCase.input), testCase.options)
+
+			var apiErr *api.Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("Expected an api.Error, Got: %v", err)
+			}
+			if apiErr.Code != testCase.code {
+				t.Errorf("Expected code %s, Got: %s", testCase.code, apiErr.Code)
+			}
+
+			if testCase.line == 0 {
+				return
+			}
+			if apiErr.Range == nil {
+				t.Fatalf("Expected a range, Got: %+v", apiErr)
+			}
+			if start := apiErr.Range.Start; start.Line != testCase.line || start.Column != testCase.column {
+				t.Errorf("Expected %d:%d, Got: %d:%d", testCase.line, testCase.column, start.Line, start.Column)
+			}
+
+			encoded, _ := json.Marshal(apiErr)
+			var decoded map[string]any
+			if json.Unmarshal(encoded, &decoded) != nil || decoded["code"] != testCase.code {
+				t.Errorf("Expected the error to serialize with its code, Got: %s", encoded)
+			}
+		})
+	}
+}
+
+func Test_DecodeContext(t *testing.T) {
+	input := []byte(`[` + strings.Repeat(`"SGVsbG8gV29ybGQgd29ybGQ=",`, 3000) + `1]`)
+
+	var last decoder.Progress
+	if _, err := api.DecodeContext(t.Context(), input, api.Options{}, func(p decoder.Progress) { last = p }); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if last.Done != 3002 || last.Total != 3002 {
+		t.Errorf("Expected a complete progress report, Got: %+v", last)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	_, err := api.DecodeContext(ctx, input, api.Options{}, func(decoder.Progress) { cancel() })
+
+	var apiErr *api.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != api.CodeCancelled {
+		t.Errorf("Expected a cancelled error, Got: %v", err)
+	}
+}
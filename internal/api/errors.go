@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	errs "github.com/vitorhrmiranda/jbdecoder/internal/errors"
+)
+
+// Error codes of Error
+const (
+	CodeInvalidOptions = "invalid_options"
+	CodeInvalidJSON    = "invalid_json"
+	CodeCancelled      = "cancelled"
+	CodeInternal       = "internal"
+)
+
+// CodeLimitExceeded is the error code of exceeded processing limits
+var CodeLimitExceeded = errs.ErrLimitExceeded.Code()
+
+// Position is a location in the input. Line and Column start at 1, and
+// Column counts UTF-16 code units so that it indexes JavaScript strings
+type Position struct {
+	Offset int64 `json:"offset"`
+	Line   int   `json:"line"`
+	Column int   `json:"column"`
+}
+
+// Range is the location of a value in the input
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Error describes a failed call
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+
+	// Limit and Path name the exceeded limit and where it was exceeded
+	Limit string `json:"limit,omitempty"`
+	Path  string `json:"path,omitempty"`
+
+	// Range locates the syntax error or the value exceeding a limit
+	Range *Range `json:"range,omitempty"`
+}
+
+// Error implements the error interface for Error
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// NewDecodeError reports a failed decoding: cancellations with
+// CodeCancelled, exceeded limits with CodeLimitExceeded naming the limit
+// and path, and anything else with CodeInternal
+func NewDecodeError(err error) *Error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return &Error{Code: CodeCancelled, Message: err.Error()}
+	}
+
+	var limit errs.LimitError
+	if !errors.As(err, &limit) {
+		return &Error{Code: CodeInternal, Message: err.Error()}
+	}
+	return &Error{
+		Code:    CodeLimitExceeded,
+		Message: limit.Error(),
+		Limit:   limit.Limit(),
+		Path:    limit.Path(),
+	}
+}
+
+// invalidOptions reports options that cannot be used
+func invalidOptions(message string) *Error {
+	return &Error{Code: CodeInvalidOptions, Message: "invalid options: " + message}
+}
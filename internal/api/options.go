@@ -0,0 +1,185 @@
+// Package api implements the decoding API of the embedded builds, such as
+// the WebAssembly module used by the browser extension. Options, results and
+// errors are plain JSON-serializable values, so that every binding exposes
+// the same shapes. The TinyGo build only gets the options and errors,
+// which do not depend on encoding/json
+package api
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+)
+
+// Options configures a decoding call
+type Options struct {
+	// Codecs restricts decoding to the named codecs, e.g. ["base64", "json"].
+	// Empty enables all codecs
+	Codecs []string `json:"codecs,omitempty"`
+
+	// Filters restricts decoding to parts of the document by JSONPath
+	Filters Filters `json:"filters,omitzero"`
+
+	// Depth is the maximum number of encoding layers decoded below each
+	// other. Zero means decoder.DefaultMaxDepth
+	Depth int `json:"depth,omitempty"`
+
+	// Pretty indents Response.Output
+	Pretty bool `json:"pretty,omitempty"`
+
+	// Charset forces decoded bytes to be read in the given charset
+	Charset string `json:"charset,omitempty"`
+
+	// Truncate leaves values exceeding a limit undecoded instead of failing
+	Truncate bool `json:"truncate,omitempty"`
+
+	// Explain reports a diagnostic for every value that was not decoded
+	Explain bool `json:"explain,omitempty"`
+}
+
+// Filters selects the values to decode by JSONPath pattern, see
+// decoder.ParsePattern
+type Filters struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// OptionsFrom reads Options from a parsed JSON object, as produced by
+// encoding/json or jsonlite, so that every build accepts the same options.
+// Null members are left unset, and unknown members are rejected to catch
+// typos
+func OptionsFrom(value any) (Options, error) {
+	var opts Options
+	if value == nil {
+		return opts, nil
+	}
+	object, ok := value.(map[string]any)
+	if !ok {
+		return Options{}, invalidOptions("options must be an object")
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(object)) {
+		member := object[key]
+		if member == nil {
+			continue
+		}
+
+		var err error
+		switch key {
+		case "codecs":
+			opts.Codecs, err = stringList(key, member)
+		case "filters":
+			opts.Filters, err = filters(member)
+		case "depth":
+			opts.Depth, err = integer(key, member)
+		case "charset":
+			opts.Charset, err = str(key, member)
+		case "pretty":
+			opts.Pretty, err = boolean(key, member)
+		case "truncate":
+			opts.Truncate, err = boolean(key, member)
+		case "explain":
+			opts.Explain, err = boolean(key, member)
+		default:
+			err = invalidOptions("unknown field \"" + key + "\"")
+		}
+		if err != nil {
+			return Options{}, err
+		}
+	}
+	return opts, nil
+}
+
+// DecoderOptions returns the decoder options of a call, which decodes its
+// own copy of the document in place
+func (o Options) DecoderOptions() decoder.Options {
+	return decoder.Options{
+		Charset:  o.Charset,
+		MaxDepth: o.Depth,
+		Truncate: o.Truncate,
+		Explain:  o.Explain,
+		InPlace:  true,
+		Codecs:   o.Codecs,
+		Include:  o.Filters.Include,
+		Exclude:  o.Filters.Exclude,
+	}
+}
+
+// filters reads the {include, exclude} filters option
+func filters(value any) (Filters, error) {
+	object, ok := value.(map[string]any)
+	if !ok {
+		return Filters{}, invalidType("filters", "an object")
+	}
+
+	var f Filters
+	for _, key := range slices.Sorted(maps.Keys(object)) {
+		var err error
+		switch key {
+		case "include":
+			f.Include, err = stringList("filters."+key, object[key])
+		case "exclude":
+			f.Exclude, err = stringList("filters."+key, object[key])
+		default:
+			err = invalidOptions("unknown field \"filters." + key + "\"")
+		}
+		if err != nil {
+			return Filters{}, err
+		}
+	}
+	return f, nil
+}
+
+// stringList reads an option holding an array of strings
+func stringList(name string, value any) ([]string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	items, ok := value.([]any)
+	if !ok {
+		return nil, invalidType(name, "an array of strings")
+	}
+
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, invalidType(name, "an array of strings")
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// integer reads an option holding a whole number
+func integer(name string, value any) (int, error) {
+	n, ok := value.(float64)
+	if !ok || n != float64(int(n)) {
+		return 0, invalidType(name, "an integer")
+	}
+	return int(n), nil
+}
+
+// str reads an option holding a string
+func str(name string, value any) (string, error) {
+	s, ok := value.(string)
+	if !ok {
+		return "", invalidType(name, "a string")
+	}
+	return s, nil
+}
+
+// boolean reads an option holding a boolean
+func boolean(name string, value any) (bool, error) {
+	b, ok := value.(bool)
+	if !ok {
+		return false, invalidType(name, "a boolean")
+	}
+	return b, nil
+}
+
+// invalidType reports an option of the wrong type
+func invalidType(name, expected string) *Error {
+	return invalidOptions(name + " must be " + expected)
+}
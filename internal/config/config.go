@@ -0,0 +1,457 @@
+// Package config loads settings from .jbdecoder.yaml, .jbdecoder.yml or
+// .jbdecoder.json files and JBDECODER_* environment variables, e.g.
+//
+//	max_depth: 8
+//	profile: logs
+//	profiles:
+//	  logs:
+//	    include: ["$.events[*].payload"]
+//	    redact: ["$..password"]
+//	    pretty: true
+//	    rules:
+//	      - path: $.events[*].body
+//	        codecs: base64url|gzip|json
+//
+// Settings at the top level of a file apply to every profile, and rules
+// are merged by path. A file in the home directory is read first and one
+// in the working directory overrides it, setting by setting
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileNames are the names of the configuration files looked up in a
+// directory, in order of preference
+var FileNames = []string{".jbdecoder.yaml", ".jbdecoder.yml", ".jbdecoder.json"}
+
+// EnvPrefix starts the names of the environment variables overriding
+// settings, e.g. JBDECODER_MAX_DEPTH
+const EnvPrefix = "JBDECODER_"
+
+// Environment variables selecting the configuration file and the profile
+const (
+	EnvConfig  = EnvPrefix + "CONFIG"
+	EnvProfile = EnvPrefix + "PROFILE"
+)
+
+// Settings are the options a configuration sets. Zero values and nil
+// pointers leave an option unset
+type Settings struct {
+	Charset   string `yaml:"charset" json:"charset"`
+	MaxDepth  int    `yaml:"max_depth" json:"max_depth"`
+	MaxInput  int64  `yaml:"max_input" json:"max_input"`
+	MaxField  int64  `yaml:"max_field" json:"max_field"`
+	MaxOutput int64  `yaml:"max_output" json:"max_output"`
+	MinLength int    `yaml:"min_length" json:"min_length"`
+	Truncate  *bool  `yaml:"truncate" json:"truncate"`
+
+	Codecs  []string `yaml:"codecs" json:"codecs"`
+	Include []string `yaml:"include" json:"include"`
+	Exclude []string `yaml:"exclude" json:"exclude"`
+	Redact  []string `yaml:"redact" json:"redact"`
+
+	// Rules declare the codec chains of values at JSONPath patterns, in
+	// order of precedence, since the first matching rule wins
+	Rules []Rule `yaml:"rules" json:"rules"`
+
+	Pretty   *bool `yaml:"pretty" json:"pretty"`
+	Annotate *bool `yaml:"annotate" json:"annotate"`
+}
+
+// Rule declares the codec chain of the values at paths matching Path, e.g.
+// "base64url|gzip|json" for "$.events[*].body"
+type Rule struct {
+	Path   string `yaml:"path" json:"path"`
+	Codecs string `yaml:"codecs" json:"codecs"`
+}
+
+// File is the content of a configuration file: settings applying to every
+// profile, the named profiles and the profile used by default
+type File struct {
+	Settings `yaml:",inline"`
+	Profile  string              `yaml:"profile" json:"profile"`
+	Profiles map[string]Settings `yaml:"profiles" json:"profiles"`
+}
+
+// Options select the configuration to load
+type Options struct {
+	// Path is a configuration file read instead of discovering one,
+	// defaulting to $JBDECODER_CONFIG
+	Path string
+
+	// Profile is the profile to apply, defaulting to $JBDECODER_PROFILE and
+	// then to the profile named by the configuration
+	Profile string
+
+	// Dir and Home are the directories searched for configuration files,
+	// the working and the home directory when empty
+	Dir, Home string
+
+	// Getenv reads environment variables, os.Getenv when nil
+	Getenv func(string) string
+}
+
+// Load resolves the settings selected by opts: the top-level settings of
+// the configuration, overridden by those of the profile and then by
+// environment variables. A missing configuration yields no settings, but
+// asking for a profile it does not define is an error
+func Load(opts Options) (Settings, error) {
+	getenv := opts.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+
+	path := opts.Path
+	if path == "" {
+		path = getenv(EnvConfig)
+	}
+
+	var file *File
+	var err error
+	if path != "" {
+		file, err = ReadFile(path)
+	} else {
+		file, err = discover(opts.Dir, opts.Home)
+	}
+	if err != nil {
+		return Settings{}, err
+	}
+
+	profile := opts.Profile
+	if profile == "" {
+		profile = getenv(EnvProfile)
+	}
+	if profile == "" {
+		profile = file.Profile
+	}
+
+	settings := file.Settings
+	if profile != "" {
+		p, ok := file.Profiles[profile]
+		if !ok {
+			return Settings{}, fmt.Errorf("unknown profile %q", profile)
+		}
+		settings = settings.overlay(p)
+	}
+
+	env, err := fromEnv(getenv)
+	if err != nil {
+		return Settings{}, err
+	}
+	return settings.overlay(env), nil
+}
+
+// ReadFile parses a configuration file, as JSON when its name ends in
+// .json and as YAML otherwise. Unknown settings are rejected, so that typos
+// do not go unnoticed
+func ReadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &File{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(file)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err = dec.Decode(file); errors.Is(err, io.EOF) {
+			// An empty file sets nothing
+			err = nil
+		}
+	}
+	if err == nil {
+		err = file.validate()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// validate checks that every rule of f names both a path and codecs
+func (f *File) validate() error {
+	check := func(rules []Rule) error {
+		for i, r := range rules {
+			if r.Path == "" || r.Codecs == "" {
+				return fmt.Errorf("rule %d: want path and codecs", i+1)
+			}
+		}
+		return nil
+	}
+	if err := check(f.Rules); err != nil {
+		return err
+	}
+	for name, p := range f.Profiles {
+		if err := check(p.Rules); err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// discover reads the configuration files of home and dir, the latter
+// overriding the former. Missing files are skipped
+func discover(dir, home string) (*File, error) {
+	if dir == "" {
+		var err error
+		if dir, err = os.Getwd(); err != nil {
+			return nil, err
+		}
+	}
+	if home == "" {
+		// Without a home directory, only the working directory is searched
+		home, _ = os.UserHomeDir()
+	}
+
+	merged := &File{}
+	dirs := []string{home, dir}
+	if home == "" || sameDir(home, dir) {
+		dirs = []string{dir}
+	}
+	for _, d := range dirs {
+		file, err := find(d)
+		if err != nil {
+			return nil, err
+		}
+		if file != nil {
+			merged = merged.overlay(file)
+		}
+	}
+	return merged, nil
+}
+
+// find reads the first of FileNames present in dir, or returns nil when
+// there is none
+func find(dir string) (*File, error) {
+	for _, name := range FileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		return ReadFile(path)
+	}
+	return nil, nil
+}
+
+// sameDir reports whether a and b name the same directory
+func sameDir(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
+
+// overlay returns f with the settings and profiles set in other replacing
+// its own. Profiles defined in both are overlaid setting by setting
+func (f *File) overlay(other *File) *File {
+	merged := &File{
+		Settings: f.Settings.overlay(other.Settings),
+		Profile:  f.Profile,
+		Profiles: make(map[string]Settings, len(f.Profiles)+len(other.Profiles)),
+	}
+	if other.Profile != "" {
+		merged.Profile = other.Profile
+	}
+	for name, p := range f.Profiles {
+		merged.Profiles[name] = p
+	}
+	for name, p := range other.Profiles {
+		merged.Profiles[name] = merged.Profiles[name].overlay(p)
+	}
+	return merged
+}
+
+// overlay returns s with the settings set in other replacing its own.
+// Rules are merged by path, those of other coming first so that they win
+// over overlapping rules of s, each list keeping its order
+func (s Settings) overlay(other Settings) Settings {
+	if other.Charset != "" {
+		s.Charset = other.Charset
+	}
+	if other.MaxDepth != 0 {
+		s.MaxDepth = other.MaxDepth
+	}
+	if other.MaxInput != 0 {
+		s.MaxInput = other.MaxInput
+	}
+	if other.MaxField != 0 {
+		s.MaxField = other.MaxField
+	}
+	if other.MaxOutput != 0 {
+		s.MaxOutput = other.MaxOutput
+	}
+	if other.MinLength != 0 {
+		s.MinLength = other.MinLength
+	}
+	if other.Truncate != nil {
+		s.Truncate = other.Truncate
+	}
+	if other.Codecs != nil {
+		s.Codecs = other.Codecs
+	}
+	if other.Include != nil {
+		s.Include = other.Include
+	}
+	if other.Exclude != nil {
+		s.Exclude = other.Exclude
+	}
+	if other.Redact != nil {
+		s.Redact = other.Redact
+	}
+	if other.Rules != nil {
+		rules := slices.Clone(other.Rules)
+		for _, r := range s.Rules {
+			if !slices.ContainsFunc(other.Rules, func(o Rule) bool { return o.Path == r.Path }) {
+				rules = append(rules, r)
+			}
+		}
+		s.Rules = rules
+	}
+	if other.Pretty != nil {
+		s.Pretty = other.Pretty
+	}
+	if other.Annotate != nil {
+		s.Annotate = other.Annotate
+	}
+	return s
+}
+
+// fromEnv reads the settings set by environment variables, named after the
+// settings with EnvPrefix, e.g. JBDECODER_MAX_DEPTH=8. Lists are
+// comma-separated, and rules given as PATH=CODECS items
+func fromEnv(getenv func(string) string) (Settings, error) {
+	var s Settings
+	var errs []error
+	str := func(name string, dst *string) {
+		*dst = getenv(EnvPrefix + name)
+	}
+	integer := func(name string, dst *int64) {
+		if v := getenv(EnvPrefix + name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s%s: %w", EnvPrefix, name, err))
+			}
+			*dst = n
+		}
+	}
+	boolean := func(name string, dst **bool) {
+		if v := getenv(EnvPrefix + name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s%s: %w", EnvPrefix, name, err))
+			}
+			*dst = &b
+		}
+	}
+	list := func(name string, dst *[]string) {
+		if v := getenv(EnvPrefix + name); v != "" {
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					*dst = append(*dst, item)
+				}
+			}
+		}
+	}
+
+	var maxDepth, minLength int64
+	str("CHARSET", &s.Charset)
+	integer("MAX_DEPTH", &maxDepth)
+	integer("MAX_INPUT", &s.MaxInput)
+	integer("MAX_FIELD", &s.MaxField)
+	integer("MAX_OUTPUT", &s.MaxOutput)
+	integer("MIN_LENGTH", &minLength)
+	boolean("TRUNCATE", &s.Truncate)
+	list("CODECS", &s.Codecs)
+	list("INCLUDE", &s.Include)
+	list("EXCLUDE", &s.Exclude)
+	list("REDACT", &s.Redact)
+	boolean("PRETTY", &s.Pretty)
+	boolean("ANNOTATE", &s.Annotate)
+	var rules []string
+	list("RULES", &rules)
+	for _, r := range rules {
+		path, codecs, err := SplitRule(r)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%sRULES: %w", EnvPrefix, err))
+			continue
+		}
+		s.Rules = append(s.Rules, Rule{Path: path, Codecs: codecs})
+	}
+	s.MaxDepth, s.MinLength = int(maxDepth), int(minLength)
+
+	return s, errors.Join(errs...)
+}
+
+// Flags returns the command-line flags equivalent to the settings that are
+// set, keyed by flag name. Lists are given one value per item, as repeated
+// flags would, and rules in order of precedence
+func (s Settings) Flags() map[string][]string {
+	flags := make(map[string][]string)
+	str := func(name, v string) {
+		if v != "" {
+			flags[name] = []string{v}
+		}
+	}
+	integer := func(name string, v int64) {
+		if v != 0 {
+			flags[name] = []string{strconv.FormatInt(v, 10)}
+		}
+	}
+	boolean := func(name string, v *bool) {
+		if v != nil {
+			flags[name] = []string{strconv.FormatBool(*v)}
+		}
+	}
+	list := func(name string, v []string) {
+		if v != nil {
+			flags[name] = v
+		}
+	}
+
+	str("charset", s.Charset)
+	integer("max-depth", int64(s.MaxDepth))
+	integer("max-input", s.MaxInput)
+	integer("max-field", s.MaxField)
+	integer("max-output", s.MaxOutput)
+	integer("min-length", int64(s.MinLength))
+	boolean("truncate", s.Truncate)
+	if s.Codecs != nil {
+		flags["codecs"] = []string{strings.Join(s.Codecs, ",")}
+	}
+	list("include", s.Include)
+	list("exclude", s.Exclude)
+	list("redact", s.Redact)
+	boolean("pretty", s.Pretty)
+	boolean("annotate", s.Annotate)
+	for _, r := range s.Rules {
+		flags["rule"] = append(flags["rule"], r.Path+"="+r.Codecs)
+	}
+	return flags
+}
+
+// SplitRule splits a rule given as PATH=CODECS, e.g.
+// "$.events[*].body=base64url|gzip|json". Codec chains hold no "=", so the
+// path is everything before the last one
+func SplitRule(s string) (path, codecs string, err error) {
+	i := strings.LastIndexByte(s, '=')
+	if i <= 0 || i == len(s)-1 {
+		return "", "", fmt.Errorf("invalid rule %q: want PATH=CODECS", s)
+	}
+	return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:]), nil
+}
@@ -0,0 +1,214 @@
+package config_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/config"
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+)
+
+// write creates a file in dir with the given content
+func write(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return path
+}
+
+func Test_Load(t *testing.T) {
+	home, dir := t.TempDir(), t.TempDir()
+	write(t, home, ".jbdecoder.yaml", `
+max_depth: 4
+charset: latin1
+profile: logs
+profiles:
+  logs:
+    include: ["$.events[*].payload"]
+    pretty: true
+  secrets:
+    redact: ["$..password"]
+`)
+	write(t, dir, ".jbdecoder.json", `{
+	"max_depth": 8,
+	"profiles": {"logs": {"exclude": ["$..raw"]}}
+}`)
+
+	testCases := []struct {
+		name     string
+		profile  string
+		env      map[string]string
+		expected string
+	}{
+		{
+			name:     "default profile",
+			expected: `{"charset":["latin1"],"exclude":["$..raw"],"include":["$.events[*].payload"],"max-depth":["8"],"pretty":["true"]}`,
+		},
+		{
+			name:     "selected profile",
+			profile:  "secrets",
+			expected: `{"charset":["latin1"],"max-depth":["8"],"redact":["$..password"]}`,
+		},
+		{
+			name:     "profile from the environment",
+			env:      map[string]string{"JBDECODER_PROFILE": "secrets", "JBDECODER_PRETTY": "false"},
+			expected: `{"charset":["latin1"],"max-depth":["8"],"pretty":["false"],"redact":["$..password"]}`,
+		},
+		{
+			name:     "environment overrides",
+			env:      map[string]string{"JBDECODER_MAX_DEPTH": "2", "JBDECODER_CODECS": "base64, json", "JBDECODER_INCLUDE": "$.a,$.b"},
+			expected: `{"charset":["latin1"],"codecs":["base64,json"],"exclude":["$..raw"],"include":["$.a","$.b"],"max-depth":["2"],"pretty":["true"]}`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			settings, err := config.Load(config.Options{
+				Profile: testCase.profile,
+				Dir:     dir,
+				Home:    home,
+				Getenv:  func(name string) string { return testCase.env[name] },
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			actual, _ := json.Marshal(settings.Flags())
+			if string(actual) != testCase.expected {
+				t.Errorf("Expected: %s, Got: %s", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func Test_Load_Rules(t *testing.T) {
+	dir := t.TempDir()
+	path := write(t, dir, "rules.yaml", `
+rules:
+  - path: $.id
+    codecs: hex
+  - path: $.blob
+    codecs: base64|json
+profiles:
+  events:
+    rules:
+      - path: $.blob
+        codecs: base64|protobuf
+`)
+
+	settings, err := config.Load(config.Options{
+		Path:    path,
+		Profile: "events",
+		Getenv: func(name string) string {
+			if name == "JBDECODER_RULES" {
+				return "$.events[*].body=base64url|gzip|json"
+			}
+			return ""
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	actual, _ := json.Marshal(settings.Flags()["rule"])
+	expected := `["$.events[*].body=base64url|gzip|json","$.blob=base64|protobuf","$.id=hex"]`
+	if string(actual) != expected {
+		t.Errorf("Expected: %s, Got: %s", expected, actual)
+	}
+
+	for _, invalid := range []string{"$.a", "=base64", "$.a="} {
+		if _, _, err := config.SplitRule(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func Test_Load_Rules_Order(t *testing.T) {
+	home, dir := t.TempDir(), t.TempDir()
+	write(t, home, ".jbdecoder.yaml", `
+rules:
+  - path: $..body
+    codecs: base64
+`)
+	write(t, dir, ".jbdecoder.yaml", `
+rules:
+  - path: $.events[*].body
+    codecs: base64url|json
+  - path: $..payload
+    codecs: hex
+`)
+
+	settings, err := config.Load(config.Options{Dir: dir, Home: home, Getenv: func(string) string { return "" }})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	actual, _ := json.Marshal(settings.Flags()["rule"])
+	expected := `["$.events[*].body=base64url|json","$..payload=hex","$..body=base64"]`
+	if string(actual) != expected {
+		t.Errorf("Expected: %s, Got: %s", expected, actual)
+	}
+
+	// The first matching rule wins, so the specific pattern must stay ahead
+	// of the overlapping one, although it sorts after it
+	rules := make([]decoder.Rule, 0, len(settings.Rules))
+	for _, r := range settings.Rules {
+		rules = append(rules, decoder.Rule{Path: r.Path, Codecs: r.Codecs})
+	}
+	d, err := decoder.New(decoder.Options{Rules: rules})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result, err := d.Decode(map[string]any{
+		"events": []any{map[string]any{"body": "eyJ1c2VyIjoiYW5hIn0"}},
+		"body":   "aGVsbG8=",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	decoded, _ := json.Marshal(result.Value)
+	if string(decoded) != `{"body":"hello","events":[{"body":{"user":"ana"}}]}` {
+		t.Errorf("Expected each body decoded by its rule, Got: %s", decoded)
+	}
+}
+
+func Test_Load_Errors(t *testing.T) {
+	dir := t.TempDir()
+	valid := write(t, dir, "valid.yml", "profiles:\n  logs:\n    pretty: true\n")
+	noEnv := func(string) string { return "" }
+
+	testCases := []struct {
+		name string
+		opts config.Options
+	}{
+		{"unknown profile", config.Options{Path: valid, Profile: "audit", Getenv: noEnv}},
+		{"unknown YAML setting", config.Options{Path: write(t, dir, "typo.yaml", "max_dpeth: 3\n"), Getenv: noEnv}},
+		{"unknown JSON setting", config.Options{Path: write(t, dir, "typo.json", `{"profiles":{"a":{"prety":true}}}`), Getenv: noEnv}},
+		{"rules as a map", config.Options{Path: write(t, dir, "map.yaml", "rules:\n  $.id: hex\n"), Getenv: noEnv}},
+		{"rule without codecs", config.Options{Path: write(t, dir, "rule.yaml", "profiles:\n  a:\n    rules:\n      - path: $.id\n"), Getenv: noEnv}},
+		{"missing file", config.Options{Path: filepath.Join(dir, "missing.yaml"), Getenv: noEnv}},
+		{"invalid variable", config.Options{Path: valid, Getenv: func(name string) string {
+			if name == "JBDECODER_TRUNCATE" {
+				return "maybe"
+			}
+			return ""
+		}}},
+		{"profile without configuration", config.Options{Profile: "logs", Dir: dir, Home: dir, Getenv: noEnv}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if _, err := config.Load(testCase.opts); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+
+	settings, err := config.Load(config.Options{Dir: dir, Home: dir, Getenv: noEnv})
+	if err != nil || len(settings.Flags()) != 0 {
+		t.Errorf("Expected no settings without a configuration, Got: %v (%v)", settings.Flags(), err)
+	}
+}
@@ -0,0 +1,520 @@
+package decoder
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	errs "github.com/vitorhrmiranda/jbdecoder/internal/errors"
+)
+
+// blobChunk is the number of Base64 characters decoded at a time, a
+// multiple of the Base64 block size
+const blobChunk = 32 * 1024
+
+// errBlobEscape is reported for escape sequences inside a Base64 blob other
+// than the escaped slash some encoders produce
+var errBlobEscape = errors.New("unexpected escape sequence in Base64")
+
+// Blob describes a Base64 value longer than Options.BlobThreshold. It is
+// decoded to a sink chunk by chunk instead of being held in memory, and
+// stands in for the value in the output
+type Blob struct {
+	// Size is the decoded size in bytes
+	Size int64 `json:"size"`
+
+	// SHA256 is the hex digest of the decoded bytes
+	SHA256 string `json:"sha256"`
+
+	// Path is the file holding the decoded bytes when Options.BlobDir is set
+	Path string `json:"path,omitempty"`
+}
+
+// blobWriter decodes Base64 written to it in chunks, hashing the decoded
+// bytes and optionally storing them in a file
+type blobWriter struct {
+	dir  string
+	file *os.File
+	hash hash.Hash
+
+	// pending holds the characters not decoded yet and decoded the bytes
+	// of the last chunk
+	pending []byte
+	decoded []byte
+
+	// consumed is the number of characters decoded so far
+	consumed int64
+	size     int64
+	err      error
+}
+
+// newBlobWriter creates a sink for one blob, backed by a temporary file in
+// Options.BlobDir when set
+func (d *Decoder) newBlobWriter() (*blobWriter, error) {
+	b := &blobWriter{
+		dir:     d.opts.BlobDir,
+		hash:    sha256.New(),
+		pending: make([]byte, 0, blobChunk),
+		decoded: make([]byte, base64.StdEncoding.DecodedLen(blobChunk)),
+	}
+
+	if b.dir != "" {
+		file, err := os.CreateTemp(b.dir, "blob-*.tmp")
+		if err != nil {
+			return nil, err
+		}
+		b.file = file
+	}
+
+	return b, nil
+}
+
+// Write buffers Base64 characters and decodes every complete chunk
+func (b *blobWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 && b.err == nil {
+		n := min(len(p), blobChunk-len(b.pending))
+		b.pending = append(b.pending, p[:n]...)
+		p = p[n:]
+		written += n
+
+		if len(b.pending) == blobChunk {
+			b.flush()
+		}
+	}
+	return written, b.err
+}
+
+// fail stops decoding with err, keeping the first error
+func (b *blobWriter) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// flush decodes the pending characters into the sinks
+func (b *blobWriter) flush() {
+	n, err := base64.StdEncoding.Decode(b.decoded, b.pending)
+	if err != nil {
+		var corrupt base64.CorruptInputError
+		if errors.As(err, &corrupt) {
+			err = base64.CorruptInputError(b.consumed + int64(corrupt))
+		}
+		b.fail(err)
+		return
+	}
+
+	b.consumed += int64(len(b.pending))
+	b.size += int64(n)
+	b.pending = b.pending[:0]
+	b.hash.Write(b.decoded[:n])
+
+	if b.file != nil {
+		if _, err := b.file.Write(b.decoded[:n]); err != nil {
+			b.fail(err)
+		}
+	}
+}
+
+// Close decodes the remaining characters and finalizes the blob. A stored
+// blob is named after its digest, so identical blobs share a file. On
+// failure the file is removed
+func (b *blobWriter) Close() (Blob, error) {
+	if b.err == nil && len(b.pending) > 0 {
+		b.flush()
+	}
+
+	blob := Blob{Size: b.size, SHA256: hex.EncodeToString(b.hash.Sum(nil))}
+	if b.file == nil {
+		return blob, b.err
+	}
+
+	if err := b.file.Close(); err != nil {
+		b.fail(err)
+	}
+	if b.err != nil {
+		_ = os.Remove(b.file.Name())
+		return Blob{}, b.err
+	}
+
+	blob.Path = filepath.Join(b.dir, blob.SHA256)
+	if err := os.Rename(b.file.Name(), blob.Path); err != nil {
+		_ = os.Remove(b.file.Name())
+		return Blob{}, err
+	}
+
+	return blob, nil
+}
+
+// blob decodes an oversized string held in memory to the blob sink, leaving
+// it unchanged when it is not Base64
+func (w *walker) blob(s string) any {
+	sink, err := w.decoder.newBlobWriter()
+	if err != nil {
+		w.err = err
+		return s
+	}
+
+	_, _ = io.WriteString(sink, s)
+	blob, err := sink.Close()
+	if err != nil {
+		w.reject(CodecBase64, errs.ErrInvalidEncoding, err)
+		return s
+	}
+
+	w.annotate(CodecBase64, "")
+	return blob
+}
+
+// Lexer states of blobReader
+const (
+	lexValue      = iota // outside of strings
+	lexString            // buffering a string that may become a blob
+	lexEscape            // after a backslash in a buffered string
+	lexPass              // passing a string through unchanged
+	lexPassEscape        // after a backslash in a passed string
+	lexBlob              // decoding a string to the blob sink
+	lexBlobEscape        // after a backslash in a blob string
+)
+
+// blobResult is the outcome of decoding one diverted string
+type blobResult struct {
+	blob Blob
+	err  error
+
+	// raw spools the characters of the string as they appeared in the
+	// input, so that the string can be written back when it is not decoded
+	raw *os.File
+}
+
+// invalid reports whether the string was not valid Base64, as opposed to
+// the blob failing to be stored
+func (b *blobResult) invalid() bool {
+	var corrupt base64.CorruptInputError
+	return errors.As(b.err, &corrupt) || errors.Is(b.err, errBlobEscape)
+}
+
+// text reads the spooled string back into memory
+func (b *blobResult) text() (string, error) {
+	if _, err := b.raw.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	raw, err := io.ReadAll(b.raw)
+	if err != nil {
+		return "", err
+	}
+
+	quoted := make([]byte, 0, len(raw)+2)
+	quoted = append(append(append(quoted, '"'), raw...), '"')
+	value, err := unmarshalJSON(quoted)
+	if err != nil {
+		return "", err
+	}
+	text, _ := value.(string)
+	return text, nil
+}
+
+// release removes the spool of the string
+func (b *blobResult) release() {
+	if b.raw != nil {
+		removeSpool(b.raw)
+		b.raw = nil
+	}
+}
+
+// removeSpool closes and deletes a spool file
+func removeSpool(f *os.File) {
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+}
+
+// blobReader sits between the input and the JSON tokenizer. It passes the
+// input through but diverts every string value (not object keys) longer
+// than Options.BlobThreshold that starts with Base64 characters to a blob
+// sink, replacing it with a
+// short placeholder string that the streamer resolves to the blob. Only the
+// first BlobThreshold bytes of a string are ever buffered; the rest of a
+// diverted string is spooled to a temporary file, from which the streamer
+// writes it back if it turns out not to be Base64
+type blobReader struct {
+	decoder *Decoder
+	src     io.Reader
+	in      []byte
+
+	// out holds the bytes ready for the tokenizer from offset on
+	out    []byte
+	offset int
+	err    error
+
+	state     int
+	raw       []byte
+	candidate bool
+	sink      *blobWriter
+	spool     *os.File
+
+	// containers holds the '{' and '[' of the open objects and arrays, and
+	// key whether the next string is an object key, which is never diverted
+	containers []byte
+	key        bool
+
+	// prefix starts every placeholder; the random nonce keeps it from
+	// matching strings of the input
+	prefix string
+	blobs  []blobResult
+}
+
+// newBlobReader wraps src for Stream when Options.BlobThreshold is set
+func (d *Decoder) newBlobReader(src io.Reader) *blobReader {
+	return &blobReader{
+		decoder: d,
+		src:     src,
+		in:      make([]byte, blobChunk),
+		prefix:  "\x00blob:" + rand.Text() + ":",
+	}
+}
+
+// Read implements io.Reader
+func (r *blobReader) Read(p []byte) (int, error) {
+	for r.offset == len(r.out) {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		r.out, r.offset = r.out[:0], 0
+		n, err := r.src.Read(r.in)
+		r.scan(r.in[:n])
+		if err != nil && r.err == nil {
+			r.end()
+			r.err = err
+		}
+	}
+
+	n := copy(p, r.out[r.offset:])
+	r.offset += n
+	return n, nil
+}
+
+// scan lexes a chunk of input, moving it to out or the current blob sink
+func (r *blobReader) scan(chunk []byte) {
+	threshold := int(r.decoder.opts.BlobThreshold)
+
+	for len(chunk) > 0 && r.err == nil {
+		switch r.state {
+		case lexValue:
+			i := bytes.IndexByte(chunk, '"')
+			if i < 0 {
+				r.structure(chunk)
+				r.out = append(r.out, chunk...)
+				return
+			}
+			r.structure(chunk[:i])
+			r.out = append(r.out, chunk[:i]...)
+			chunk = chunk[i+1:]
+			if r.key {
+				r.out = append(r.out, '"')
+				r.state = lexPass
+				continue
+			}
+			r.raw, r.candidate, r.state = r.raw[:0], true, lexString
+
+		case lexString, lexEscape:
+			c := chunk[0]
+			chunk = chunk[1:]
+
+			switch {
+			case r.state == lexEscape:
+				r.raw = append(r.raw, c)
+				r.candidate = r.candidate && c == '/'
+				r.state = lexString
+			case c == '"':
+				r.passString()
+				r.out = append(r.out, '"')
+				r.state = lexValue
+				continue
+			case c == '\\':
+				r.raw = append(r.raw, c)
+				r.state = lexEscape
+				continue
+			default:
+				r.raw = append(r.raw, c)
+				r.candidate = r.candidate && isBase64Char(c)
+			}
+
+			switch {
+			case !r.candidate:
+				r.passString()
+				r.state = lexPass
+			case len(r.raw) > threshold:
+				r.startBlob()
+			}
+
+		case lexPass:
+			i := bytes.IndexAny(chunk, `"\`)
+			if i < 0 {
+				r.out = append(r.out, chunk...)
+				return
+			}
+			r.out = append(r.out, chunk[:i+1]...)
+			if chunk[i] == '"' {
+				r.state = lexValue
+			} else {
+				r.state = lexPassEscape
+			}
+			chunk = chunk[i+1:]
+
+		case lexPassEscape:
+			r.out = append(r.out, chunk[0])
+			r.state = lexPass
+			chunk = chunk[1:]
+
+		case lexBlob:
+			i := bytes.IndexAny(chunk, `"\`)
+			if i < 0 {
+				r.divert(chunk, chunk)
+				return
+			}
+			r.divert(chunk[:i], chunk[:i])
+			if chunk[i] == '"' {
+				r.endBlob()
+			} else {
+				r.state = lexBlobEscape
+			}
+			chunk = chunk[i+1:]
+
+		case lexBlobEscape:
+			c := chunk[0]
+			switch {
+			case c == '/':
+				r.divert([]byte(`\/`), chunk[:1])
+			case strings.IndexByte(`"\bfnrtu`, c) >= 0:
+				r.sink.fail(errBlobEscape)
+				r.divert([]byte{'\\', c}, nil)
+			default:
+				r.err = fmt.Errorf("invalid character %q in string escape code", c)
+			}
+			r.state = lexBlob
+			chunk = chunk[1:]
+		}
+	}
+}
+
+// structure follows the objects and arrays opened and closed by input
+// outside of strings, to tell object keys from string values
+func (r *blobReader) structure(input []byte) {
+	for _, c := range input {
+		switch c {
+		case '{', '[':
+			r.containers = append(r.containers, c)
+			r.key = c == '{'
+		case '}', ']':
+			if len(r.containers) > 0 {
+				r.containers = r.containers[:len(r.containers)-1]
+			}
+		case ',':
+			r.key = len(r.containers) > 0 && r.containers[len(r.containers)-1] == '{'
+		case ':':
+			r.key = false
+		}
+	}
+}
+
+// passString writes the buffered start of a string that is not a blob
+func (r *blobReader) passString() {
+	r.out = append(r.out, '"')
+	r.out = append(r.out, r.raw...)
+}
+
+// startBlob moves the buffered start of a string to a new blob sink
+func (r *blobReader) startBlob() {
+	spool, err := os.CreateTemp("", "jbdecoder-*.raw")
+	if err != nil {
+		r.err = err
+		return
+	}
+	sink, err := r.decoder.newBlobWriter()
+	if err != nil {
+		removeSpool(spool)
+		r.err = err
+		return
+	}
+
+	r.sink, r.spool = sink, spool
+	r.state = lexBlob
+	r.divert(r.raw, bytes.ReplaceAll(r.raw, []byte(`\/`), []byte("/")))
+}
+
+// divert spools the raw characters of the current blob and writes the
+// Base64 characters they stand for to its sink
+func (r *blobReader) divert(raw, chars []byte) {
+	if _, err := r.spool.Write(raw); err != nil && r.err == nil {
+		r.err = err
+	}
+	_, _ = r.sink.Write(chars)
+}
+
+// endBlob finalizes the current blob and emits its placeholder
+func (r *blobReader) endBlob() {
+	blob, err := r.sink.Close()
+	r.blobs = append(r.blobs, blobResult{blob: blob, err: err, raw: r.spool})
+	r.sink, r.spool = nil, nil
+
+	placeholder, _ := marshalJSON(r.prefix + strconv.Itoa(len(r.blobs)-1))
+	r.out = append(r.out, placeholder...)
+	r.state = lexValue
+}
+
+// end passes on a string cut short by the end of the input, so that the
+// tokenizer reports the truncation
+func (r *blobReader) end() {
+	switch r.state {
+	case lexString, lexEscape:
+		r.passString()
+	case lexBlob, lexBlobEscape:
+		r.sink.fail(io.ErrUnexpectedEOF)
+		_, _ = r.sink.Close()
+		removeSpool(r.spool)
+		r.sink, r.spool = nil, nil
+	}
+}
+
+// close removes the spools left behind when the stream stops early
+func (r *blobReader) close() {
+	for i := range r.blobs {
+		r.blobs[i].release()
+	}
+	if r.sink != nil {
+		r.sink.fail(io.ErrUnexpectedEOF)
+		_, _ = r.sink.Close()
+		removeSpool(r.spool)
+	}
+}
+
+// lookup resolves a placeholder string to the blob it stands for
+func (r *blobReader) lookup(s string) (*blobResult, bool) {
+	index, ok := strings.CutPrefix(s, r.prefix)
+	if !ok {
+		return nil, false
+	}
+
+	i, err := strconv.Atoi(index)
+	if err != nil || i < 0 || i >= len(r.blobs) {
+		return nil, false
+	}
+	return &r.blobs[i], true
+}
+
+// isBase64Char reports whether c belongs to the standard Base64 alphabet
+func isBase64Char(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+		c == '+' || c == '/' || c == '='
+}
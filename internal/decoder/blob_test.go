@@ -0,0 +1,245 @@
+package decoder_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+	errs "github.com/vitorhrmiranda/jbdecoder/internal/errors"
+)
+
+// blobPayload returns binary data spanning several decoding chunks
+func blobPayload() []byte {
+	return bytes.Repeat([]byte{0x00, 0xFF, 0x10, 0x80, 0x7F}, 30000)
+}
+
+func Test_Decoder_Stream_Blob(t *testing.T) {
+	payload := blobPayload()
+	digest := sha256.Sum256(payload)
+	encoded := strings.ReplaceAll(base64.StdEncoding.EncodeToString(payload), "/", `\/`)
+
+	dir := t.TempDir()
+	var annotations []decoder.Annotation
+	d, _ := decoder.New(decoder.Options{
+		BlobThreshold: 1024,
+		BlobDir:       dir,
+		OnAnnotation:  func(a decoder.Annotation) { annotations = append(annotations, a) },
+	})
+
+	input := `{"name":"U2hvcnQgdGV4dCB2YWx1ZQ==","backup":"` + encoded + `","n":1}`
+	var out strings.Builder
+	if err := d.Stream(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var result struct {
+		Name   string       `json:"name"`
+		Backup decoder.Blob `json:"backup"`
+	}
+	if err := json.Unmarshal([]byte(out.String()), &result); err != nil {
+		t.Fatalf("Unexpected output %s: %v", out.String(), err)
+	}
+
+	if result.Name != "Short text value" {
+		t.Errorf("Expected short values to be decoded as usual, Got: %s", result.Name)
+	}
+	if result.Backup.Size != int64(len(payload)) || result.Backup.SHA256 != hex.EncodeToString(digest[:]) {
+		t.Errorf("Unexpected blob: %+v", result.Backup)
+	}
+	if result.Backup.Path != filepath.Join(dir, result.Backup.SHA256) {
+		t.Errorf("Expected the blob to be named after its digest, Got: %s", result.Backup.Path)
+	}
+	if stored, _ := os.ReadFile(result.Backup.Path); !bytes.Equal(stored, payload) {
+		t.Errorf("Expected the stored blob to hold the decoded bytes")
+	}
+	if len(annotations) != 2 || annotations[1].Path != "$.backup" {
+		t.Errorf("Expected an annotation for the blob, Got: %+v", annotations)
+	}
+}
+
+func Test_Decoder_Stream_BlobErrors(t *testing.T) {
+	dir, spools := t.TempDir(), t.TempDir()
+	t.Setenv("TMPDIR", spools)
+	d, _ := decoder.New(decoder.Options{BlobThreshold: 16, BlobDir: dir})
+
+	longText := `{"text":"` + strings.Repeat("not base64, just text ", 10) + `"}`
+	var out strings.Builder
+	if err := d.Stream(strings.NewReader(longText), &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.TrimSpace(out.String()) != longText {
+		t.Errorf("Expected long text to pass through, Got: %s", out.String())
+	}
+
+	// Strings that only look like Base64 are written back unchanged, like
+	// those held in memory
+	for _, invalid := range []string{
+		`["` + strings.Repeat("QUJD", 10) + `!!!!","QUJD"]`,
+		`{"id":"` + strings.Repeat("abc", 301) + `"}`,
+		`{"id":"` + strings.Repeat("QUJD", 10) + `\/\n\u00e9"}`,
+	} {
+		var diagnostics []decoder.Diagnostic
+		d, _ := decoder.New(decoder.Options{
+			BlobThreshold: 16,
+			BlobDir:       dir,
+			Explain:       true,
+			OnDiagnostic:  func(diagnostic decoder.Diagnostic) { diagnostics = append(diagnostics, diagnostic) },
+		})
+
+		var out strings.Builder
+		if err := d.Stream(strings.NewReader(invalid), &out); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if strings.TrimSpace(out.String()) != invalid {
+			t.Errorf("Expected %s to pass through, Got: %s", invalid, out.String())
+		}
+		if len(diagnostics) == 0 || !errors.Is(diagnostics[0], errs.ErrInvalidEncoding) {
+			t.Errorf("Expected an invalid encoding diagnostic, Got: %v", diagnostics)
+		}
+	}
+
+	if err := d.Stream(strings.NewReader(`["`+strings.Repeat("QUJD", 10)+`\q"]`), &strings.Builder{}); err == nil {
+		t.Error("Expected an error for an invalid escape sequence")
+	}
+
+	truncated := `["` + strings.Repeat("QUJD", 10)
+	if err := d.Stream(strings.NewReader(truncated), &strings.Builder{}); err == nil {
+		t.Error("Expected an error for truncated input")
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Expected failed blobs to be removed, Got: %v", entries)
+	}
+	if entries, _ := os.ReadDir(spools); len(entries) != 0 {
+		t.Errorf("Expected spooled strings to be removed, Got: %v", entries)
+	}
+}
+
+func Test_Decoder_Stream_BlobKeys(t *testing.T) {
+	key := strings.Repeat("S2V5", 400)
+	encoded := base64.StdEncoding.EncodeToString(blobPayload())
+	input := `{"` + key + `":"` + encoded + `","list":["` + encoded + `",{"` + key + `":1}]}`
+
+	d, _ := decoder.New(decoder.Options{BlobThreshold: 1024})
+	var out strings.Builder
+	if err := d.Stream(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var result map[string]any
+	if err := json.Unmarshal([]byte(out.String()), &result); err != nil {
+		t.Fatalf("Unexpected output %.200s: %v", out.String(), err)
+	}
+	if _, ok := result[key].(map[string]any); !ok {
+		t.Errorf("Expected the oversized key to be kept and its value to be a blob, Got keys: %.80v", slices.Collect(maps.Keys(result)))
+	}
+	list, _ := result["list"].([]any)
+	if len(list) != 2 {
+		t.Fatalf("Unexpected list: %.200v", result["list"])
+	}
+	if _, ok := list[0].(map[string]any); !ok {
+		t.Errorf("Expected the array element to be a blob, Got: %.80v", list[0])
+	}
+	if nested, _ := list[1].(map[string]any); nested[key] != 1.0 {
+		t.Errorf("Expected the nested oversized key to be kept, Got: %.80v", list[1])
+	}
+}
+
+func Test_Decoder_Blob(t *testing.T) {
+	payload := blobPayload()
+	d, _ := decoder.New(decoder.Options{BlobThreshold: 1024})
+
+	result, err := d.Decode(map[string]any{
+		"backup": base64.StdEncoding.EncodeToString(payload),
+		"broken": strings.Repeat("QUJD", 300) + "!!!!",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	value := result.Value.(map[string]any)
+	blob, ok := value["backup"].(decoder.Blob)
+	if !ok || blob.Size != int64(len(payload)) || blob.Path != "" {
+		t.Errorf("Expected a hashed blob, Got: %+v", value["backup"])
+	}
+	if _, ok := value["broken"].(string); !ok {
+		t.Errorf("Expected invalid Base64 to be left unchanged, Got: %T", value["broken"])
+	}
+}
+
+func Test_Decoder_Stream_BlobSelection(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString(blobPayload())
+	document := base64.StdEncoding.EncodeToString([]byte(`{"note":"` + strings.Repeat("a long note ", 200) + `"}`))
+	input := `{"keep":"` + encoded + `","other":"` + encoded + `","doc":"` + document + `"}`
+
+	testCases := []struct {
+		name    string
+		options decoder.Options
+		blobs   []string
+	}{
+		{
+			name:    "excluded path",
+			options: decoder.Options{Exclude: []string{"$.keep"}},
+			blobs:   []string{"other", "doc"},
+		},
+		{
+			name:    "included path",
+			options: decoder.Options{Include: []string{"$.keep"}},
+			blobs:   []string{"keep"},
+		},
+		{
+			name:    "Base64 disabled",
+			options: decoder.Options{Codecs: []string{decoder.CodecJSON}},
+		},
+		{
+			name:    "rule",
+			options: decoder.Options{Rules: []decoder.Rule{{Path: "$.doc", Codecs: "base64|json"}}},
+			blobs:   []string{"keep", "other"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.options.BlobThreshold = 1024
+			d, err := decoder.New(testCase.options)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var out strings.Builder
+			if err := d.Stream(strings.NewReader(input), &out); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var result map[string]any
+			if err := json.Unmarshal([]byte(out.String()), &result); err != nil {
+				t.Fatalf("Unexpected output %s: %v", out.String(), err)
+			}
+			for name, value := range result {
+				_, isBlob := value.(map[string]any)
+				if name == "doc" && testCase.name == "rule" {
+					if note, _ := value.(map[string]any)["note"].(string); !strings.HasPrefix(note, "a long note") {
+						t.Errorf("Expected the rule to decode %s, Got: %v", name, value)
+					}
+					continue
+				}
+				if isBlob != slices.Contains(testCase.blobs, name) {
+					t.Errorf("Expected %s to be a blob: %v, Got: %.40v", name, !isBlob, value)
+				}
+				if !isBlob && value != map[string]string{"keep": encoded, "other": encoded, "doc": document}[name] {
+					t.Errorf("Expected %s to be left unchanged", name)
+				}
+			}
+		})
+	}
+}
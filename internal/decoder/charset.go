@@ -0,0 +1,442 @@
+package decoder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	xunicode "golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/encoding/unicode/utf32"
+)
+
+// Charset names reported in annotations
+const (
+	CharsetUTF8        = "utf-8"
+	CharsetUTF16LE     = "utf-16le"
+	CharsetUTF16BE     = "utf-16be"
+	CharsetUTF32LE     = "utf-32le"
+	CharsetUTF32BE     = "utf-32be"
+	CharsetShiftJIS    = "shift_jis"
+	CharsetWindows1252 = "windows-1252"
+	CharsetLatin1      = "iso-8859-1"
+)
+
+const (
+	// utf16Unit is the size in bytes of a UTF-16 code unit
+	utf16Unit = 2
+
+	// utf16ZeroRatio is the minimum share of code units with a zero high
+	// byte for BOM-less data to be considered UTF-16
+	utf16ZeroRatio = 0.4
+
+	// minUTF16Units is the minimum number of code units needed to guess
+	// BOM-less UTF-16
+	minUTF16Units = 2
+
+	// maxScripts is the number of distinct writing systems that transcoded
+	// text may mix before it is considered binary noise
+	maxScripts = 2
+
+	// maxHighByteRatio is the largest share of bytes above 0x7F that BOM-less
+	// text may have to be read in a single-byte Western charset
+	maxHighByteRatio = 0.25
+)
+
+// scriptGroups are the writing systems told apart when judging whether
+// transcoded text is plausible. Japanese and Chinese scripts are grouped
+// since they commonly appear together
+var scriptGroups = [][]*unicode.RangeTable{
+	{unicode.Latin},
+	{unicode.Greek},
+	{unicode.Cyrillic},
+	{unicode.Arabic},
+	{unicode.Hebrew},
+	{unicode.Han, unicode.Hiragana, unicode.Katakana},
+	{unicode.Hangul},
+	{unicode.Devanagari},
+	{unicode.Thai},
+}
+
+// asciiSymbols are the ASCII characters, other than letters, digits, space
+// and basic punctuation, that Japanese text seldom puts next to its own
+// characters
+const asciiSymbols = "#$&*+/<=>@\\^_`|~"
+
+// utf8BOM is the optional byte order mark of UTF-8 text
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// byteOrderMarks maps BOMs to the charset they announce, longest first so
+// UTF-32 LE is not mistaken for UTF-16 LE
+var byteOrderMarks = []struct {
+	bom     []byte
+	charset string
+}{
+	{[]byte{0x00, 0x00, 0xFE, 0xFF}, CharsetUTF32BE},
+	{[]byte{0xFF, 0xFE, 0x00, 0x00}, CharsetUTF32LE},
+	{utf8BOM, CharsetUTF8},
+	{[]byte{0xFE, 0xFF}, CharsetUTF16BE},
+	{[]byte{0xFF, 0xFE}, CharsetUTF16LE},
+}
+
+// charsetEncodings resolves the charsets this package detects by itself
+var charsetEncodings = map[string]encoding.Encoding{
+	CharsetUTF8:        xunicode.UTF8,
+	CharsetUTF16LE:     xunicode.UTF16(xunicode.LittleEndian, xunicode.IgnoreBOM),
+	CharsetUTF16BE:     xunicode.UTF16(xunicode.BigEndian, xunicode.IgnoreBOM),
+	CharsetUTF32LE:     utf32.UTF32(utf32.LittleEndian, utf32.IgnoreBOM),
+	CharsetUTF32BE:     utf32.UTF32(utf32.BigEndian, utf32.IgnoreBOM),
+	CharsetShiftJIS:    japanese.ShiftJIS,
+	CharsetWindows1252: charmap.Windows1252,
+	CharsetLatin1:      charmap.ISO8859_1,
+}
+
+// lookupCharset resolves a charset name, or with lookupAlias an alias such
+// as "latin1" or "UTF-16LE", to its encoding and canonical name
+func lookupCharset(name string) (encoding.Encoding, string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+	if enc, ok := charsetEncodings[normalized]; ok {
+		return enc, normalized, nil
+	}
+
+	enc, canonical, ok := lookupAlias(normalized)
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported charset %q", name)
+	}
+	return enc, canonical, nil
+}
+
+// toUTF8 converts text declared in the given charset to UTF-8
+func toUTF8(data []byte, charset string) ([]byte, error) {
+	if charset == "" {
+		charset = CharsetUTF8
+	}
+
+	enc, name, err := lookupCharset(charset)
+	if err != nil {
+		return nil, err
+	}
+
+	if name == CharsetUTF8 {
+		if !utf8.Valid(data) {
+			return nil, fmt.Errorf("invalid %s text", charset)
+		}
+		return data, nil
+	}
+
+	return enc.NewDecoder().Bytes(data)
+}
+
+// DetectCharset guesses the charset of decoded bytes. A byte order mark is
+// authoritative; otherwise the NUL bytes of BOM-less UTF-16 are checked
+// before valid UTF-8, then Shift-JIS and finally the single-byte Western
+// encodings for mostly-ASCII text. It returns an empty name when there is
+// no evidence for any charset, since single-byte charsets accept any data
+func DetectCharset(data []byte) string {
+	for _, mark := range byteOrderMarks {
+		if bytes.HasPrefix(data, mark.bom) {
+			return mark.charset
+		}
+	}
+
+	switch {
+	case looksLikeUTF16(data, true):
+		return CharsetUTF16LE
+	case looksLikeUTF16(data, false):
+		return CharsetUTF16BE
+	case utf8.Valid(data):
+		return CharsetUTF8
+	case looksLikeShiftJIS(data):
+		return CharsetShiftJIS
+	case !looksLikeWestern(data):
+		return ""
+	case hasC1Bytes(data):
+		return CharsetWindows1252
+	default:
+		return CharsetLatin1
+	}
+}
+
+// transcode converts decoded bytes to UTF-8 text, using the forced charset
+// when given or detecting one otherwise. It returns the charset that was
+// applied, or an empty name when the data was plain UTF-8
+func transcode(data []byte, forced string) ([]byte, string, error) {
+	charset := forced
+	if charset == "" {
+		// Fast path for the common case of plain UTF-8 text
+		if bytes.IndexByte(data, 0) < 0 && utf8.Valid(data) && !bytes.HasPrefix(data, utf8BOM) {
+			return data, "", nil
+		}
+
+		charset = DetectCharset(data)
+		switch {
+		case charset == "":
+			return nil, "", errors.New("data is not text in a known charset")
+		case charset == CharsetUTF8 && !bytes.HasPrefix(data, utf8BOM):
+			return data, "", nil
+		}
+	}
+
+	enc, name, err := lookupCharset(charset)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Decoders configured to ignore the BOM still leave it in the output
+	for _, mark := range byteOrderMarks {
+		if mark.charset == name && bytes.HasPrefix(data, mark.bom) {
+			data = data[len(mark.bom):]
+			break
+		}
+	}
+
+	text, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !utf8.Valid(text) || !isPrintable(string(text)) || !isPlausibleText(string(text)) ||
+		forced == "" && !hasPlausibleCasing(string(text)) {
+		return nil, "", fmt.Errorf("data is not %s text", name)
+	}
+
+	return text, name, nil
+}
+
+// looksLikeUTF16 reports whether data has the zero high bytes typical of
+// mostly-ASCII UTF-16 text in the given byte order
+func looksLikeUTF16(data []byte, littleEndian bool) bool {
+	units := len(data) / utf16Unit
+	if len(data)%utf16Unit != 0 || units < minUTF16Units {
+		return false
+	}
+
+	high, low := 1, 0
+	if !littleEndian {
+		high, low = 0, 1
+	}
+
+	zeros := 0
+	for i := 0; i < len(data); i += utf16Unit {
+		if data[i+low] == 0 {
+			return false
+		}
+		if data[i+high] == 0 {
+			zeros++
+		}
+	}
+
+	return float64(zeros)/float64(units) >= utf16ZeroRatio
+}
+
+// looksLikeShiftJIS reports whether data is well-formed Shift-JIS with at
+// least two adjacent double-byte characters. Requiring adjacency keeps
+// Latin-1 text with isolated accented letters from matching. Half-width
+// katakana, lead bytes outside the assigned JIS X 0208 rows, and single
+// ASCII letters or symbols other than basic punctuation next to
+// double-byte characters are rejected, since random bytes produce them far
+// more often than Japanese text, which has full-width punctuation, does
+func looksLikeShiftJIS(data []byte) bool {
+	const (
+		other = iota
+		letter
+		symbol
+		double
+	)
+
+	var kinds []int
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case isASCIILetter(c):
+			kinds = append(kinds, letter)
+		case strings.IndexByte(asciiSymbols, c) >= 0:
+			kinds = append(kinds, symbol)
+		case c < 0x80:
+			kinds = append(kinds, other)
+		case c >= 0x81 && c <= 0x84 || c >= 0x88 && c <= 0x9F || c >= 0xE0 && c <= 0xEA:
+			if i+1 >= len(data) {
+				return false
+			}
+			trail := data[i+1]
+			if trail < 0x40 || trail == 0x7F || trail > 0xFC {
+				return false
+			}
+			kinds = append(kinds, double)
+			i++
+		default:
+			return false
+		}
+	}
+
+	adjacent := false
+	for i, kind := range kinds {
+		previous, next := other, other
+		if i > 0 {
+			previous = kinds[i-1]
+		}
+		if i+1 < len(kinds) {
+			next = kinds[i+1]
+		}
+
+		switch {
+		case kind == double && previous == double:
+			adjacent = true
+		case kind == letter && previous != letter && next != letter && (previous == double || next == double):
+			return false
+		case kind == symbol && (previous == double || next == double):
+			return false
+		}
+	}
+
+	return adjacent
+}
+
+// looksLikeWestern reports whether data is mostly ASCII text with a few
+// letters or typographic marks above 0x7F, each next to an ASCII letter as
+// in Western words
+func looksLikeWestern(data []byte) bool {
+	high := 0
+	for i, c := range data {
+		switch {
+		case c < 0x20 && c != '\n' && c != '\r' && c != '\t' || c == 0x7F:
+			return false
+		case c < 0x80:
+			continue
+		}
+
+		high++
+		if !isWesternHighByte(c) {
+			return false
+		}
+		if !(i > 0 && isASCIILetter(data[i-1]) || i+1 < len(data) && isASCIILetter(data[i+1])) {
+			return false
+		}
+	}
+
+	return high > 0 && float64(high) <= maxHighByteRatio*float64(len(data))
+}
+
+// isWesternHighByte reports whether c is a letter in ISO-8859-1, or a
+// letter, quote or dash that Windows-1252 adds in the 0x80-0x9F range
+func isWesternHighByte(c byte) bool {
+	switch c {
+	case 0x8A, 0x8C, 0x8E, 0x9A, 0x9C, 0x9E, 0x9F, 0x91, 0x92, 0x93, 0x94, 0x96, 0x97:
+		return true
+	case 0xD7, 0xF7:
+		// Multiplication and division signs
+		return false
+	}
+	return c >= 0xC0
+}
+
+// isASCIILetter reports whether c is an ASCII letter
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// hasC1Bytes reports whether data uses the 0x80-0x9F range, which holds
+// printable characters in Windows-1252 but control codes in ISO-8859-1
+func hasC1Bytes(data []byte) bool {
+	for _, c := range data {
+		if c >= 0x80 && c <= 0x9F {
+			return true
+		}
+	}
+	return false
+}
+
+// isPlausibleText reports whether text sticks to a few known writing
+// systems. Binary data read in a multi-byte charset tends to produce
+// printable characters scattered across many unrelated scripts
+func isPlausibleText(text string) bool {
+	seen := make([]bool, len(scriptGroups))
+	scripts := 0
+
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			continue
+		}
+
+		group := -1
+		for i, tables := range scriptGroups {
+			if unicode.In(r, tables...) {
+				group = i
+				break
+			}
+		}
+
+		switch {
+		case !unicode.IsPrint(r) && !unicode.IsSpace(r):
+			// Private use and unassigned code points
+			return false
+		case group >= 0 && !seen[group]:
+			seen[group] = true
+			scripts++
+		case group < 0 && unicode.IsLetter(r):
+			// Letters from scripts outside the known groups are unexpected
+			// in legacy payloads
+			return false
+		}
+
+		if scripts > maxScripts {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hasPlausibleCasing reports whether the words holding non-ASCII letters
+// are lowercase, uppercase or capitalized. Binary data read in a legacy
+// charset mixes cases at random, while words spelled with accented
+// letters or next to Japanese text rarely do. Words of plain ASCII letters
+// are not checked, so identifiers such as camelCase keys are allowed
+func hasPlausibleCasing(text string) bool {
+	var word []rune
+	check := func() bool {
+		defer func() { word = word[:0] }()
+
+		ascii := true
+		for _, r := range word {
+			ascii = ascii && r < utf8.RuneSelf
+		}
+		if ascii {
+			return true
+		}
+
+		// Lowercase letters may only follow a single capital
+		upper, lower := 0, 0
+		for _, r := range word {
+			switch {
+			case unicode.IsUpper(r):
+				if lower > 0 {
+					return false
+				}
+				upper++
+			case unicode.IsLower(r):
+				if upper > 1 {
+					return false
+				}
+				lower++
+			}
+		}
+		return true
+	}
+
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			word = append(word, r)
+			continue
+		}
+		if !check() {
+			return false
+		}
+	}
+	return check()
+}
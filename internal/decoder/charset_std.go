@@ -0,0 +1,23 @@
+//go:build !tinygo
+
+package decoder
+
+import (
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// lookupAlias resolves the names and aliases of the WHATWG Encoding
+// Standard, such as "latin1" or "euc-kr"
+func lookupAlias(name string) (encoding.Encoding, string, bool) {
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, "", false
+	}
+
+	canonical, err := htmlindex.Name(enc)
+	if err != nil {
+		canonical = name
+	}
+	return enc, canonical, true
+}
@@ -0,0 +1,124 @@
+package decoder_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math/rand"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+)
+
+func Test_DetectCharset(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    []byte
+		expected string
+	}{
+		{name: "utf-8", input: []byte("café"), expected: decoder.CharsetUTF8},
+		{name: "utf-8 BOM", input: []byte("\xef\xbb\xbfhi"), expected: decoder.CharsetUTF8},
+		{name: "utf-16le BOM", input: []byte("\xff\xfeh\x00i\x00"), expected: decoder.CharsetUTF16LE},
+		{name: "utf-16be BOM", input: []byte("\xfe\xff\x00h\x00i"), expected: decoder.CharsetUTF16BE},
+		{name: "utf-16le without BOM", input: []byte("h\x00e\x00l\x00l\x00o\x00"), expected: decoder.CharsetUTF16LE},
+		{name: "shift_jis", input: []byte("\x93\xfa\x96\x7b\x8c\xea"), expected: decoder.CharsetShiftJIS},
+		{name: "windows-1252", input: []byte("\x93quoted\x94"), expected: decoder.CharsetWindows1252},
+		{name: "latin-1", input: []byte("na\xefve caf\xe9"), expected: decoder.CharsetLatin1},
+		{name: "latin-1 JSON", input: []byte("{\"name\":\"Jos\xe9\",\"city\":\"S\xe3o Paulo\"}"), expected: decoder.CharsetLatin1},
+		{name: "random bytes", input: []byte("\xb3\xdf\xb4B\xber\xb3\xe1\xad\"pn\x93?\x99\xa1"), expected: ""},
+		{name: "half-width katakana", input: []byte("X\x9b\xac\xce\xa9\xd6\xe2c\xe2\\'t"), expected: ""},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := decoder.DetectCharset(testCase.input)
+			if actual != testCase.expected {
+				t.Errorf("Expected: %s, Got: %s", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func Test_Decoder_Charset(t *testing.T) {
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+	data := map[string]any{
+		"latin": encode("caf\xe9 cr\xe8me br\xfbl\xe9e"),
+		"utf16": encode("\xff\xfeH\x00e\x00l\x00l\x00o\x00 \x00W\x00o\x00r\x00l\x00d\x00"),
+		"sjis":  encode(`{"text":"` + "\x93\xfa\x96\x7b\x8c\xea\x82\xc5\x82\xb7" + `"}`),
+		// Mostly ASCII, but cased at random
+		"noise": encode("\xefgzF{H#p\xeeZm\xea"),
+	}
+
+	d, err := decoder.New(decoder.Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	result, err := d.Decode(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	actual, _ := json.Marshal(result.Value)
+	expected := `{"latin":"café crème brûlée","noise":"` + data["noise"].(string) + `","sjis":{"text":"日本語です"},"utf16":"Hello World"}`
+	if expected != string(actual) {
+		t.Errorf("Expected: %s, Got: %s", expected, actual)
+	}
+
+	charsets := make(map[string]string)
+	for _, annotation := range result.Annotations {
+		charsets[annotation.Path] = annotation.Charset
+	}
+	if charsets["$.latin"] != decoder.CharsetLatin1 || charsets["$.utf16"] != decoder.CharsetUTF16LE ||
+		charsets["$.sjis"] != decoder.CharsetShiftJIS {
+		t.Errorf("Unexpected annotations: %+v", result.Annotations)
+	}
+}
+
+func Test_Decoder_ForcedCharset(t *testing.T) {
+	d, err := decoder.New(decoder.Options{Charset: "windows-1252"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	result, err := d.Decode(base64.StdEncoding.EncodeToString([]byte("\x93smart quotes\x94 here")))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Value != "“smart quotes” here" {
+		t.Errorf("Expected: “smart quotes” here, Got: %v", result.Value)
+	}
+
+	if _, err := decoder.New(decoder.Options{Charset: "klingon"}); err == nil {
+		t.Errorf("Expected an error for an unknown charset")
+	}
+}
+
+func Test_Decoder_RandomTokens(t *testing.T) {
+	d, err := decoder.New(decoder.Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Random bytes carry no evidence of a legacy charset, so tokens that are
+	// not UTF-8 are left as they are
+	r := rand.New(rand.NewSource(1))
+	for _, size := range []int{12, 16, 24, 32} {
+		b := make([]byte, size)
+		for i := 0; i < 1000; i++ {
+			r.Read(b)
+			if utf8.Valid(b) {
+				continue
+			}
+
+			token := base64.StdEncoding.EncodeToString(b)
+			result, err := d.Decode(token)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Value != token {
+				t.Errorf("Expected %s to be left undecoded, Got: %q", token, result.Value)
+			}
+		}
+	}
+}
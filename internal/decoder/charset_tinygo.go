@@ -0,0 +1,12 @@
+//go:build tinygo
+
+package decoder
+
+import "golang.org/x/text/encoding"
+
+// lookupAlias resolves no aliases. Only the charsets this package detects
+// by itself can be forced, since the index of all charsets links the
+// Chinese and Korean tables into TinyGo binaries
+func lookupAlias(string) (encoding.Encoding, string, bool) {
+	return nil, "", false
+}
@@ -0,0 +1,124 @@
+package decoder
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+
+	errs "github.com/vitorhrmiranda/jbdecoder/internal/errors"
+)
+
+const (
+	dataURIScheme       = "data:"
+	defaultURIMediaType = "text/plain"
+)
+
+// ErrInvalidDataURI is returned when a string is not a well-formed data URI
+var ErrInvalidDataURI = errors.New("invalid data URI")
+
+// DataURI is a parsed RFC 2397 data URI
+type DataURI struct {
+	MediaType string
+	Params    map[string]string
+	Base64    bool
+	Data      []byte
+}
+
+// Charset returns the declared charset of the data, if any
+func (u DataURI) Charset() string {
+	return u.Params["charset"]
+}
+
+// IsJSON reports whether the media type declares JSON content
+func (u DataURI) IsJSON() bool {
+	return u.MediaType == "application/json" || strings.HasSuffix(u.MediaType, "+json")
+}
+
+// IsText reports whether the media type declares textual content
+func (u DataURI) IsText() bool {
+	return strings.HasPrefix(u.MediaType, "text/") || u.Charset() != ""
+}
+
+// ParseDataURI parses a data URI such as `data:application/json;base64,eyJ9`,
+// decoding its Base64 or percent-encoded payload
+func ParseDataURI(s string) (DataURI, error) {
+	if !strings.HasPrefix(s, dataURIScheme) {
+		return DataURI{}, ErrInvalidDataURI
+	}
+
+	header, payload, found := strings.Cut(s[len(dataURIScheme):], ",")
+	if !found {
+		return DataURI{}, ErrInvalidDataURI
+	}
+
+	uri := DataURI{Params: make(map[string]string)}
+	parts := strings.Split(header, ";")
+	uri.MediaType = strings.ToLower(strings.TrimSpace(parts[0]))
+
+	for _, part := range parts[1:] {
+		if part == "base64" {
+			uri.Base64 = true
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return DataURI{}, ErrInvalidDataURI
+		}
+		uri.Params[strings.ToLower(name)] = value
+	}
+
+	if uri.MediaType == "" {
+		uri.MediaType = defaultURIMediaType
+	}
+
+	var err error
+	if uri.Base64 {
+		uri.Data, err = base64.StdEncoding.DecodeString(payload)
+	} else {
+		var unescaped string
+		unescaped, err = url.PathUnescape(payload)
+		uri.Data = []byte(unescaped)
+	}
+	if err != nil {
+		return DataURI{}, errors.Join(ErrInvalidDataURI, err)
+	}
+
+	return uri, nil
+}
+
+// dataURI decodes a data URI string according to its declared media type
+// and charset, leaving non-textual payloads untouched
+func (w *walker) dataURI(s string) any {
+	uri, err := ParseDataURI(s)
+	if err != nil {
+		w.reject(CodecDataURI, errs.ErrInvalidEncoding, err)
+		return s
+	}
+
+	if !uri.IsJSON() && !uri.IsText() {
+		w.reject(CodecDataURI, errs.ErrUnsupportedMediaType, errors.New(uri.MediaType))
+		return s
+	}
+
+	if !w.allow(int64(len(uri.Data)), CodecDataURI) {
+		return s
+	}
+
+	charset := w.decoder.opts.Charset
+	if charset == "" {
+		charset = strings.ToLower(uri.Charset())
+	}
+
+	text, err := toUTF8(uri.Data, charset)
+	if err != nil {
+		w.reject(CodecDataURI, errs.ErrNotText, err)
+		return s
+	}
+
+	if charset == CharsetUTF8 || charset == "us-ascii" {
+		charset = ""
+	}
+
+	return w.content(text, CodecDataURI, charset)
+}
@@ -0,0 +1,69 @@
+package decoder_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+)
+
+func Test_ParseDataURI(t *testing.T) {
+	uri, err := decoder.ParseDataURI("data:text/plain;charset=iso-8859-1,caf%E9")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if uri.MediaType != "text/plain" || uri.Charset() != "iso-8859-1" || uri.Base64 {
+		t.Errorf("Unexpected data URI: %+v", uri)
+	}
+	if string(uri.Data) != "caf\xe9" {
+		t.Errorf("Expected: caf\\xe9, Got: %q", uri.Data)
+	}
+
+	if _, err := decoder.ParseDataURI("data:text/plain;base64"); !errors.Is(err, decoder.ErrInvalidDataURI) {
+		t.Errorf("Expected ErrInvalidDataURI, Got: %v", err)
+	}
+}
+
+func Test_DecodeBase64String_DataURI(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "JSON with nested base64",
+			input:    "data:application/json;base64,eyJ0b2tlbiI6ImV5SjFjMlZ5SWpvaWFtOW9iaUo5In0=",
+			expected: `{"token":{"user":"john"}}`,
+		},
+		{
+			name:     "vendor JSON media type",
+			input:    "data:application/vnd.api+json;base64,eyJhIjoxfQ==",
+			expected: `{"a":1}`,
+		},
+		{
+			name:     "latin-1 text",
+			input:    "data:text/plain;charset=iso-8859-1;base64,Y2Fm6Q==",
+			expected: `"café"`,
+		},
+		{
+			name:     "percent-encoded text",
+			input:    "data:,Hello%20World",
+			expected: `"Hello World"`,
+		},
+		{
+			name:     "binary media type is left untouched",
+			input:    "data:image/png;base64,iVBORw0KGgo=",
+			expected: `"data:image/png;base64,iVBORw0KGgo="`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual, _ := json.Marshal(decoder.DecodeBase64String(testCase.input))
+			if testCase.expected != string(actual) {
+				t.Errorf("Expected: %s, Got: %s", testCase.expected, actual)
+			}
+		})
+	}
+}
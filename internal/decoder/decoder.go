@@ -1,22 +1,346 @@
 package decoder
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
-	"encoding/json"
+	"fmt"
+	"slices"
 	"strings"
-	"unicode/utf8"
+
+	errs "github.com/vitorhrmiranda/jbdecoder/internal/errors"
 )
 
 const (
 	base64BlockSize = 4
 	validBase64Mod  = 0
-	minBase64Length = 16
 )
 
+// DefaultMaxDepth bounds how many encoding layers are decoded below each
+// other when Options.MaxDepth is not set
+const DefaultMaxDepth = 32
+
+// DefaultMinLength is the length below which strings are not taken for
+// Base64 when Options.MinLength is not set, since short words are often
+// valid Base64 by accident
+const DefaultMinLength = 16
+
+// Redacted replaces the values hidden by Options.Redact
+const Redacted = "[REDACTED]"
+
+// Codec names reported in annotations. Chains of codecs applied to a single
+// value are joined with codecSeparator, e.g. "base64|json"
+const (
+	CodecBase64    = "base64"
+	CodecDataURI   = "datauri"
+	CodecMIMEWord  = "mimeword"
+	CodecJSON      = "json"
+	codecSeparator = "|"
+)
+
+// codecs lists the codecs that Options.Codecs can enable
+var codecs = []string{CodecBase64, CodecDataURI, CodecMIMEWord, CodecJSON}
+
+// Options configures a Decoder
+type Options struct {
+	// Charset forces decoded bytes to be read in the given charset instead
+	// of detecting it, e.g. "windows-1252" or "shift_jis"
+	Charset string
+
+	// MaxDepth is the maximum number of encoding layers decoded below each
+	// other, e.g. Base64 JSON holding Base64 JSON is two layers deep.
+	// Zero means DefaultMaxDepth
+	MaxDepth int
+
+	// MaxFieldSize is the maximum size in bytes of a single decoded value.
+	// Zero means no limit
+	MaxFieldSize int64
+
+	// MaxOutputSize is the budget in bytes for all decoded values of a
+	// document together. Zero means no limit
+	MaxOutputSize int64
+
+	// Truncate makes exceeded limits leave the offending value undecoded
+	// and annotated instead of failing with a LimitError
+	Truncate bool
+
+	// Explain collects a diagnostic for every string that was not decoded,
+	// explaining which codec rejected it and why
+	Explain bool
+
+	// InPlace updates the maps and slices of the input document instead of
+	// building a decoded copy, saving allocations when the caller does not
+	// need the original anymore
+	InPlace bool
+
+	// Jobs is the number of goroutines decoding the elements of large
+	// arrays concurrently. Zero or one decodes sequentially. The results
+	// are identical either way; MaxOutputSize forces sequential decoding
+	// since the budget is consumed in document order
+	Jobs int
+
+	// BlobThreshold is the length in bytes above which Base64 strings are
+	// decoded chunk by chunk to a blob sink instead of into memory, and
+	// replaced by a Blob reporting their size and digest. Blobs are not
+	// parsed further and do not count against the size limits. Zero
+	// disables blobs
+	BlobThreshold int64
+
+	// BlobDir is the directory where blobs are stored, named after their
+	// SHA-256 digest. When empty blobs are only hashed
+	BlobDir string
+
+	// OnAnnotation and OnDiagnostic, when set, receive annotations and
+	// diagnostics as they are produced instead of collecting them in the
+	// Result, e.g. to report progress while streaming
+	OnAnnotation func(Annotation)
+	OnDiagnostic func(Diagnostic)
+
+	// Observer, when set, is told about every decoded and rejected value in
+	// addition to the annotations and diagnostics, e.g. to collect metrics
+	Observer Observer
+
+	// Codecs restricts decoding to the named codecs: CodecBase64,
+	// CodecDataURI, CodecMIMEWord and CodecJSON. Without CodecJSON decoded
+	// text is kept as a string. Empty enables all codecs
+	Codecs []string
+
+	// Include restricts decoding to strings at or below paths matching one
+	// of these patterns, e.g. "$.events[*].payload". Exclude leaves strings
+	// at or below matching paths undecoded and wins over Include. See
+	// ParsePattern for the syntax
+	Include []string
+	Exclude []string
+
+	// Redact replaces the values at paths matching one of these patterns
+	// with Redacted instead of decoding them, e.g. "$..password". Paths
+	// inside decoded content are matched too, so secrets nested in encoded
+	// payloads can be hidden
+	Redact []string
+
+	// MinLength is the length below which strings are not decoded as
+	// Base64. Zero means DefaultMinLength
+	MinLength int
+
+	// Rules declare the codec chains of values at known paths, which are
+	// decoded exactly so instead of guessing, failing with a DecodeError
+	// when the chain does not apply. The first matching rule wins. Rules
+	// are applied regardless of Codecs, Include and Exclude; values at
+	// other paths are still decoded by guessing
+	Rules []Rule
+
+	// RuleExclude keeps Rules from applying at or below paths matching one
+	// of these patterns, for callers taking rules from untrusted input that
+	// must not lift their exclusions
+	RuleExclude []string
+
+	// OnProgress, when set, is called periodically by DecodeContext and once
+	// decoding is complete, e.g. to update a progress bar. It is never
+	// called concurrently
+	OnProgress func(Progress)
+}
+
+// Progress reports how many values of the input document were decoded so
+// far, out of Total. Values found in decoded content are not counted
+type Progress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// checkpointInterval is the number of input values decoded between two
+// cancellation checks and progress reports
+const checkpointInterval = 1024
+
+// Observer is notified of decoding outcomes. It receives no paths, so it is
+// cheap enough to be used without Options.Explain. With Options.Jobs its
+// methods may be called concurrently
+type Observer interface {
+	// Decoded is called for every value decoded with codec
+	Decoded(codec string)
+
+	// Rejected is called for every value codec did not decode
+	Rejected(codec string, reason errs.RejectReason)
+}
+
+// Diagnostic explains why a codec did not decode the value at a JSON path
+type Diagnostic = errs.DecodeError
+
+// Annotation describes a value that was decoded and how. Values left
+// undecoded because of a limit carry the exceeded limit instead of a codec
+type Annotation struct {
+	Path    string `json:"path"`
+	Codec   string `json:"codec,omitempty"`
+	Charset string `json:"charset,omitempty"`
+	Limit   string `json:"limit,omitempty"`
+}
+
+// Result holds a decoded document along with annotations for every value
+// that was decoded and, with Options.Explain, diagnostics for every value
+// that was not, both in traversal order unless Options hooks receive them
+type Result struct {
+	Value       any
+	Annotations []Annotation
+	Diagnostics []Diagnostic
+}
+
+// Decoder decodes Base64 and related encodings found in JSON data
+type Decoder struct {
+	opts Options
+
+	// codecs is the set of enabled codecs, nil when all are enabled
+	codecs map[string]bool
+
+	include []Pattern
+	exclude []Pattern
+	redact  []Pattern
+	rules   []rule
+
+	// ruleExclude holds the patterns of Options.RuleExclude
+	ruleExclude []Pattern
+}
+
+// defaultDecoder backs the package-level helpers, which never fail and
+// therefore truncate instead
+var defaultDecoder = &Decoder{opts: Options{MaxDepth: DefaultMaxDepth, MinLength: DefaultMinLength, Truncate: true}}
+
+// New creates a Decoder with the given options
+func New(opts Options) (*Decoder, error) {
+	if opts.Charset != "" {
+		_, name, err := lookupCharset(opts.Charset)
+		if err != nil {
+			return nil, err
+		}
+		opts.Charset = name
+	}
+
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = DefaultMaxDepth
+	}
+	if opts.MinLength <= 0 {
+		opts.MinLength = DefaultMinLength
+	}
+
+	d := &Decoder{opts: opts}
+	for _, codec := range opts.Codecs {
+		if !slices.Contains(codecs, codec) {
+			return nil, fmt.Errorf("unsupported codec %q, expected one of %s", codec, strings.Join(codecs, ", "))
+		}
+		if d.codecs == nil {
+			d.codecs = make(map[string]bool, len(codecs))
+		}
+		d.codecs[codec] = true
+	}
+
+	var err error
+	if d.include, err = parsePatterns(opts.Include); err != nil {
+		return nil, err
+	}
+	if d.exclude, err = parsePatterns(opts.Exclude); err != nil {
+		return nil, err
+	}
+	if d.redact, err = parsePatterns(opts.Redact); err != nil {
+		return nil, err
+	}
+	if d.rules, err = parseRules(opts.Rules); err != nil {
+		return nil, err
+	}
+	if d.ruleExclude, err = parsePatterns(opts.RuleExclude); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// enabled reports whether codec may be used
+func (d *Decoder) enabled(codec string) bool {
+	return d.codecs == nil || d.codecs[codec]
+}
+
+// Decode recursively traverses JSON data and decodes encoded strings. When a
+// limit is exceeded it returns a LimitError unless Options.Truncate is set
+func (d *Decoder) Decode(data any) (Result, error) {
+	return d.DecodeContext(context.Background(), data)
+}
+
+// DecodeContext decodes like Decode, stopping with the context error once
+// ctx is done
+func (d *Decoder) DecodeContext(ctx context.Context, data any) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	w := d.walker()
+	w.ctx = ctx
+	w.checkpoints = ctx.Done() != nil || d.opts.OnProgress != nil
+	if d.opts.OnProgress != nil {
+		w.total = countValues(data)
+	}
+
+	value := w.value(data)
+	if w.err != nil {
+		return Result{}, w.err
+	}
+
+	if d.opts.OnProgress != nil {
+		d.opts.OnProgress(Progress{Done: w.visited, Total: w.total})
+	}
+	return Result{Value: value, Annotations: w.annotations, Diagnostics: w.diagnostics}, nil
+}
+
+// DecodeAt decodes a value taken from the given JSONPath of a larger
+// document, such as a string under the cursor of an editor, so that
+// Options.Include, Options.Exclude, Options.Redact and Options.Rules apply
+// to it as they would when decoding the whole document. The path must not
+// hold wildcards, and it prefixes the paths of annotations and errors
+func (d *Decoder) DecodeAt(jsonPath string, data any) (Result, error) {
+	at, err := parsePath(jsonPath)
+	if err != nil {
+		return Result{}, err
+	}
+
+	w := d.walker()
+	w.ctx = context.Background()
+	w.path = at
+	value := w.value(data)
+	if w.err != nil {
+		return Result{}, w.err
+	}
+	return Result{Value: value, Annotations: w.annotations, Diagnostics: w.diagnostics}, nil
+}
+
+// countValues counts the values of a document, containers included
+func countValues(data any) int {
+	count := 1
+	switch v := data.(type) {
+	case map[string]any:
+		for _, value := range v {
+			count += countValues(value)
+		}
+	case []any:
+		for _, value := range v {
+			count += countValues(value)
+		}
+	}
+	return count
+}
+
+// Explain decodes data like Decode while collecting diagnostics, regardless
+// of Options.Explain
+func (d *Decoder) Explain(data any) (Result, error) {
+	explaining := *d
+	explaining.opts.Explain = true
+	return explaining.Decode(data)
+}
+
+// walker creates the state for a single decoding pass
+func (d *Decoder) walker() *walker {
+	return &walker{decoder: d, budget: d.opts.MaxOutputSize, inPlace: d.opts.InPlace}
+}
+
 // IsBase64 checks if a string is valid Base64 encoded
 func IsBase64(s string) bool {
 	// Base64 strings should be reasonably long to avoid false positives
-	if len(s) < minBase64Length {
+	if len(s) < DefaultMinLength {
 		return false
 	}
 
@@ -30,72 +354,400 @@ func IsBase64(s string) bool {
 	return err == nil
 }
 
+// base64Rejection explains why a string shorter than minLength or not
+// valid Base64 is rejected
+func base64Rejection(s string, minLength int) (errs.RejectReason, error) {
+	switch {
+	case len(s) < minLength:
+		return errs.ErrTooShort, nil
+	case len(s)%base64BlockSize != validBase64Mod:
+		return errs.ErrBadLength, nil
+	}
+
+	_, err := base64.StdEncoding.DecodeString(s)
+	return errs.ErrInvalidEncoding, err
+}
+
 // IsValidJSON checks if a string is valid JSON
 func IsValidJSON(s string) bool {
-	var temp any
-	return json.Unmarshal([]byte(s), &temp) == nil
+	_, err := unmarshalJSON([]byte(s))
+	return err == nil
 }
 
-// DecodeBase64String attempts decode a Base64 string and parse as JSON if valid
+// DecodeBase64String attempts decode a Base64 string and parse as JSON if valid.
+// Data URIs and MIME encoded-words are recognized and decoded as well
 func DecodeBase64String(s string) any {
-	if !IsBase64(s) {
-		return s
-	}
+	return defaultDecoder.walker().value(s)
+}
 
-	decoded, err := base64.StdEncoding.DecodeString(s)
-	if err != nil {
-		return s
-	}
+// DecodeBase64Fields recursively traverses JSON data and decodes Base64 strings
+func DecodeBase64Fields(data any) any {
+	return defaultDecoder.walker().value(data)
+}
 
-	// Check if the decoded data is valid UTF-8 text
-	if !utf8.Valid(decoded) {
-		// If it's not valid UTF-8, return the original Base64 string unchanged
-		return s
+// DecodeBase64InMap processes all values in a map
+func DecodeBase64InMap(m map[string]any) map[string]any {
+	return defaultDecoder.walker().object(m)
+}
+
+// DecodeBase64InSlice processes all values in a slice
+func DecodeBase64InSlice(s []any) []any {
+	return defaultDecoder.walker().slice(s)
+}
+
+// walker carries the state of a single Decode call
+type walker struct {
+	decoder     *Decoder
+	annotations []Annotation
+	diagnostics []Diagnostic
+
+	// depth is the number of encoding layers above the current value
+	depth int
+
+	// budget is the remaining output budget when MaxOutputSize is set
+	budget int64
+
+	// err is the first limit error, which stops any further decoding
+	err error
+
+	// src and dst are scratch buffers reused across Base64 candidates
+	src []byte
+	dst []byte
+
+	// keys is a stack of sorted map keys shared by nested objects
+	keys []string
+
+	// inPlace is set while walking containers that may be updated directly
+	inPlace bool
+
+	// path is the location of the value being decoded
+	path path
+
+	// streaming drops annotations and diagnostics without a hook, so memory
+	// use does not grow with the document
+	streaming bool
+
+	// worker is set for walkers of the array worker pool
+	worker bool
+
+	// ctx stops decoding when done. It is only checked, along with
+	// progress being reported, every checkpointInterval input values when
+	// checkpoints is set
+	ctx         context.Context
+	checkpoints bool
+
+	// visited counts the input values decoded so far, out of total
+	visited int
+	total   int
+}
+
+// value decodes a JSON value of any type found at the current path
+func (w *walker) value(data any) any {
+	if w.err != nil {
+		return data
 	}
 
-	decodedStr := strings.TrimSpace(string(decoded))
+	if w.depth == 0 {
+		w.visited++
+		if w.checkpoints && w.visited%checkpointInterval == 0 {
+			if w.checkpoint(); w.err != nil {
+				return data
+			}
+		}
+	}
 
-	// Check if the decoded string is valid JSON
-	if IsValidJSON(decodedStr) {
-		var jsonObj any
-		if err := json.Unmarshal([]byte(decodedStr), &jsonObj); err == nil {
-			// Recursively process the parsed JSON to decode any nested Base64
-			return DecodeBase64Fields(jsonObj)
+	if len(w.decoder.redact) > 0 && w.redacted() {
+		if w.depth == 0 && w.total > 0 {
+			// The values nested in a redacted one count as done too
+			w.visited += countValues(data) - 1
 		}
+		return Redacted
 	}
 
-	return decodedStr
-}
+	if len(w.decoder.rules) > 0 {
+		if r := w.rule(); r != nil {
+			return w.applyRule(r, data)
+		}
+	}
 
-// DecodeBase64Fields recursively traverses JSON data and decodes Base64 strings
-func DecodeBase64Fields(data any) any {
 	switch v := data.(type) {
 	case map[string]any:
-		return DecodeBase64InMap(v)
+		return w.object(v)
 	case []any:
-		return DecodeBase64InSlice(v)
+		return w.slice(v)
 	case string:
-		return DecodeBase64String(v)
+		return w.str(v)
 	default:
 		// For other types (numbers, booleans, null), return as-is
 		return v
 	}
 }
 
-// DecodeBase64InMap processes all values in a map
-func DecodeBase64InMap(m map[string]any) map[string]any {
-	result := make(map[string]any)
-	for key, value := range m {
-		result[key] = DecodeBase64Fields(value)
+// checkpoint stops decoding when the context is done and reports progress
+func (w *walker) checkpoint() {
+	if err := w.ctx.Err(); err != nil {
+		w.err = err
+		return
 	}
+	if hook := w.decoder.opts.OnProgress; hook != nil {
+		hook(Progress{Done: w.visited, Total: w.total})
+	}
+}
+
+// object processes all values in a map, in key order so that annotations
+// and diagnostics come out in a stable order. With Options.InPlace the map
+// itself is updated instead of a copy
+func (w *walker) object(m map[string]any) map[string]any {
+	result := m
+	if !w.inPlace {
+		result = make(map[string]any, len(m))
+	}
+
+	// Keys are pushed on the shared stack and popped once done, so nested
+	// objects reuse the same backing array
+	start := len(w.keys)
+	for key := range m {
+		w.keys = append(w.keys, key)
+	}
+	slices.Sort(w.keys[start:])
+
+	for i := start; i < len(w.keys); i++ {
+		key := w.keys[i]
+		w.path = append(w.path, segment{name: key})
+		result[key] = w.value(m[key])
+		w.path = w.path[:len(w.path)-1]
+	}
+
+	w.keys = w.keys[:start]
 	return result
 }
 
-// DecodeBase64InSlice processes all values in a slice
-func DecodeBase64InSlice(s []any) []any {
-	result := make([]any, len(s))
+// slice processes all values in a slice, large ones concurrently with
+// Options.Jobs. With Options.InPlace the slice itself is updated instead of
+// a copy
+func (w *walker) slice(s []any) []any {
+	result := s
+	if !w.inPlace {
+		result = make([]any, len(s))
+	}
+
+	if w.parallel(s) {
+		w.sliceParallel(s, result)
+		return result
+	}
+
 	for i, value := range s {
-		result[i] = DecodeBase64Fields(value)
+		w.path = append(w.path, segment{offset: i, isIndex: true})
+		result[i] = w.value(value)
+		w.path = w.path[:len(w.path)-1]
 	}
 	return result
 }
+
+// str attempts to decode a string as a data URI, MIME encoded-words or
+// Base64, returning it unchanged when none applies
+func (w *walker) str(s string) any {
+	if !w.selected() {
+		return s
+	}
+
+	switch {
+	case strings.HasPrefix(s, dataURIScheme) && w.decoder.enabled(CodecDataURI):
+		return w.dataURI(s)
+	case strings.Contains(s, encodedWordPrefix) && w.decoder.enabled(CodecMIMEWord):
+		return w.encodedWords(s)
+	case !w.decoder.enabled(CodecBase64):
+		return s
+	case w.decoder.opts.BlobThreshold > 0 && int64(len(s)) > w.decoder.opts.BlobThreshold:
+		return w.blob(s)
+	}
+
+	minLength := w.decoder.opts.MinLength
+	if len(s) < minLength || len(s)%base64BlockSize != validBase64Mod {
+		if w.decoder.opts.Explain || w.decoder.opts.Observer != nil {
+			reason, err := base64Rejection(s, minLength)
+			w.reject(CodecBase64, reason, err)
+		}
+		return s
+	}
+
+	// Decode once into the scratch buffer instead of validating with
+	// IsBase64 and decoding again. Both steps are bounded by the size of
+	// the input, so limits are only checked once the string turned out to
+	// be Base64 text, and plain strings never exceed them
+	w.src = append(w.src[:0], s...)
+	w.dst = slices.Grow(w.dst[:0], base64.StdEncoding.DecodedLen(len(s)))
+	n, err := base64.StdEncoding.Decode(w.dst[:cap(w.dst)], w.src)
+	if err != nil {
+		w.reject(CodecBase64, errs.ErrInvalidEncoding, err)
+		return s
+	}
+
+	text, charset, err := transcode(w.dst[:n], w.decoder.opts.Charset)
+	if err != nil {
+		// If it's not text in any known charset, return the original Base64 string unchanged
+		w.reject(CodecBase64, errs.ErrNotText, err)
+		return s
+	}
+
+	if !w.allow(int64(len(text)), CodecBase64) {
+		return s
+	}
+
+	return w.content(text, CodecBase64, charset)
+}
+
+// selected reports whether the current path passes Options.Include and
+// Options.Exclude
+func (w *walker) selected() bool {
+	d := w.decoder
+	if len(d.include) == 0 && len(d.exclude) == 0 {
+		return true
+	}
+
+	for _, p := range d.exclude {
+		if p.covers(w.path) {
+			return false
+		}
+	}
+	if len(d.include) == 0 {
+		return true
+	}
+	for _, p := range d.include {
+		if p.covers(w.path) {
+			return true
+		}
+	}
+	return false
+}
+
+// redacted reports whether Options.Redact hides the value at the current
+// path
+func (w *walker) redacted() bool {
+	for _, p := range w.decoder.redact {
+		if p.covers(w.path) {
+			return true
+		}
+	}
+	return false
+}
+
+// content interprets decoded UTF-8 text, parsing it as JSON when possible,
+// and records how the value at the current path was decoded. The text may live in a
+// scratch buffer, so it is copied before being kept
+func (w *walker) content(text []byte, codec, charset string) any {
+	trimmed := bytes.TrimSpace(text)
+
+	// Parse the decoded text as JSON in a single pass, skipping texts that
+	// cannot start a JSON value at all
+	if w.decoder.enabled(CodecJSON) && startsJSONValue(trimmed) {
+		jsonObj, err := unmarshalJSON(trimmed)
+		if err == nil {
+			w.annotate(codec+codecSeparator+CodecJSON, charset)
+			// Recursively process the parsed JSON to decode any nested Base64,
+			// one encoding layer deeper. Freshly parsed values are never
+			// shared, so they can always be updated in place
+			inPlace := w.inPlace
+			w.inPlace = true
+			w.depth++
+			value := w.value(jsonObj)
+			w.depth--
+			w.inPlace = inPlace
+			return value
+		}
+		if looksLikeJSON(trimmed) {
+			w.reject(CodecJSON, errs.ErrInvalidJSON, err)
+		}
+	}
+
+	w.annotate(codec, charset)
+	return string(trimmed)
+}
+
+// startsJSONValue reports whether text begins with a character that can
+// start a JSON value
+func startsJSONValue(text []byte) bool {
+	if len(text) == 0 {
+		return false
+	}
+	return strings.IndexByte(`{["-0123456789tfn`, text[0]) >= 0
+}
+
+// looksLikeJSON reports whether text starts like a JSON object or array, so
+// that failing to parse it is worth a diagnostic
+func looksLikeJSON(text []byte) bool {
+	return bytes.HasPrefix(text, []byte("{")) || bytes.HasPrefix(text, []byte("["))
+}
+
+// allow checks the depth, field size and output budget limits before a value
+// of the given decoded size is decoded with codec at the current path. When
+// a limit is exceeded it either records the error or, in truncate mode,
+// annotates the value that is left undecoded
+func (w *walker) allow(size int64, codec string) bool {
+	opts := w.decoder.opts
+
+	var limit errs.LimitError
+	switch {
+	case w.depth >= opts.MaxDepth:
+		limit = errs.NewLimitError(errs.LimitDepth, int64(opts.MaxDepth))
+	case opts.MaxFieldSize > 0 && size > opts.MaxFieldSize:
+		limit = errs.NewLimitError(errs.LimitFieldSize, opts.MaxFieldSize)
+	case opts.MaxOutputSize > 0 && size > w.budget:
+		limit = errs.NewLimitError(errs.LimitOutputSize, opts.MaxOutputSize)
+	default:
+		w.budget -= size
+		return true
+	}
+
+	if opts.Truncate {
+		w.record(Annotation{Path: w.path.String(), Limit: limit.Error()})
+		w.reject(codec, errs.ErrLimitExceeded, limit)
+	} else {
+		w.err = limit.At(w.path.String())
+	}
+	return false
+}
+
+// reject records a diagnostic explaining why codec did not decode the value
+// at the current path, when diagnostics are enabled
+func (w *walker) reject(codec string, reason errs.RejectReason, err error) {
+	if observer := w.decoder.opts.Observer; observer != nil {
+		observer.Rejected(codec, reason)
+	}
+	if !w.decoder.opts.Explain {
+		return
+	}
+	w.report(errs.NewDecodeError(w.path.String(), codec, reason, err))
+}
+
+// report hands a diagnostic to the hook or collects it for the Result
+func (w *walker) report(diagnostic Diagnostic) {
+	switch {
+	case w.decoder.opts.OnDiagnostic != nil:
+		w.decoder.opts.OnDiagnostic(diagnostic)
+	case !w.streaming:
+		w.diagnostics = append(w.diagnostics, diagnostic)
+	}
+}
+
+// annotate records that the value at the current path was decoded with codec
+func (w *walker) annotate(codec, charset string) {
+	if observer := w.decoder.opts.Observer; observer != nil {
+		observer.Decoded(codec)
+	}
+	w.record(Annotation{
+		Path:    w.path.String(),
+		Codec:   codec,
+		Charset: charset,
+	})
+}
+
+// record hands an annotation to the hook or collects it for the Result
+func (w *walker) record(annotation Annotation) {
+	switch {
+	case w.decoder.opts.OnAnnotation != nil:
+		w.decoder.opts.OnAnnotation(annotation)
+	case !w.streaming:
+		w.annotations = append(w.annotations, annotation)
+	}
+}
@@ -1,10 +1,16 @@
 package decoder_test
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"reflect"
+	"runtime"
 	"testing"
 
 	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+	errs "github.com/vitorhrmiranda/jbdecoder/internal/errors"
 )
 
 const example = `{
@@ -23,3 +29,399 @@ func Test_DecodeBase64Fields(t *testing.T) {
 		t.Errorf("Expected: %s, Got: %s", expected, decoded)
 	}
 }
+
+// nestedBase64 wraps value in the given number of Base64 JSON layers
+func nestedBase64(value string, layers int) string {
+	for range layers {
+		encoded, _ := json.Marshal(map[string]string{"next": value})
+		value = base64.StdEncoding.EncodeToString(encoded)
+	}
+	return value
+}
+
+func Test_Decoder_Limits(t *testing.T) {
+	const secret = "a secret value that is long enough"
+
+	testCases := []struct {
+		name     string
+		options  decoder.Options
+		data     any
+		expected error
+	}{
+		{
+			name:     "max depth",
+			options:  decoder.Options{MaxDepth: 3},
+			data:     map[string]any{"payload": nestedBase64(secret, 5)},
+			expected: errs.ErrMaxDepth,
+		},
+		{
+			name:     "max field size",
+			options:  decoder.Options{MaxFieldSize: 8},
+			data:     map[string]any{"payload": nestedBase64(secret, 1)},
+			expected: errs.ErrFieldTooLarge,
+		},
+		{
+			name:     "max output size",
+			options:  decoder.Options{MaxOutputSize: 64},
+			data:     []any{nestedBase64(secret, 1), nestedBase64(secret, 1)},
+			expected: errs.ErrOutputTooLarge,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			d, err := decoder.New(testCase.options)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if _, err := d.Decode(testCase.data); !errors.Is(err, testCase.expected) {
+				t.Errorf("Expected: %v, Got: %v", testCase.expected, err)
+			}
+
+			testCase.options.Truncate = true
+			d, _ = decoder.New(testCase.options)
+			result, err := d.Decode(testCase.data)
+			if err != nil {
+				t.Fatalf("Unexpected error in truncate mode: %v", err)
+			}
+
+			last := result.Annotations[len(result.Annotations)-1]
+			if last.Limit == "" {
+				t.Errorf("Expected the last annotation to mark a limit, Got: %+v", result.Annotations)
+			}
+		})
+	}
+}
+
+func Test_Decoder_Limits_PlainText(t *testing.T) {
+	// Strings that are not Base64 text never count against the limits, even
+	// when their length and alphabet could be Base64
+	plain := map[string]any{
+		"note": "just a plain note!!!",
+		"id":   "0123456789abcdef0123456789abcdef",
+		"tags": []any{"plain string 001", "plain string 002", "plain string 003"},
+	}
+	nested, _ := json.Marshal(plain)
+
+	testCases := []struct {
+		name    string
+		options decoder.Options
+		data    any
+	}{
+		{
+			name:    "max depth",
+			options: decoder.Options{MaxDepth: 1},
+			data:    map[string]any{"p": base64.StdEncoding.EncodeToString(nested)},
+		},
+		{
+			name:    "max field size",
+			options: decoder.Options{MaxFieldSize: 8},
+			data:    plain,
+		},
+		{
+			name:    "max output size",
+			options: decoder.Options{MaxOutputSize: 30},
+			data:    plain,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			d, err := decoder.New(testCase.options)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			result, err := d.Decode(testCase.data)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for _, annotation := range result.Annotations {
+				if annotation.Limit != "" {
+					t.Errorf("Expected no limit, Got: %+v", annotation)
+				}
+			}
+		})
+	}
+}
+
+func Test_DecodeBase64Fields_DeepNesting(t *testing.T) {
+	data := map[string]any{"payload": nestedBase64("innermost value here", decoder.DefaultMaxDepth+1)}
+
+	// The package-level helpers stop at the default depth instead of failing
+	decoded := decoder.DecodeBase64Fields(data).(map[string]any)["payload"]
+
+	layers := 0
+	for next, ok := decoded.(map[string]any); ok; next, ok = decoded.(map[string]any) {
+		decoded = next["next"]
+		layers++
+	}
+
+	if layers != decoder.DefaultMaxDepth {
+		t.Errorf("Expected %d decoded layers, Got: %d", decoder.DefaultMaxDepth, layers)
+	}
+	if decoded == "innermost value here" {
+		t.Errorf("Expected the innermost layer to remain encoded")
+	}
+}
+
+func Test_Decoder_Explain(t *testing.T) {
+	data := map[string]any{
+		"short":   "abc",
+		"length":  "SGVsbG8gV29ybGQgd29ybGQ",
+		"invalid": "SGVs!G8gV29ybGQgd29ybGQ=",
+		"binary":  base64.StdEncoding.EncodeToString([]byte{0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a, 0x00, 0x00, 0x00, 0x0d}),
+		"json":    base64.StdEncoding.EncodeToString([]byte(`{"broken": }`)),
+		"image":   "data:image/png;base64,iVBORw0KGgo=",
+		"decoded": "SGVsbG8gV29ybGQgd29ybGQ=",
+	}
+
+	d, _ := decoder.New(decoder.Options{})
+	result, err := d.Explain(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]error{
+		"$.short":   errs.ErrTooShort,
+		"$.length":  errs.ErrBadLength,
+		"$.invalid": errs.ErrInvalidEncoding,
+		"$.binary":  errs.ErrNotText,
+		"$.json":    errs.ErrInvalidJSON,
+		"$.image":   errs.ErrUnsupportedMediaType,
+	}
+
+	if len(result.Diagnostics) != len(expected) {
+		t.Fatalf("Expected %d diagnostics, Got: %v", len(expected), result.Diagnostics)
+	}
+	for _, diagnostic := range result.Diagnostics {
+		if !errors.Is(diagnostic, expected[diagnostic.Path]) {
+			t.Errorf("Expected %s to be rejected with %v, Got: %v", diagnostic.Path, expected[diagnostic.Path], diagnostic)
+		}
+	}
+
+	var syntaxErr *json.SyntaxError
+	for _, diagnostic := range result.Diagnostics {
+		if diagnostic.Path == "$.json" && !errors.As(diagnostic, &syntaxErr) {
+			t.Errorf("Expected the JSON diagnostic to wrap the syntax error, Got: %v", diagnostic)
+		}
+	}
+
+	if result, _ := d.Decode(data); result.Diagnostics != nil {
+		t.Errorf("Expected no diagnostics without Explain, Got: %v", result.Diagnostics)
+	}
+}
+
+// countingObserver counts decoded and rejected values by codec and reason
+type countingObserver map[string]int
+
+func (o countingObserver) Decoded(codec string) {
+	o[codec]++
+}
+
+func (o countingObserver) Rejected(codec string, reason errs.RejectReason) {
+	o[codec+" "+reason.Code()]++
+}
+
+func Test_Decoder_Observer(t *testing.T) {
+	observer := countingObserver{}
+	d, _ := decoder.New(decoder.Options{Observer: observer})
+
+	result, err := d.Decode(map[string]any{
+		"short":  "abc",
+		"json":   "eyJ1c2VyIjoiam9obiJ9",
+		"text":   "SGVsbG8gV29ybGQgd29ybGQ=",
+		"broken": "SGVs!G8gV29ybGQgd29ybGQ=",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := countingObserver{
+		"base64|json":             1,
+		"base64":                  1,
+		"base64 too_short":        2,
+		"base64 invalid_encoding": 1,
+	}
+	if !reflect.DeepEqual(observer, expected) {
+		t.Errorf("Expected: %v, Got: %v", expected, observer)
+	}
+	if result.Diagnostics != nil {
+		t.Errorf("Expected no diagnostics without Explain, Got: %v", result.Diagnostics)
+	}
+}
+
+func Test_Decoder_Codecs(t *testing.T) {
+	data := map[string]any{
+		"json":  "eyJ1c2VyIjoiam9obiJ9",
+		"text":  "SGVsbG8gV29ybGQgd29ybGQ=",
+		"uri":   "data:text/plain;base64,SGVsbG8=",
+		"words": "=?UTF-8?B?SGVsbG8=?=",
+	}
+
+	testCases := []struct {
+		name     string
+		codecs   []string
+		expected string
+	}{
+		{
+			name:     "all",
+			expected: `{"json":{"user":"john"},"text":"Hello World world","uri":"Hello","words":"Hello"}`,
+		},
+		{
+			name:     "base64 without json",
+			codecs:   []string{decoder.CodecBase64},
+			expected: `{"json":"{\"user\":\"john\"}","text":"Hello World world","uri":"data:text/plain;base64,SGVsbG8=","words":"=?UTF-8?B?SGVsbG8=?="}`,
+		},
+		{
+			name:     "data uri and mime words",
+			codecs:   []string{decoder.CodecDataURI, decoder.CodecMIMEWord},
+			expected: `{"json":"eyJ1c2VyIjoiam9obiJ9","text":"SGVsbG8gV29ybGQgd29ybGQ=","uri":"Hello","words":"Hello"}`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			d, err := decoder.New(decoder.Options{Codecs: testCase.codecs})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			result, err := d.Decode(data)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			actual, _ := json.Marshal(result.Value)
+			if string(actual) != testCase.expected {
+				t.Errorf("Expected: %s, Got: %s", testCase.expected, actual)
+			}
+		})
+	}
+
+	if _, err := decoder.New(decoder.Options{Codecs: []string{"rot13"}}); err == nil {
+		t.Errorf("Expected an unsupported codec to be rejected")
+	}
+}
+
+func Test_Decoder_InPlace(t *testing.T) {
+	newData := func() map[string]any {
+		var data map[string]any
+		_ = json.Unmarshal([]byte(example), &data)
+		return data
+	}
+
+	original := newData()
+	d, _ := decoder.New(decoder.Options{})
+	if _, err := d.Decode(original); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := original["data"].(string); !ok {
+		t.Errorf("Expected the input to be left untouched, Got: %v", original)
+	}
+
+	shared := newData()
+	d, _ = decoder.New(decoder.Options{InPlace: true})
+	result, err := d.Decode(shared)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := shared["data"].(map[string]any); !ok {
+		t.Errorf("Expected the input to be updated in place, Got: %v", shared)
+	}
+
+	expected, _ := json.Marshal(decoder.DecodeBase64Fields(newData()))
+	actual, _ := json.Marshal(result.Value)
+	if string(expected) != string(actual) {
+		t.Errorf("Expected: %s, Got: %s", expected, actual)
+	}
+}
+
+func Test_Decoder_DecodeContext(t *testing.T) {
+	data := benchmarkDocument(500)
+
+	for _, jobs := range []int{0, 4} {
+		var reports []decoder.Progress
+		d, _ := decoder.New(decoder.Options{Jobs: jobs, OnProgress: func(p decoder.Progress) {
+			reports = append(reports, p)
+		}})
+
+		if _, err := d.DecodeContext(t.Context(), data); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(reports) < 2 {
+			t.Fatalf("Expected intermediate progress reports, Got: %v", reports)
+		}
+		for i := 1; i < len(reports); i++ {
+			if reports[i].Done < reports[i-1].Done {
+				t.Errorf("Expected progress to increase, Got: %v", reports)
+			}
+		}
+		if last := reports[len(reports)-1]; last.Done != last.Total || last.Total != 500*10+1 {
+			t.Errorf("Expected the last report to be complete, Got: %+v", last)
+		}
+
+		ctx, cancel := context.WithCancel(t.Context())
+		d, _ = decoder.New(decoder.Options{Jobs: jobs, OnProgress: func(p decoder.Progress) {
+			if p.Done < p.Total {
+				cancel()
+			}
+		}})
+		if jobs > 1 {
+			// Workers report progress once merged, so cancel upfront
+			cancel()
+		}
+		if _, err := d.DecodeContext(ctx, data); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected decoding to be cancelled with %d jobs, Got: %v", jobs, err)
+		}
+	}
+}
+
+// benchmarkDocument builds an audit-export-like document of n records
+// mixing Base64 JSON, Base64 text and plain fields
+func benchmarkDocument(n int) []any {
+	records := make([]any, n)
+	for i := range records {
+		payload, _ := json.Marshal(map[string]any{
+			"user":   "user-" + string(rune('a'+i%26)),
+			"action": "login",
+			"token":  base64.StdEncoding.EncodeToString([]byte("session token for the record")),
+		})
+		records[i] = map[string]any{
+			"id":      float64(i),
+			"type":    "audit.event",
+			"source":  "not base64 at all",
+			"payload": base64.StdEncoding.EncodeToString(payload),
+			"note":    base64.StdEncoding.EncodeToString([]byte("a plain text note that was encoded")),
+			"tags":    []any{"alpha", "beta", "gamma"},
+		}
+	}
+	return records
+}
+
+func Benchmark_Decode(b *testing.B) {
+	input, _ := json.Marshal(benchmarkDocument(1000))
+
+	benchmarks := []struct {
+		name    string
+		options decoder.Options
+	}{
+		{name: "copy", options: decoder.Options{}},
+		{name: "in-place", options: decoder.Options{InPlace: true}},
+		{name: "in-place-jobs", options: decoder.Options{InPlace: true, Jobs: runtime.GOMAXPROCS(0)}},
+	}
+
+	for _, benchmark := range benchmarks {
+		b.Run(benchmark.name, func(b *testing.B) {
+			d, _ := decoder.New(benchmark.options)
+			b.SetBytes(int64(len(input)))
+			b.ReportAllocs()
+			for b.Loop() {
+				var data any
+				_ = json.Unmarshal(input, &data)
+				_, _ = d.Decode(data)
+			}
+		})
+	}
+}
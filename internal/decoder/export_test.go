@@ -0,0 +1,11 @@
+package decoder
+
+import "github.com/vitorhrmiranda/jbdecoder/internal/jsonlite"
+
+// UseJSONLite makes the package read and write JSON with jsonlite like
+// TinyGo builds do, returning a function that restores encoding/json
+func UseJSONLite() (restore func()) {
+	unmarshal, marshal := unmarshalJSON, marshalJSON
+	unmarshalJSON, marshalJSON = jsonlite.Parse, jsonlite.Marshal
+	return func() { unmarshalJSON, marshalJSON = unmarshal, marshal }
+}
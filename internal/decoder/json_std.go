@@ -0,0 +1,17 @@
+//go:build !tinygo
+
+package decoder
+
+import "encoding/json"
+
+// unmarshalJSON and marshalJSON read and write the JSON held in decoded
+// values. TinyGo builds use the reflection-free jsonlite instead, see
+// json_tinygo.go; both produce the same values and bytes
+var (
+	unmarshalJSON = func(data []byte) (any, error) {
+		var v any
+		err := json.Unmarshal(data, &v)
+		return v, err
+	}
+	marshalJSON = json.Marshal
+)
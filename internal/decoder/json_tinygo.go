@@ -0,0 +1,13 @@
+//go:build tinygo
+
+package decoder
+
+import "github.com/vitorhrmiranda/jbdecoder/internal/jsonlite"
+
+// unmarshalJSON and marshalJSON read and write the JSON held in decoded
+// values without encoding/json, whose reflection makes TinyGo binaries
+// several times larger
+var (
+	unmarshalJSON = jsonlite.Parse
+	marshalJSON   = jsonlite.Marshal
+)
@@ -0,0 +1,100 @@
+//go:build !tinygo
+
+package decoder
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// Span is the byte range of a value in a JSON document, from the first byte
+// of the value up to and excluding End
+type Span struct {
+	Start int64
+	End   int64
+}
+
+// locateFrame is an open container while locating values
+type locateFrame struct {
+	object bool
+	start  int64
+	count  int
+}
+
+// Locate maps the JSONPath of every value of a JSON document, as reported
+// in annotations and diagnostics, to its span in data
+func Locate(data []byte) (map[string]Span, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	spans := make(map[string]Span)
+	var at path
+	var stack []locateFrame
+	expectKey := false
+
+	for {
+		start := skipSeparators(data, dec.InputOffset())
+		token, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			if len(stack) > 0 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return spans, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		end := dec.InputOffset()
+
+		if delim, ok := token.(json.Delim); ok && (delim == '}' || delim == ']') {
+			frame := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			spans[at.String()] = Span{Start: frame.start, End: end}
+			if len(stack) > 0 {
+				at = at[:len(at)-1]
+			}
+			expectKey = len(stack) > 0 && stack[len(stack)-1].object
+			continue
+		}
+
+		if expectKey {
+			at = append(at, segment{name: token.(string)})
+			expectKey = false
+			continue
+		}
+
+		if len(stack) > 0 && !stack[len(stack)-1].object {
+			frame := &stack[len(stack)-1]
+			at = append(at, segment{offset: frame.count, isIndex: true})
+			frame.count++
+		}
+
+		if delim, ok := token.(json.Delim); ok {
+			stack = append(stack, locateFrame{object: delim == '{', start: start})
+			expectKey = delim == '{'
+			continue
+		}
+
+		spans[at.String()] = Span{Start: start, End: end}
+		if len(stack) > 0 {
+			at = at[:len(at)-1]
+		}
+		expectKey = len(stack) > 0 && stack[len(stack)-1].object
+	}
+}
+
+// skipSeparators returns the offset of the next token at or after offset,
+// past whitespace, colons and commas
+func skipSeparators(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) {
+		switch data[offset] {
+		case ' ', '\t', '\r', '\n', ':', ',':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
@@ -0,0 +1,44 @@
+package decoder_test
+
+import (
+	"testing"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+)
+
+func Test_Locate(t *testing.T) {
+	input := `{"a": [1, {"b": "x"}], "e-mail" : "y",
+"n": null}`
+
+	spans, err := decoder.Locate([]byte(input))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"$":           input,
+		"$.a":         `[1, {"b": "x"}]`,
+		"$.a[0]":      `1`,
+		"$.a[1]":      `{"b": "x"}`,
+		"$.a[1].b":    `"x"`,
+		"$['e-mail']": `"y"`,
+		"$.n":         `null`,
+	}
+	if len(spans) != len(expected) {
+		t.Errorf("Expected %d spans, Got: %v", len(expected), spans)
+	}
+	for path, value := range expected {
+		span, ok := spans[path]
+		if !ok {
+			t.Errorf("Expected a span for %s", path)
+			continue
+		}
+		if actual := input[span.Start:span.End]; actual != value {
+			t.Errorf("Expected %s to span %q, Got: %q", path, value, actual)
+		}
+	}
+
+	if _, err := decoder.Locate([]byte(`{"a": [1,`)); err == nil {
+		t.Errorf("Expected an error for truncated input")
+	}
+}
@@ -0,0 +1,129 @@
+package decoder
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidLogfmt is returned when a line cannot be parsed as logfmt
+var ErrInvalidLogfmt = errors.New("invalid logfmt line")
+
+// LogfmtPair is a single key/value pair of a logfmt line
+type LogfmtPair struct {
+	Key   string
+	Value string
+}
+
+// ParseLogfmt splits a logfmt line such as `level=info msg="hello world"`
+// into its key/value pairs, keeping their order. Keys without a value are
+// reported with an empty value, but a line needs at least one key=value pair
+// to be considered logfmt
+func ParseLogfmt(line string) ([]LogfmtPair, error) {
+	var pairs []LogfmtPair
+	assignments := 0
+
+	for i := 0; i < len(line); {
+		if line[i] == ' ' || line[i] == '\t' {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(line) && isLogfmtKeyByte(line[i]) {
+			i++
+		}
+		key := line[start:i]
+		if key == "" || (i < len(line) && line[i] != '=' && line[i] != ' ' && line[i] != '\t') {
+			return nil, ErrInvalidLogfmt
+		}
+
+		if i >= len(line) || line[i] != '=' {
+			pairs = append(pairs, LogfmtPair{Key: key})
+			continue
+		}
+		i++
+
+		value, next, err := parseLogfmtValue(line, i)
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, LogfmtPair{Key: key, Value: value})
+		assignments++
+		i = next
+	}
+
+	if assignments == 0 {
+		return nil, ErrInvalidLogfmt
+	}
+
+	return pairs, nil
+}
+
+// isLogfmtKeyByte reports whether c may appear in a logfmt key
+func isLogfmtKeyByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '.' || c == '-' || c == '/'
+}
+
+// parseLogfmtValue reads a bare or quoted value starting at offset i and
+// returns it along with the offset just past it
+func parseLogfmtValue(line string, i int) (string, int, error) {
+	if i < len(line) && line[i] == '"' {
+		end := i + 1
+		for end < len(line) && line[end] != '"' {
+			if line[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(line) {
+			return "", 0, ErrInvalidLogfmt
+		}
+
+		value, err := strconv.Unquote(line[i : end+1])
+		if err != nil {
+			return "", 0, ErrInvalidLogfmt
+		}
+		return value, end + 1, nil
+	}
+
+	end := i
+	for end < len(line) && line[end] != ' ' && line[end] != '\t' {
+		end++
+	}
+	return line[i:end], end, nil
+}
+
+// DecodeLogfmt parses a logfmt line into an object whose values have been
+// decoded: values that are entirely Base64 are decoded like JSON fields, and
+// values with embedded Base64 are decoded in place using markers
+func DecodeLogfmt(line string, markers Markers) (map[string]any, error) {
+	return defaultDecoder.DecodeLogfmt(line, markers)
+}
+
+// DecodeLogfmt parses and decodes a logfmt line like the package-level
+// DecodeLogfmt, using the decoder's options
+func (d *Decoder) DecodeLogfmt(line string, markers Markers) (map[string]any, error) {
+	pairs, err := ParseLogfmt(strings.TrimSpace(line))
+	if err != nil {
+		return nil, err
+	}
+
+	w := d.walker()
+	result := make(map[string]any, len(pairs))
+	for _, pair := range pairs {
+		w.path = append(w.path[:0], segment{name: pair.Key})
+		decoded := w.value(pair.Value)
+		if s, ok := decoded.(string); ok && s == pair.Value {
+			decoded = w.text(pair.Value, markers)
+		}
+		result[pair.Key] = decoded
+	}
+
+	if w.err != nil {
+		return nil, w.err
+	}
+
+	return result, nil
+}
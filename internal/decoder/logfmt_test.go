@@ -0,0 +1,49 @@
+package decoder_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+)
+
+func Test_ParseLogfmt(t *testing.T) {
+	pairs, err := decoder.ParseLogfmt(`level=info msg="hello \"world\"" debug`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []decoder.LogfmtPair{
+		{Key: "level", Value: "info"},
+		{Key: "msg", Value: `hello "world"`},
+		{Key: "debug"},
+	}
+	if len(pairs) != len(expected) {
+		t.Fatalf("Expected %d pairs, Got: %v", len(expected), pairs)
+	}
+	for i := range expected {
+		if pairs[i] != expected[i] {
+			t.Errorf("Expected: %v, Got: %v", expected[i], pairs[i])
+		}
+	}
+
+	if _, err := decoder.ParseLogfmt("panic: something went wrong"); !errors.Is(err, decoder.ErrInvalidLogfmt) {
+		t.Errorf("Expected ErrInvalidLogfmt, Got: %v", err)
+	}
+}
+
+func Test_DecodeLogfmt(t *testing.T) {
+	line := `ts=1 body=eyJ1c2VyIjoiam9obiJ9 msg="payload=SGVsbG8gV29ybGQgZnJvbSBsb2dz"`
+
+	fields, err := decoder.DecodeLogfmt(line, decoder.Markers{Open: "{{", Close: "}}"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	actual, _ := json.Marshal(fields)
+	expected := `{"body":{"user":"john"},"msg":"payload={{Hello World from logs}}","ts":"1"}`
+	if expected != string(actual) {
+		t.Errorf("Expected: %s, Got: %s", expected, actual)
+	}
+}
@@ -0,0 +1,80 @@
+package decoder
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"strings"
+
+	errs "github.com/vitorhrmiranda/jbdecoder/internal/errors"
+)
+
+// encodedWordPrefix starts every RFC 2047 encoded-word
+const encodedWordPrefix = "=?"
+
+// errNoEncodedWords is returned when a string holds no decodable encoded-word
+var errNoEncodedWords = errors.New("no encoded-words found")
+
+// DecodeEncodedWords decodes the RFC 2047 encoded-words in a header value
+// such as `=?UTF-8?B?SGVsbG8=?=`, reporting false if none could be decoded.
+// It also returns the last charset declared by a non-UTF-8 word
+func DecodeEncodedWords(s string) (string, string, bool) {
+	decoded, charset, err := decodeEncodedWords(s)
+	if err != nil {
+		return s, "", false
+	}
+	return decoded, charset, true
+}
+
+// decodeEncodedWords decodes the encoded-words in s, failing with
+// errNoEncodedWords when there was nothing to decode
+func decodeEncodedWords(s string) (string, string, error) {
+	if !strings.Contains(s, encodedWordPrefix) {
+		return s, "", errNoEncodedWords
+	}
+
+	var charset string
+	wordDecoder := mime.WordDecoder{
+		CharsetReader: func(declared string, input io.Reader) (io.Reader, error) {
+			data, err := io.ReadAll(input)
+			if err != nil {
+				return nil, err
+			}
+
+			text, err := toUTF8(data, declared)
+			if err != nil {
+				return nil, err
+			}
+
+			charset = strings.ToLower(declared)
+			return bytes.NewReader(text), nil
+		},
+	}
+
+	decoded, err := wordDecoder.DecodeHeader(s)
+	if err != nil {
+		return s, "", err
+	}
+	if decoded == s {
+		return s, "", errNoEncodedWords
+	}
+
+	return decoded, charset, nil
+}
+
+// encodedWords decodes a header value made of encoded-words and continues
+// into its content when it turns out to be JSON
+func (w *walker) encodedWords(s string) any {
+	decoded, charset, err := decodeEncodedWords(s)
+	if err != nil {
+		w.reject(CodecMIMEWord, errs.ErrInvalidEncoding, err)
+		return s
+	}
+
+	if !w.allow(int64(len(decoded)), CodecMIMEWord) {
+		return s
+	}
+
+	return w.content([]byte(decoded), CodecMIMEWord, charset)
+}
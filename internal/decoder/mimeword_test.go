@@ -0,0 +1,51 @@
+package decoder_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+)
+
+func Test_DecodeBase64String_EncodedWords(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "base64 encoded-word",
+			input:    "=?UTF-8?B?SGVsbG8gV29ybGQ=?=",
+			expected: `"Hello World"`,
+		},
+		{
+			name:     "quoted-printable latin-1 encoded-word",
+			input:    "=?ISO-8859-1?Q?caf=E9?=",
+			expected: `"café"`,
+		},
+		{
+			name:     "adjacent encoded-words in a header",
+			input:    "Re: =?UTF-8?B?SGVsbG8g?= =?UTF-8?B?V29ybGQ=?=",
+			expected: `"Re: Hello World"`,
+		},
+		{
+			name:     "JSON content",
+			input:    "=?UTF-8?B?eyJ1c2VyIjoiam9obiJ9?=",
+			expected: `{"user":"john"}`,
+		},
+		{
+			name:     "malformed encoded-word is left untouched",
+			input:    "=?UTF-8?X?abc?=",
+			expected: `"=?UTF-8?X?abc?="`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual, _ := json.Marshal(decoder.DecodeBase64String(testCase.input))
+			if testCase.expected != string(actual) {
+				t.Errorf("Expected: %s, Got: %s", testCase.expected, actual)
+			}
+		})
+	}
+}
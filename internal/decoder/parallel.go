@@ -0,0 +1,108 @@
+package decoder
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// minParallelElements is the smallest array decoded by the worker pool;
+	// shorter arrays are not worth the coordination
+	minParallelElements = 64
+
+	// chunksPerJob splits arrays finer than one chunk per worker so that
+	// uneven elements do not leave workers idle
+	chunksPerJob = 4
+)
+
+// chunk is a run of array elements decoded by one worker, along with what
+// the worker recorded while decoding them
+type chunk struct {
+	start, end  int
+	visited     int
+	annotations []Annotation
+	diagnostics []Diagnostic
+	err         error
+}
+
+// parallel reports whether the elements of s should be decoded by the
+// worker pool. Walkers inside the pool never fan out again, and the output
+// budget is consumed in document order, so it keeps decoding sequential
+func (w *walker) parallel(s []any) bool {
+	opts := w.decoder.opts
+	return opts.Jobs > 1 && !w.worker && opts.MaxOutputSize == 0 && len(s) >= minParallelElements
+}
+
+// sliceParallel decodes the elements of s into result with a bounded pool
+// of workers. Each worker records into its own walker and the chunks are
+// merged in order afterwards, so the value, annotations, diagnostics and
+// first error are identical to sequential decoding
+func (w *walker) sliceParallel(s, result []any) {
+	jobs := min(w.decoder.opts.Jobs, len(s))
+	size := max(len(s)/(jobs*chunksPerJob), 1)
+
+	chunks := make([]chunk, 0, len(s)/size+1)
+	for start := 0; start < len(s); start += size {
+		chunks = append(chunks, chunk{start: start, end: min(start+size, len(s))})
+	}
+
+	// Workers collect everything; hooks are replayed in order while merging
+	collecting := *w.decoder
+	collecting.opts.OnAnnotation = nil
+	collecting.opts.OnDiagnostic = nil
+	collecting.opts.OnProgress = nil
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			worker := &walker{
+				decoder:     &collecting,
+				depth:       w.depth,
+				inPlace:     w.inPlace,
+				worker:      true,
+				path:        append(path(nil), w.path...),
+				ctx:         w.ctx,
+				checkpoints: w.checkpoints,
+			}
+
+			for i := int(next.Add(1) - 1); i < len(chunks); i = int(next.Add(1) - 1) {
+				c := &chunks[i]
+				visited := worker.visited
+				for j := c.start; j < c.end && worker.err == nil; j++ {
+					worker.path = append(worker.path, segment{offset: j, isIndex: true})
+					result[j] = worker.value(s[j])
+					worker.path = worker.path[:len(worker.path)-1]
+				}
+
+				c.visited = worker.visited - visited
+				c.annotations, c.diagnostics, c.err = worker.annotations, worker.diagnostics, worker.err
+				worker.annotations, worker.diagnostics, worker.err = nil, nil, nil
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, c := range chunks {
+		w.visited += c.visited
+		for _, annotation := range c.annotations {
+			w.record(annotation)
+		}
+		for _, diagnostic := range c.diagnostics {
+			w.report(diagnostic)
+		}
+		if c.err != nil {
+			w.err = c.err
+			return
+		}
+	}
+
+	// Workers only check for cancellation; progress is reported once the
+	// chunks are merged
+	if w.checkpoints {
+		w.checkpoint()
+	}
+}
@@ -0,0 +1,64 @@
+package decoder_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+	errs "github.com/vitorhrmiranda/jbdecoder/internal/errors"
+)
+
+func Test_Decoder_Jobs(t *testing.T) {
+	data := benchmarkDocument(500)
+	data[321] = "c2hvcnQ="
+
+	sequential, _ := decoder.New(decoder.Options{Explain: true})
+	expected, err := sequential.Decode(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var hooked []string
+	parallel, _ := decoder.New(decoder.Options{
+		Explain:      true,
+		Jobs:         8,
+		OnAnnotation: func(a decoder.Annotation) { hooked = append(hooked, a.Path) },
+	})
+	actual, err := parallel.Decode(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expectedJSON, _ := json.Marshal(expected.Value)
+	actualJSON, _ := json.Marshal(actual.Value)
+	if string(expectedJSON) != string(actualJSON) {
+		t.Errorf("Expected the same value as sequential decoding")
+	}
+	if !reflect.DeepEqual(expected.Diagnostics, actual.Diagnostics) {
+		t.Errorf("Expected the same diagnostics as sequential decoding")
+	}
+
+	paths := make([]string, len(expected.Annotations))
+	for i, annotation := range expected.Annotations {
+		paths[i] = annotation.Path
+	}
+	if !reflect.DeepEqual(paths, hooked) {
+		t.Errorf("Expected annotations in document order, Got: %v", hooked[:min(len(hooked), 5)])
+	}
+}
+
+func Test_Decoder_Jobs_Limits(t *testing.T) {
+	data := benchmarkDocument(500)
+	data[100] = nestedBase64("a secret value that is long enough", 4)
+	data[400] = nestedBase64("a secret value that is long enough", 5)
+
+	d, _ := decoder.New(decoder.Options{Jobs: 8, MaxDepth: 3})
+	_, err := d.Decode(data)
+
+	var limit errs.LimitError
+	if !errors.As(err, &limit) || limit.Path() != "$[100].next.next.next" {
+		t.Errorf("Expected the first limit error below $[100], Got: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package decoder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// rootPath is the JSONPath of the document root
+const rootPath = "$"
+
+// segment is one step of a JSONPath: a member name or an array index
+type segment struct {
+	name    string
+	offset  int
+	isIndex bool
+}
+
+// path is a JSONPath location kept as a stack of segments from the root.
+// The walker pushes and pops segments as it descends, so tracking the
+// location costs no allocations and the string form is only rendered when
+// needed. The empty path is the document root
+type path []segment
+
+// String renders the path in JSONPath notation such as `$.user['e-mail'][0]`
+func (p path) String() string {
+	var b strings.Builder
+	b.WriteString(rootPath)
+
+	for _, s := range p {
+		switch {
+		case s.isIndex:
+			b.WriteByte('[')
+			b.WriteString(strconv.Itoa(s.offset))
+			b.WriteByte(']')
+		case isIdentifier(s.name):
+			b.WriteByte('.')
+			b.WriteString(s.name)
+		default:
+			b.WriteString("['")
+			b.WriteString(strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s.name))
+			b.WriteString("']")
+		}
+	}
+
+	return b.String()
+}
+
+// isIdentifier reports whether a member name can use dot notation
+func isIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		letter := c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+		digit := c >= '0' && c <= '9'
+		if !letter && (i == 0 || !digit) {
+			return false
+		}
+	}
+	return true
+}
+
+// MemberPath returns the JSONPath of member name of the value at parent,
+// rendered like the paths of annotations and diagnostics
+func MemberPath(parent, name string) string {
+	return parent + path{{name: name}}.String()[len(rootPath):]
+}
+
+// IndexPath returns the JSONPath of element i of the array at parent
+func IndexPath(parent string, i int) string {
+	return parent + path{{offset: i, isIndex: true}}.String()[len(rootPath):]
+}
+
+// parsePath parses a JSONPath without wildcards, such as the paths of
+// annotations, to the path it locates
+func parsePath(jsonPath string) (path, error) {
+	p, err := ParsePattern(jsonPath)
+	if err != nil {
+		return nil, err
+	}
+
+	at := make(path, 0, len(p.steps))
+	for _, st := range p.steps {
+		if st.wildcard || st.recursive {
+			return nil, fmt.Errorf("invalid path %q: must not hold wildcards", jsonPath)
+		}
+		at = append(at, st.segment)
+	}
+	return at, nil
+}
+
+// Generalize returns the pattern matching the same members of every array
+// element as a JSONPath, by replacing its indexes with wildcards, e.g.
+// "$.events[*].body" for "$.events[3].body"
+func Generalize(jsonPath string) (string, error) {
+	p, err := ParsePattern(jsonPath)
+	if err != nil {
+		return "", err
+	}
+
+	general := rootPath
+	for _, st := range p.steps {
+		switch {
+		case st.wildcard || st.recursive:
+			return "", fmt.Errorf("invalid path %q: must not hold wildcards", jsonPath)
+		case st.isIndex:
+			general += "[*]"
+		default:
+			general = MemberPath(general, st.name)
+		}
+	}
+	return general, nil
+}
@@ -0,0 +1,101 @@
+package decoder_test
+
+import (
+	"testing"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+)
+
+func Test_MemberPath_IndexPath(t *testing.T) {
+	testCases := []struct {
+		got      string
+		expected string
+	}{
+		{decoder.MemberPath("$", "user"), "$.user"},
+		{decoder.MemberPath("$.user", "e-mail"), "$.user['e-mail']"},
+		{decoder.MemberPath("$", "it's"), `$['it\'s']`},
+		{decoder.MemberPath("$", ""), "$['']"},
+		{decoder.IndexPath("$.items", 3), "$.items[3]"},
+		{decoder.MemberPath(decoder.IndexPath("$", 0), "id"), "$[0].id"},
+	}
+
+	for _, tc := range testCases {
+		if tc.got != tc.expected {
+			t.Errorf("expected %s, got %s", tc.expected, tc.got)
+		}
+	}
+}
+
+func Test_Generalize(t *testing.T) {
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{"$", "$"},
+		{"$.events[3].body", "$.events[*].body"},
+		{"$[0][12]['e-mail']", "$[*][*]['e-mail']"},
+		{`$['it\'s'][1]`, `$['it\'s'][*]`},
+	}
+
+	for _, tc := range testCases {
+		got, err := decoder.Generalize(tc.path)
+		if err != nil || got != tc.expected {
+			t.Errorf("expected %s, got %s (%v)", tc.expected, got, err)
+		}
+	}
+
+	for _, invalid := range []string{"events", "$.events[*]", "$..body"} {
+		if _, err := decoder.Generalize(invalid); err == nil {
+			t.Errorf("expected %s to be rejected", invalid)
+		}
+	}
+}
+
+func Test_Decoder_DecodeAt(t *testing.T) {
+	// "eyJ1c2VyIjoiam9obiJ9" is {"user":"john"} in Base64
+	const encoded = "eyJ1c2VyIjoiam9obiJ9"
+
+	testCases := []struct {
+		name     string
+		opts     decoder.Options
+		path     string
+		expected string
+	}{
+		{"root", decoder.Options{}, "$", "base64|json"},
+		{"included", decoder.Options{Include: []string{"$.events[*].body"}}, "$.events[2].body", "base64|json"},
+		{"not included", decoder.Options{Include: []string{"$.events[*].body"}}, "$.events[2].id", ""},
+		{"excluded", decoder.Options{Exclude: []string{"$..raw"}}, "$.a.raw", ""},
+		{"redacted", decoder.Options{Redact: []string{"$['e-mail']"}}, "$['e-mail']", ""},
+		{"rule", decoder.Options{Rules: []decoder.Rule{{Path: "$.id", Codecs: "base64"}}}, "$.id", "base64"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := decoder.New(tc.opts)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			result, err := d.DecodeAt(tc.path, encoded)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var codec string
+			for _, annotation := range result.Annotations {
+				if annotation.Path == tc.path {
+					codec = annotation.Codec
+				}
+			}
+			if codec != tc.expected {
+				t.Errorf("expected codec %q at %s, got %q in %+v", tc.expected, tc.path, codec, result.Annotations)
+			}
+		})
+	}
+
+	d, _ := decoder.New(decoder.Options{})
+	for _, invalid := range []string{"events", "$.events[*]", "$..body"} {
+		if _, err := d.DecodeAt(invalid, encoded); err == nil {
+			t.Errorf("expected %s to be rejected", invalid)
+		}
+	}
+}
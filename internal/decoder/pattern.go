@@ -0,0 +1,210 @@
+package decoder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// step is one step of a Pattern: a member name, an array index or a
+// wildcard matching any member or index
+type step struct {
+	segment
+	wildcard bool
+
+	// recursive lets the step match at any depth below the previous one
+	recursive bool
+}
+
+// matches reports whether the step accepts a path segment
+func (s step) matches(seg segment) bool {
+	switch {
+	case s.wildcard:
+		return true
+	case s.isIndex:
+		return seg.isIndex && seg.offset == s.offset
+	default:
+		return !seg.isIndex && seg.name == s.name
+	}
+}
+
+// Pattern selects values by their JSONPath. It supports member names in dot
+// or bracket notation, array indexes, the wildcard `*` for any member or
+// index, and recursive descent with `..`, e.g. `$.events[*].payload`,
+// `$['user-data'].token` or `$..secret`
+type Pattern struct {
+	raw   string
+	steps []step
+}
+
+// ParsePattern parses a JSONPath pattern
+func ParsePattern(s string) (Pattern, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(s), rootPath)
+	if !ok {
+		return Pattern{}, fmt.Errorf("invalid path pattern %q: must start with %s", s, rootPath)
+	}
+
+	p := Pattern{raw: s}
+	for rest != "" {
+		var st step
+		var err error
+
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			st.recursive = true
+			rest = rest[2:]
+			if strings.HasPrefix(rest, "[") {
+				st, rest, err = parseBracket(rest)
+				st.recursive = true
+			} else {
+				st, rest, err = parseMember(rest, st)
+			}
+		case strings.HasPrefix(rest, "."):
+			st, rest, err = parseMember(rest[1:], st)
+		case strings.HasPrefix(rest, "["):
+			st, rest, err = parseBracket(rest)
+		default:
+			err = fmt.Errorf("unexpected %q", rest)
+		}
+
+		if err != nil {
+			return Pattern{}, fmt.Errorf("invalid path pattern %q: %w", s, err)
+		}
+		p.steps = append(p.steps, st)
+	}
+
+	return p, nil
+}
+
+// parseMember parses a member name or wildcard in dot notation
+func parseMember(rest string, st step) (step, string, error) {
+	end := strings.IndexAny(rest, ".[")
+	if end < 0 {
+		end = len(rest)
+	}
+
+	name := rest[:end]
+	switch {
+	case name == "":
+		return st, rest, fmt.Errorf("missing member name")
+	case name == "*":
+		st.wildcard = true
+	default:
+		st.name = name
+	}
+	return st, rest[end:], nil
+}
+
+// parseBracket parses an index, a wildcard or a quoted member name in
+// bracket notation
+func parseBracket(rest string) (step, string, error) {
+	var st step
+	rest = rest[1:]
+
+	if quote := rest[:min(1, len(rest))]; quote == "'" || quote == `"` {
+		var name strings.Builder
+		for i := 1; i < len(rest); i++ {
+			switch c := rest[i]; {
+			case c == '\\' && i+1 < len(rest):
+				i++
+				name.WriteByte(rest[i])
+			case string(c) == quote:
+				closing, ok := strings.CutPrefix(rest[i+1:], "]")
+				if !ok {
+					return st, rest, fmt.Errorf("missing ] after member name")
+				}
+				st.name = name.String()
+				return st, closing, nil
+			default:
+				name.WriteByte(c)
+			}
+		}
+		return st, rest, fmt.Errorf("unterminated member name")
+	}
+
+	end := strings.IndexByte(rest, ']')
+	if end < 0 {
+		return st, rest, fmt.Errorf("missing ]")
+	}
+
+	index := rest[:end]
+	if index == "*" {
+		st.wildcard = true
+		return st, rest[end+1:], nil
+	}
+
+	offset, err := strconv.Atoi(index)
+	if err != nil || offset < 0 {
+		return st, rest, fmt.Errorf("invalid index %q", index)
+	}
+	st.offset, st.isIndex = offset, true
+	return st, rest[end+1:], nil
+}
+
+// String returns the pattern as it was parsed
+func (p Pattern) String() string {
+	return p.raw
+}
+
+// covers reports whether the pattern matches p or one of its ancestors, so
+// that selecting a value selects everything nested in it
+func (p Pattern) covers(at path) bool {
+	return matchSteps(p.steps, at, false)
+}
+
+// matches reports whether the pattern matches p itself, not an ancestor
+func (p Pattern) matches(at path) bool {
+	return matchSteps(p.steps, at, true)
+}
+
+// Covers reports whether the pattern matches the value at a JSONPath, such
+// as the path of an annotation or one built with MemberPath and IndexPath,
+// or one of its ancestors. Paths holding wildcards never match
+func (p Pattern) Covers(jsonPath string) bool {
+	concrete, err := ParsePattern(jsonPath)
+	if err != nil {
+		return false
+	}
+
+	at := make(path, 0, len(concrete.steps))
+	for _, st := range concrete.steps {
+		if st.wildcard || st.recursive {
+			return false
+		}
+		at = append(at, st.segment)
+	}
+	return p.covers(at)
+}
+
+// matchSteps matches steps against a prefix of the path segments, or all
+// of them when exact, backtracking over recursive descent
+func matchSteps(steps []step, segments path, exact bool) bool {
+	if len(steps) == 0 {
+		return !exact || len(segments) == 0
+	}
+
+	st := steps[0]
+	if st.recursive {
+		for i, seg := range segments {
+			if st.matches(seg) && matchSteps(steps[1:], segments[i+1:], exact) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return len(segments) > 0 && st.matches(segments[0]) && matchSteps(steps[1:], segments[1:], exact)
+}
+
+// parsePatterns parses a list of patterns
+func parsePatterns(patterns []string) ([]Pattern, error) {
+	parsed := make([]Pattern, 0, len(patterns))
+	for _, s := range patterns {
+		p, err := ParsePattern(s)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, p)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,183 @@
+package decoder_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+)
+
+func Test_ParsePattern(t *testing.T) {
+	valid := []string{"$", "$.user", "$.events[*].payload", "$['e-mail'][0]", `$["a.b"]`, "$..token", "$..[2]", "$.*"}
+	for _, pattern := range valid {
+		if _, err := decoder.ParsePattern(pattern); err != nil {
+			t.Errorf("Expected %q to parse, Got: %v", pattern, err)
+		}
+	}
+
+	invalid := []string{"", "user", "$.", "$[", "$[x]", "$[-1]", "$['open", "$['a'", "$user"}
+	for _, pattern := range invalid {
+		if _, err := decoder.ParsePattern(pattern); err == nil {
+			t.Errorf("Expected %q to be rejected", pattern)
+		}
+	}
+}
+
+func Test_Pattern_Covers(t *testing.T) {
+	testCases := []struct {
+		pattern  string
+		path     string
+		expected bool
+	}{
+		{"$.events[*].ts", "$.events[2].ts", true},
+		{"$.events[*].ts", "$.events[2].ts.nanos", true},
+		{"$.events[*].ts", "$.events[2]", false},
+		{"$..secret", "$.a['b c'].secret", true},
+		{"$['it\\'s']", decoder.MemberPath("$", "it's"), true},
+		{"$.a", "$.*", false},
+		{"$.a", "not a path", false},
+	}
+
+	for _, tc := range testCases {
+		p, err := decoder.ParsePattern(tc.pattern)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if actual := p.Covers(tc.path); actual != tc.expected {
+			t.Errorf("Expected %s covering %s to be %v", tc.pattern, tc.path, tc.expected)
+		}
+	}
+}
+
+func Test_Decoder_Filters(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte("a value that was encoded"))
+	data := func() any {
+		return map[string]any{
+			"token": encoded,
+			"events": []any{
+				map[string]any{"payload": encoded, "raw": encoded},
+				map[string]any{"payload": encoded, "raw": encoded},
+			},
+			"user-data": map[string]any{"token": encoded},
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		options  decoder.Options
+		expected []string
+	}{
+		{
+			name:     "include wildcard",
+			options:  decoder.Options{Include: []string{"$.events[*].payload"}},
+			expected: []string{"$.events[0].payload", "$.events[1].payload"},
+		},
+		{
+			name:     "include covers nested values",
+			options:  decoder.Options{Include: []string{"$.events[1]"}},
+			expected: []string{"$.events[1].payload", "$.events[1].raw"},
+		},
+		{
+			name:     "recursive descent",
+			options:  decoder.Options{Include: []string{"$..token"}},
+			expected: []string{"$.token", "$['user-data'].token"},
+		},
+		{
+			name:     "exclude wins over include",
+			options:  decoder.Options{Include: []string{"$.events"}, Exclude: []string{"$..raw", "$.events[0]"}},
+			expected: []string{"$.events[1].payload"},
+		},
+		{
+			name:     "exclude only",
+			options:  decoder.Options{Exclude: []string{"$.events", "$['user-data']"}},
+			expected: []string{"$.token"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			d, err := decoder.New(testCase.options)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			result, err := d.Decode(data())
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var paths []string
+			for _, annotation := range result.Annotations {
+				paths = append(paths, annotation.Path)
+			}
+			actual, _ := json.Marshal(paths)
+			expected, _ := json.Marshal(testCase.expected)
+			if string(actual) != string(expected) {
+				t.Errorf("Expected: %s, Got: %s", expected, actual)
+			}
+		})
+	}
+
+	if _, err := decoder.New(decoder.Options{Include: []string{"events"}}); err == nil {
+		t.Errorf("Expected an invalid pattern to be rejected")
+	}
+}
+
+func Test_Decoder_Redact(t *testing.T) {
+	secret := base64.StdEncoding.EncodeToString([]byte(`{"user":"ana","password":"hunter2"}`))
+	input := `{"auth":"` + secret + `","keys":{"a":[1,2]},"id":"SGVsbG8=","note":"ok"}`
+
+	testCases := []struct {
+		name     string
+		options  decoder.Options
+		expected string
+		streamed string
+	}{
+		{
+			name:     "inside decoded content",
+			options:  decoder.Options{Redact: []string{"$..password", "$.keys"}},
+			expected: `{"auth":{"password":"[REDACTED]","user":"ana"},"id":"SGVsbG8=","keys":"[REDACTED]","note":"ok"}`,
+			streamed: `{"auth":{"password":"[REDACTED]","user":"ana"},"keys":"[REDACTED]","id":"SGVsbG8=","note":"ok"}` + "\n",
+		},
+		{
+			name:     "encoded value itself",
+			options:  decoder.Options{Redact: []string{"$.auth"}, MinLength: 8},
+			expected: `{"auth":"[REDACTED]","id":"Hello","keys":{"a":[1,2]},"note":"ok"}`,
+			streamed: `{"auth":"[REDACTED]","keys":{"a":[1,2]},"id":"Hello","note":"ok"}` + "\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			d, err := decoder.New(testCase.options)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var data any
+			_ = json.Unmarshal([]byte(input), &data)
+			result, err := d.Decode(data)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			actual, _ := json.Marshal(result.Value)
+			if string(actual) != testCase.expected {
+				t.Errorf("Expected: %s, Got: %s", testCase.expected, actual)
+			}
+
+			var out strings.Builder
+			if err := d.Stream(strings.NewReader(input), &out); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if out.String() != testCase.streamed {
+				t.Errorf("Expected: %s, Got: %s", testCase.streamed, out.String())
+			}
+		})
+	}
+
+	if _, err := decoder.New(decoder.Options{Redact: []string{"password"}}); err == nil {
+		t.Errorf("Expected an invalid pattern to be rejected")
+	}
+}
@@ -0,0 +1,122 @@
+package decoder
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// maxExactFloat is the largest integer up to which float64 values are exact
+const maxExactFloat = 1 << 53
+
+// maxProtobufDepth bounds how deeply length-delimited fields are tried as
+// nested messages
+const maxProtobufDepth = 32
+
+// errTruncatedProtobuf reports a message that ends in the middle of a field
+var errTruncatedProtobuf = errors.New("truncated protobuf message")
+
+// decodeProtobuf decodes a protobuf message without its schema, like
+// protoc --decode_raw. Members are named after field numbers and repeated
+// fields become arrays. Varints and fixed-size fields are numbers, or
+// decimal strings when a JSON number cannot hold them exactly.
+// Length-delimited fields are strings when they are printable UTF-8, nested
+// messages when they parse as one and Base64 strings otherwise
+func decodeProtobuf(b []byte, depth int) (map[string]any, error) {
+	message := make(map[string]any)
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errTruncatedProtobuf
+		}
+		b = b[n:]
+
+		field, wire := key>>3, key&7
+		if field == 0 {
+			return nil, errors.New("invalid protobuf field number 0")
+		}
+
+		var value any
+		switch wire {
+		case wireVarint:
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				return nil, errTruncatedProtobuf
+			}
+			value, b = protobufNumber(v), b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return nil, errTruncatedProtobuf
+			}
+			value, b = protobufNumber(binary.LittleEndian.Uint64(b)), b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return nil, errTruncatedProtobuf
+			}
+			value, b = float64(binary.LittleEndian.Uint32(b)), b[4:]
+		case wireBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || size > uint64(len(b)-n) {
+				return nil, errTruncatedProtobuf
+			}
+			value, b = protobufBytes(b[n:n+int(size)], depth), b[n+int(size):]
+		default:
+			return nil, fmt.Errorf("unsupported protobuf wire type %d", wire)
+		}
+
+		name := strconv.FormatUint(field, 10)
+		switch existing := message[name].(type) {
+		case nil:
+			message[name] = value
+		case repeated:
+			message[name] = append(existing, value)
+		default:
+			message[name] = repeated{existing, value}
+		}
+	}
+
+	// Repeated fields were collected apart from values that are arrays
+	// themselves, and are plain arrays from now on
+	for name, value := range message {
+		if values, ok := value.(repeated); ok {
+			message[name] = []any(values)
+		}
+	}
+	return message, nil
+}
+
+// repeated holds the values of a field seen several times in a message
+type repeated []any
+
+// protobufNumber returns an unsigned integer as a JSON number, or as a
+// decimal string when it does not fit a float64 exactly
+func protobufNumber(v uint64) any {
+	if v > maxExactFloat {
+		return strconv.FormatUint(v, 10)
+	}
+	return float64(v)
+}
+
+// protobufBytes interprets a length-delimited field
+func protobufBytes(b []byte, depth int) any {
+	if utf8.Valid(b) && isPrintable(string(b)) {
+		return string(b)
+	}
+	if depth < maxProtobufDepth && len(b) > 0 {
+		if message, err := decodeProtobuf(b, depth+1); err == nil {
+			return message
+		}
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
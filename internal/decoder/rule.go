@@ -0,0 +1,225 @@
+package decoder
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	errs "github.com/vitorhrmiranda/jbdecoder/internal/errors"
+)
+
+// Codec names that only rules apply, since guessing them would be
+// unreliable
+const (
+	CodecBase64URL = "base64url"
+	CodecHex       = "hex"
+	CodecGzip      = "gzip"
+	CodecZlib      = "zlib"
+	CodecProtobuf  = "protobuf"
+)
+
+// Rule declares the codec chain of the values at the paths matching a
+// pattern, e.g. Path "$.events[*].body" and Codecs "base64url|gzip|json".
+// Chains start with base64, base64url or hex, possibly followed by more of
+// them and by gzip or zlib, and end with json or protobuf to parse the
+// decoded bytes, or with neither to read them as text. Protobuf messages
+// are decoded without their schema, so the codec takes no message type
+type Rule struct {
+	Path   string `json:"path"`
+	Codecs string `json:"codecs"`
+}
+
+// rule is a parsed Rule
+type rule struct {
+	pattern Pattern
+	chain   string
+	steps   []string
+}
+
+// parseRules parses the rules of Options.Rules
+func parseRules(rules []Rule) ([]rule, error) {
+	parsed := make([]rule, 0, len(rules))
+	for _, r := range rules {
+		p, err := ParsePattern(r.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		steps := strings.Split(r.Codecs, codecSeparator)
+		for i, step := range steps {
+			step = strings.TrimSpace(step)
+			steps[i] = step
+			first, last := i == 0, i == len(steps)-1
+			switch {
+			case step == CodecBase64 || step == CodecBase64URL || step == CodecHex:
+			case (step == CodecGzip || step == CodecZlib) && !first:
+			case step == CodecJSON && !first && last:
+			case step == CodecProtobuf && !first && last:
+			case strings.HasPrefix(step, CodecProtobuf+"("):
+				return nil, fmt.Errorf("invalid rule for %s: %q names a message type, but protobuf is decoded without a schema", r.Path, step)
+			default:
+				return nil, fmt.Errorf("invalid rule for %s: unexpected codec %q in %q", r.Path, step, r.Codecs)
+			}
+		}
+		parsed = append(parsed, rule{pattern: p, chain: strings.Join(steps, codecSeparator), steps: steps})
+	}
+	return parsed, nil
+}
+
+// rule returns the first rule matching the current path, or nil when none
+// does or Options.RuleExclude covers the path
+func (w *walker) rule() *rule {
+	for _, p := range w.decoder.ruleExclude {
+		if p.covers(w.path) {
+			return nil
+		}
+	}
+	for i := range w.decoder.rules {
+		if w.decoder.rules[i].pattern.matches(w.path) {
+			return &w.decoder.rules[i]
+		}
+	}
+	return nil
+}
+
+// applyRule decodes the value at the current path with the declared chain
+// of r instead of guessing. Failing to do so stops decoding with a
+// RuleError naming the rule and the failing codec. Null values are
+// left alone, for optional fields
+func (w *walker) applyRule(r *rule, data any) any {
+	s, ok := data.(string)
+	if !ok {
+		if data != nil {
+			w.err = errs.NewRuleError(r.pattern.String(), r.chain, errs.NewDecodeError(w.path.String(), r.steps[0], errs.ErrNotString, nil))
+		}
+		return data
+	}
+
+	fail := func(i int, reason errs.RejectReason, err error) any {
+		chain := strings.Join(r.steps[:i+1], codecSeparator)
+		w.err = errs.NewRuleError(r.pattern.String(), r.chain, errs.NewDecodeError(w.path.String(), chain, reason, err))
+		return s
+	}
+
+	b := []byte(s)
+	for i, codec := range r.steps {
+		var err error
+		switch codec {
+		case CodecBase64:
+			b, err = decodeBase64(base64.StdEncoding, b)
+		case CodecBase64URL:
+			b, err = decodeBase64(base64.URLEncoding, b)
+		case CodecHex:
+			b, err = hex.DecodeString(string(bytes.TrimSpace(b)))
+		case CodecGzip, CodecZlib:
+			b, err = w.decompress(codec, b)
+			if errors.Is(err, errInflateLimit) {
+				w.allow(int64(len(b)), r.chain)
+				return s
+			}
+		case CodecJSON, CodecProtobuf:
+			if !w.allow(int64(len(b)), r.chain) {
+				return s
+			}
+
+			var value any
+			if codec == CodecJSON {
+				value, err = unmarshalJSON(b)
+				if err != nil {
+					return fail(i, errs.ErrInvalidJSON, err)
+				}
+			} else if value, err = decodeProtobuf(b, 0); err != nil {
+				return fail(i, errs.ErrInvalidEncoding, err)
+			}
+
+			w.annotate(r.chain, "")
+
+			// Values nested in the parsed content are decoded one
+			// encoding layer deeper, by rules or by guessing. The content
+			// itself is at the path of the rule, so it is not walked again
+			inPlace := w.inPlace
+			w.inPlace = true
+			w.depth++
+			switch v := value.(type) {
+			case map[string]any:
+				value = w.object(v)
+			case []any:
+				value = w.slice(v)
+			}
+			w.depth--
+			w.inPlace = inPlace
+			return value
+		}
+		if err != nil {
+			return fail(i, errs.ErrInvalidEncoding, err)
+		}
+	}
+
+	if !w.allow(int64(len(b)), r.chain) {
+		return s
+	}
+	text, charset, err := transcode(b, w.decoder.opts.Charset)
+	if err != nil {
+		return fail(len(r.steps)-1, errs.ErrNotText, err)
+	}
+	w.annotate(r.chain, charset)
+	return string(text)
+}
+
+// decodeBase64 decodes padded or unpadded Base64 in the given alphabet
+func decodeBase64(enc *base64.Encoding, b []byte) ([]byte, error) {
+	b = bytes.TrimSpace(b)
+	if len(b)%base64BlockSize != validBase64Mod {
+		enc = enc.WithPadding(base64.NoPadding)
+	}
+	out := make([]byte, enc.DecodedLen(len(b)))
+	n, err := enc.Decode(out, b)
+	return out[:n], err
+}
+
+// errInflateLimit reports decompressed data exceeding Options.MaxFieldSize
+// or the remaining output budget
+var errInflateLimit = errors.New("decompressed data exceeds a limit")
+
+// decompress inflates gzip or zlib data. With Options.MaxFieldSize or
+// Options.MaxOutputSize, reading stops just past the field limit or the
+// remaining output budget, whichever is lower, and fails with
+// errInflateLimit, so that compression bombs are rejected without inflating
+// them entirely
+func (w *walker) decompress(codec string, b []byte) ([]byte, error) {
+	var r io.ReadCloser
+	var err error
+	if codec == CodecGzip {
+		r, err = gzip.NewReader(bytes.NewReader(b))
+	} else {
+		r, err = zlib.NewReader(bytes.NewReader(b))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+
+	opts := w.decoder.opts
+	limit := int64(-1)
+	if opts.MaxFieldSize > 0 {
+		limit = opts.MaxFieldSize
+	}
+	if opts.MaxOutputSize > 0 && (limit < 0 || w.budget < limit) {
+		limit = w.budget
+	}
+	if limit < 0 {
+		return io.ReadAll(r)
+	}
+
+	out, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err == nil && int64(len(out)) > limit {
+		err = errInflateLimit
+	}
+	return out, err
+}
@@ -0,0 +1,240 @@
+package decoder_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+	errs "github.com/vitorhrmiranda/jbdecoder/internal/errors"
+)
+
+// gzipped compresses s with gzip
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return b.Bytes()
+}
+
+func Test_Decoder_Rules(t *testing.T) {
+	body := base64.RawURLEncoding.EncodeToString(gzipped(t, `{"user":"ana","note":"SGVsbG8gV29ybGQgd29ybGQ="}`))
+	// Field 1 = 150, field 2 = "testing", field 3 = {1: 1}, field 4 = 1 and 2
+	message := base64.StdEncoding.EncodeToString([]byte("\x08\x96\x01\x12\x07testing\x1a\x02\x08\x01\x20\x01\x20\x02"))
+	input := `{
+		"events": [{"body": "` + body + `"}, {"body": null}],
+		"blob": "` + message + `",
+		"id": "` + hex.EncodeToString([]byte("order-1")) + `",
+		"other": "eyJ1c2VyIjoiam9obiJ9"
+	}`
+
+	d, err := decoder.New(decoder.Options{
+		Rules: []decoder.Rule{
+			{Path: "$.events[*].body", Codecs: "base64url|gzip|json"},
+			{Path: "$.blob", Codecs: "base64|protobuf"},
+			{Path: "$.id", Codecs: "hex"},
+		},
+		// Rules apply regardless of the codecs enabled for guessing
+		Codecs: []string{decoder.CodecBase64, decoder.CodecJSON},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var data any
+	if err := json.Unmarshal([]byte(input), &data); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result, err := d.Decode(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	actual, _ := json.Marshal(result.Value)
+	expected := `{"blob":{"1":150,"2":"testing","3":{"1":1},"4":[1,2]},` +
+		`"events":[{"body":{"note":"Hello World world","user":"ana"}},{"body":null}],` +
+		`"id":"order-1","other":{"user":"john"}}`
+	if string(actual) != expected {
+		t.Errorf("Expected: %s, Got: %s", expected, actual)
+	}
+
+	var annotations []string
+	for _, annotation := range result.Annotations {
+		annotations = append(annotations, annotation.Path+" "+annotation.Codec)
+	}
+	expectedAnnotations := "$.blob base64|protobuf, $.events[0].body base64url|gzip|json, " +
+		"$.events[0].body.note base64, $.id hex, $.other base64|json"
+	if strings.Join(annotations, ", ") != expectedAnnotations {
+		t.Errorf("Expected: %s, Got: %s", expectedAnnotations, strings.Join(annotations, ", "))
+	}
+}
+
+func Test_Decoder_RuleExclude(t *testing.T) {
+	d, err := decoder.New(decoder.Options{
+		Rules:       []decoder.Rule{{Path: "$..id", Codecs: "hex"}},
+		Exclude:     []string{"$.internal"},
+		RuleExclude: []string{"$.internal"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	result, err := d.Decode(map[string]any{
+		"id":       "6f726465722d31",
+		"internal": map[string]any{"id": "6f726465722d32"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	actual, _ := json.Marshal(result.Value)
+	if expected := `{"id":"order-1","internal":{"id":"6f726465722d32"}}`; string(actual) != expected {
+		t.Errorf("Expected: %s, Got: %s", expected, actual)
+	}
+}
+
+func Test_Decoder_Rules_Limits(t *testing.T) {
+	// Inflates to 4 KiB of Base64, which the last step would reject once
+	// cut short
+	bomb := base64.StdEncoding.EncodeToString(gzipped(t, strings.Repeat("QUFB", 1024)))
+
+	testCases := []struct {
+		name     string
+		options  decoder.Options
+		data     any
+		expected error
+	}{
+		{
+			name:     "max field size",
+			options:  decoder.Options{MaxFieldSize: 100},
+			data:     map[string]any{"a": bomb},
+			expected: errs.ErrFieldTooLarge,
+		},
+		{
+			name:     "max output size",
+			options:  decoder.Options{MaxOutputSize: 100},
+			data:     map[string]any{"a": bomb},
+			expected: errs.ErrOutputTooLarge,
+		},
+		{
+			name:     "remaining output budget",
+			options:  decoder.Options{MaxOutputSize: 5000},
+			data:     []any{map[string]any{"a": bomb}, map[string]any{"a": bomb}},
+			expected: errs.ErrOutputTooLarge,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.options.Rules = []decoder.Rule{{Path: "$..a", Codecs: "base64|gzip|base64"}}
+			d, err := decoder.New(testCase.options)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if _, err := d.Decode(testCase.data); !errors.Is(err, testCase.expected) {
+				t.Errorf("Expected: %v, Got: %v", testCase.expected, err)
+			}
+
+			testCase.options.Truncate = true
+			d, _ = decoder.New(testCase.options)
+			result, err := d.Decode(testCase.data)
+			if err != nil {
+				t.Fatalf("Unexpected error in truncate mode: %v", err)
+			}
+			last := result.Annotations[len(result.Annotations)-1]
+			if last.Limit == "" {
+				t.Errorf("Expected the last annotation to mark a limit, Got: %+v", result.Annotations)
+			}
+		})
+	}
+}
+
+func Test_Decoder_Rules_Errors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		rule   decoder.Rule
+		input  string
+		reason errs.RejectReason
+		codec  string
+	}{
+		{
+			name:   "invalid encoding",
+			rule:   decoder.Rule{Path: "$.a", Codecs: "base64|gzip|json"},
+			input:  `{"a": "not base64!"}`,
+			reason: errs.ErrInvalidEncoding,
+			codec:  "base64",
+		},
+		{
+			name:   "not compressed",
+			rule:   decoder.Rule{Path: "$.a", Codecs: "base64|gzip|json"},
+			input:  `{"a": "eyJ1c2VyIjoiam9obiJ9"}`,
+			reason: errs.ErrInvalidEncoding,
+			codec:  "base64|gzip",
+		},
+		{
+			name:   "not JSON",
+			rule:   decoder.Rule{Path: "$.a", Codecs: "base64|json"},
+			input:  `{"a": "SGVsbG8gV29ybGQgd29ybGQ="}`,
+			reason: errs.ErrInvalidJSON,
+			codec:  "base64|json",
+		},
+		{
+			name:   "not a string",
+			rule:   decoder.Rule{Path: "$.a", Codecs: "base64"},
+			input:  `{"a": {"b": 1}}`,
+			reason: errs.ErrNotString,
+			codec:  "base64",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			d, err := decoder.New(decoder.Options{Rules: []decoder.Rule{testCase.rule}})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var data any
+			_ = json.Unmarshal([]byte(testCase.input), &data)
+			_, err = d.Decode(data)
+			var decodeErr errs.DecodeError
+			if !errors.As(err, &decodeErr) || !errors.Is(err, testCase.reason) {
+				t.Fatalf("Expected a %s error, Got: %v", testCase.reason.Code(), err)
+			}
+			if decodeErr.Path != "$.a" || decodeErr.Codec != testCase.codec {
+				t.Errorf("Expected $.a %s, Got: %s %s", testCase.codec, decodeErr.Path, decodeErr.Codec)
+			}
+			var ruleErr errs.RuleError
+			if !errors.As(err, &ruleErr) || !strings.HasPrefix(err.Error(), "$.a: rule $.a="+testCase.rule.Codecs+": ") {
+				t.Errorf("Expected the error to name the rule, Got: %v", err)
+			}
+
+			if err := d.Stream(strings.NewReader(testCase.input), &strings.Builder{}); !errors.Is(err, testCase.reason) {
+				t.Errorf("Expected the stream to fail with %s, Got: %v", testCase.reason.Code(), err)
+			}
+		})
+	}
+
+	for _, codecs := range []string{"gzip|json", "json|base64", "base64|rot13", "base64|protobuf(my.pkg.Msg)", "base64|protobuf|json"} {
+		if _, err := decoder.New(decoder.Options{Rules: []decoder.Rule{{Path: "$.a", Codecs: codecs}}}); err == nil {
+			t.Errorf("Expected %q to be rejected", codecs)
+		}
+	}
+
+	_, err := decoder.New(decoder.Options{Rules: []decoder.Rule{{Path: "$.a", Codecs: "base64|protobuf(my.pkg.Msg)"}}})
+	if err == nil || !strings.Contains(err.Error(), "protobuf is decoded without a schema") {
+		t.Errorf("Expected message types to be rejected, Got: %v", err)
+	}
+}
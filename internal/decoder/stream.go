@@ -0,0 +1,299 @@
+//go:build !tinygo
+
+package decoder
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+
+	errs "github.com/vitorhrmiranda/jbdecoder/internal/errors"
+)
+
+// ErrEmptyStream is returned when a stream holds no JSON value at all
+var ErrEmptyStream = errors.New("no JSON value in stream")
+
+// streamFrame tracks an object or array that is open in the output
+type streamFrame struct {
+	object bool
+
+	// count is the number of members or elements written so far
+	count int
+
+	// expectKey is set in objects when the next string token is a key
+	expectKey bool
+}
+
+// streamer walks a JSON token stream, writing decoded values as it goes
+type streamer struct {
+	walker *walker
+	out    *bufio.Writer
+	stack  []streamFrame
+
+	// blobs diverts oversized strings when Options.BlobThreshold is set
+	blobs *blobReader
+
+	// skip is the nesting depth within an object or array hidden by
+	// Options.Redact, whose tokens are dropped
+	skip int
+}
+
+// Stream decodes the JSON read from r token by token and writes the decoded
+// document to out incrementally, so memory use is bounded by the largest
+// single string rather than the document size. Object members keep their
+// input order. Several top-level values, such as NDJSON records, are each
+// written on their own line and flushed as soon as they are complete, so
+// an error may leave a partial value behind in out.
+// Annotations and diagnostics are only delivered through the OnAnnotation
+// and OnDiagnostic hooks
+func (d *Decoder) Stream(r io.Reader, out io.Writer) error {
+	s := &streamer{walker: d.walker(), out: bufio.NewWriter(out)}
+	s.walker.streaming = true
+	if d.opts.BlobThreshold > 0 {
+		// Oversized strings are diverted before the tokenizer buffers them
+		s.blobs = d.newBlobReader(r)
+		defer s.blobs.close()
+		r = s.blobs
+	}
+
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	values := 0
+
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			if len(s.stack) > 0 || s.skip > 0 {
+				return io.ErrUnexpectedEOF
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := s.token(tok); err != nil {
+			return err
+		}
+		if s.walker.err != nil {
+			return s.walker.err
+		}
+
+		if len(s.stack) == 0 && s.skip == 0 {
+			values++
+			if err := s.endDocument(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if values == 0 {
+		return ErrEmptyStream
+	}
+
+	return nil
+}
+
+// token writes a single token, decoding string values
+func (s *streamer) token(tok json.Token) error {
+	if s.skip > 0 {
+		if delim, ok := tok.(json.Delim); ok {
+			if delim == '{' || delim == '[' {
+				s.skip++
+			} else if s.skip--; s.skip == 0 {
+				s.endValue()
+			}
+		}
+		return nil
+	}
+
+	if delim, ok := tok.(json.Delim); ok {
+		switch delim {
+		case '{', '[':
+			s.beginValue()
+			if len(s.walker.decoder.redact) > 0 && s.walker.redacted() {
+				s.skip = 1
+				return s.write(Redacted)
+			}
+			if err := s.ruleMismatch(); err != nil {
+				return err
+			}
+			s.stack = append(s.stack, streamFrame{object: delim == '{', expectKey: delim == '{'})
+			return s.out.WriteByte(byte(delim))
+		default:
+			s.stack = s.stack[:len(s.stack)-1]
+			if err := s.out.WriteByte(byte(delim)); err != nil {
+				return err
+			}
+			s.endValue()
+			return nil
+		}
+	}
+
+	if key, ok := tok.(string); ok && len(s.stack) > 0 && s.top().expectKey {
+		return s.key(key)
+	}
+
+	s.beginValue()
+	if err := s.scalar(tok); err != nil {
+		return err
+	}
+	s.endValue()
+	return nil
+}
+
+// key writes an object member name and descends into it
+func (s *streamer) key(key string) error {
+	frame := s.top()
+	if frame.count > 0 {
+		if err := s.out.WriteByte(','); err != nil {
+			return err
+		}
+	}
+	frame.count++
+	frame.expectKey = false
+
+	if err := s.write(key); err != nil {
+		return err
+	}
+	s.walker.path = append(s.walker.path, segment{name: key})
+	return s.out.WriteByte(':')
+}
+
+// scalar writes a string, number, boolean or null, decoding strings
+func (s *streamer) scalar(tok json.Token) error {
+	if len(s.walker.decoder.redact) > 0 && s.walker.redacted() {
+		return s.write(Redacted)
+	}
+
+	switch v := tok.(type) {
+	case string:
+		if s.blobs != nil {
+			if result, ok := s.blobs.lookup(v); ok {
+				return s.blob(result)
+			}
+		}
+		return s.write(s.walker.value(v))
+	case json.Number:
+		if err := s.ruleMismatch(); err != nil {
+			return err
+		}
+		_, err := s.out.WriteString(v.String())
+		return err
+	case bool:
+		if err := s.ruleMismatch(); err != nil {
+			return err
+		}
+		return s.write(v)
+	default:
+		return s.write(v)
+	}
+}
+
+// ruleMismatch fails for a value that is not a string at a path declared
+// by Options.Rules, which decodes only strings
+func (s *streamer) ruleMismatch() error {
+	if len(s.walker.decoder.rules) == 0 {
+		return nil
+	}
+	if r := s.walker.rule(); r != nil {
+		return errs.NewRuleError(r.pattern.String(), r.chain, errs.NewDecodeError(s.walker.path.String(), r.steps[0], errs.ErrNotString, nil))
+	}
+	return nil
+}
+
+// blob writes a diverted string that was decoded to a blob sink. Like
+// strings held in memory, a string is written back unchanged when its path
+// is not selected, Base64 is disabled or it is not valid Base64, and
+// decoded in memory when a rule declares its codecs
+func (s *streamer) blob(result *blobResult) error {
+	defer result.release()
+
+	w := s.walker
+	if len(w.decoder.rules) > 0 && w.rule() != nil {
+		text, err := result.text()
+		if err != nil {
+			return err
+		}
+		return s.write(w.value(text))
+	}
+	if !w.selected() || !w.decoder.enabled(CodecBase64) {
+		return s.verbatim(result)
+	}
+
+	switch {
+	case result.err == nil:
+		s.walker.annotate(CodecBase64, "")
+		return s.write(result.blob)
+	case !result.invalid():
+		return result.err
+	}
+
+	s.walker.reject(CodecBase64, errs.ErrInvalidEncoding, result.err)
+	return s.verbatim(result)
+}
+
+// verbatim writes a diverted string back as it appeared in the input
+func (s *streamer) verbatim(result *blobResult) error {
+	if _, err := result.raw.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := s.out.WriteByte('"'); err != nil {
+		return err
+	}
+	if _, err := s.out.ReadFrom(result.raw); err != nil {
+		return err
+	}
+	return s.out.WriteByte('"')
+}
+
+// beginValue writes the separator before an array element and descends
+// into it. Object members were already entered by their key
+func (s *streamer) beginValue() {
+	if len(s.stack) == 0 || s.top().object {
+		return
+	}
+
+	frame := s.top()
+	if frame.count > 0 {
+		_ = s.out.WriteByte(',')
+	}
+	s.walker.path = append(s.walker.path, segment{offset: frame.count, isIndex: true})
+	frame.count++
+}
+
+// endValue leaves the member or element that was just written
+func (s *streamer) endValue() {
+	if len(s.stack) == 0 {
+		return
+	}
+
+	s.walker.path = s.walker.path[:len(s.walker.path)-1]
+	if frame := s.top(); frame.object {
+		frame.expectKey = true
+	}
+}
+
+// endDocument terminates a top-level value and flushes it
+func (s *streamer) endDocument() error {
+	if err := s.out.WriteByte('\n'); err != nil {
+		return err
+	}
+	return s.out.Flush()
+}
+
+// top returns the innermost open object or array
+func (s *streamer) top() *streamFrame {
+	return &s.stack[len(s.stack)-1]
+}
+
+// write encodes a value as JSON
+func (s *streamer) write(v any) error {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = s.out.Write(encoded)
+	return err
+}
@@ -0,0 +1,87 @@
+package decoder_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+	errs "github.com/vitorhrmiranda/jbdecoder/internal/errors"
+)
+
+func Test_Decoder_Stream(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "object keeps member order",
+			input:    `{"z":"eyJ1c2VyIjoiam9obiJ9","a":[1,2.50,true,null]}`,
+			expected: `{"z":{"user":"john"},"a":[1,2.50,true,null]}` + "\n",
+		},
+		{
+			name:     "nested containers",
+			input:    `[{"a":[]},{},[["SGVsbG8gV29ybGQgZnJvbSBCYXNlNjQ="]]]`,
+			expected: `[{"a":[]},{},[["Hello World from Base64"]]]` + "\n",
+		},
+		{
+			name:     "NDJSON records",
+			input:    "{\"id\":1}\n{\"id\":2}\n\"plain\"\n",
+			expected: "{\"id\":1}\n{\"id\":2}\n\"plain\"\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			d, _ := decoder.New(decoder.Options{})
+
+			var out strings.Builder
+			if err := d.Stream(strings.NewReader(testCase.input), &out); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if out.String() != testCase.expected {
+				t.Errorf("Expected: %s, Got: %s", testCase.expected, out.String())
+			}
+		})
+	}
+}
+
+func Test_Decoder_Stream_Hooks(t *testing.T) {
+	var paths []string
+	d, _ := decoder.New(decoder.Options{
+		Explain:      true,
+		OnAnnotation: func(a decoder.Annotation) { paths = append(paths, a.Path) },
+		OnDiagnostic: func(diagnostic decoder.Diagnostic) { paths = append(paths, "!"+diagnostic.Path) },
+	})
+
+	input := `{"records":[{"token":"eyJ1c2VyIjoiam9obiJ9"},{"note":"short"}]}`
+	if err := d.Stream(strings.NewReader(input), &strings.Builder{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "$.records[0].token !$.records[0].token.user !$.records[1].note"
+	if strings.Join(paths, " ") != expected {
+		t.Errorf("Expected: %s, Got: %v", expected, paths)
+	}
+}
+
+func Test_Decoder_Stream_Errors(t *testing.T) {
+	d, _ := decoder.New(decoder.Options{})
+	if err := d.Stream(strings.NewReader("  "), &strings.Builder{}); !errors.Is(err, decoder.ErrEmptyStream) {
+		t.Errorf("Expected ErrEmptyStream, Got: %v", err)
+	}
+	if err := d.Stream(strings.NewReader(`{"a":}`), &strings.Builder{}); err == nil {
+		t.Error("Expected a syntax error")
+	}
+	if err := d.Stream(strings.NewReader(`{"a":1}{"b":`), &strings.Builder{}); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected io.ErrUnexpectedEOF, Got: %v", err)
+	}
+
+	d, _ = decoder.New(decoder.Options{MaxFieldSize: 4})
+	input := `["eyJ1c2VyIjoiam9obiJ9"]`
+	if err := d.Stream(strings.NewReader(input), &strings.Builder{}); !errors.Is(err, errs.ErrFieldTooLarge) {
+		t.Errorf("Expected ErrFieldTooLarge, Got: %v", err)
+	}
+}
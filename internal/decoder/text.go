@@ -0,0 +1,150 @@
+package decoder
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Markers delimit decoded content that was substituted into free-form text
+type Markers struct {
+	Open  string
+	Close string
+}
+
+// DefaultMarkers are used when text mode is not given explicit markers
+var DefaultMarkers = Markers{Open: "«", Close: "»"}
+
+var (
+	// base64RunPattern matches standalone runs of Base64 alphabet characters
+	base64RunPattern = regexp.MustCompile(`[A-Za-z0-9+/]{16,}={0,2}`)
+
+	// dataURIPattern matches Base64 data URIs embedded in text
+	dataURIPattern = regexp.MustCompile(`data:[A-Za-z0-9.+-]*/?[A-Za-z0-9.+-]*(?:;[A-Za-z0-9.+-]+=[A-Za-z0-9.+-]+)*;base64,[A-Za-z0-9+/]+={0,2}`)
+
+	// encodedWordsPattern matches runs of RFC 2047 encoded-words in text
+	encodedWordsPattern = regexp.MustCompile(`=\?[^?\s]+\?[BbQq]\?[^?\s]*\?=(?:\s+=\?[^?\s]+\?[BbQq]\?[^?\s]*\?=)*`)
+)
+
+// DecodeText scans free-form text for Base64 runs, data URIs and MIME
+// encoded-words and replaces each decodable one with its decoded content
+// wrapped in markers, leaving the surrounding text intact
+func DecodeText(text string, markers Markers) string {
+	return defaultDecoder.walker().text(text, markers)
+}
+
+// DecodeText scans free-form text like the package-level DecodeText, using
+// the decoder's options. The output budget applies to the text as a whole
+func (d *Decoder) DecodeText(text string, markers Markers) (string, error) {
+	return d.DecodeTextContext(context.Background(), text, markers)
+}
+
+// DecodeTextContext decodes text like DecodeText, stopping with the context
+// error once ctx is done
+func (d *Decoder) DecodeTextContext(ctx context.Context, text string, markers Markers) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	w := d.walker()
+	w.ctx = ctx
+	w.checkpoints = ctx.Done() != nil || d.opts.OnProgress != nil
+	decoded := w.text(text, markers)
+	if w.err != nil {
+		return "", w.err
+	}
+	return decoded, nil
+}
+
+// text replaces the decodable candidates found in text
+func (w *walker) text(text string, markers Markers) string {
+	var b strings.Builder
+	last := 0
+
+	for _, loc := range findCandidates(text) {
+		if w.err != nil {
+			break
+		}
+		decoded, ok := w.textCandidate(text[loc[0]:loc[1]])
+		if !ok {
+			continue
+		}
+		b.WriteString(text[last:loc[0]])
+		b.WriteString(markers.Open)
+		b.WriteString(decoded)
+		b.WriteString(markers.Close)
+		last = loc[1]
+	}
+
+	if last == 0 {
+		return text
+	}
+
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// findCandidates returns the non-overlapping byte ranges of data URIs,
+// encoded-words and Base64 runs in text, in order of appearance
+func findCandidates(text string) [][]int {
+	var candidates [][]int
+
+	for _, pattern := range []*regexp.Regexp{dataURIPattern, encodedWordsPattern, base64RunPattern} {
+		for _, loc := range pattern.FindAllStringIndex(text, -1) {
+			if !overlapsAny(loc, candidates) {
+				candidates = append(candidates, loc)
+			}
+		}
+	}
+
+	slices.SortFunc(candidates, func(a, b []int) int { return a[0] - b[0] })
+	return candidates
+}
+
+// overlapsAny reports whether r overlaps any of the given ranges
+func overlapsAny(r []int, ranges [][]int) bool {
+	for _, other := range ranges {
+		if r[0] < other[1] && other[0] < r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// textCandidate decodes a Base64 run, data URI or encoded-word found
+// in text and renders it as text, reporting false if it should be left
+// untouched
+func (w *walker) textCandidate(candidate string) (string, bool) {
+	decoded := w.value(candidate)
+
+	switch v := decoded.(type) {
+	case string:
+		if v == candidate || !isPrintable(v) {
+			return "", false
+		}
+		return v, true
+	default:
+		output, err := marshalJSON(v)
+		if err != nil {
+			return "", false
+		}
+		return string(output), true
+	}
+}
+
+// isPrintable reports whether decoded text is free of control characters
+// other than common whitespace, which filters out identifiers that happen
+// to be valid Base64
+func isPrintable(s string) bool {
+	for _, r := range s {
+		if unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t' {
+			return false
+		}
+		if r == unicode.ReplacementChar {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package decoder_test
+
+import (
+	"testing"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+)
+
+func Test_DecodeText(t *testing.T) {
+	markers := decoder.Markers{Open: "[", Close: "]"}
+
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "base64 JSON inside a log line",
+			input:    `msg="payload=eyJ1c2VyIjoiam9obiJ9" level=info`,
+			expected: `msg="payload=[{"user":"john"}]" level=info`,
+		},
+		{
+			name:     "base64 text between words",
+			input:    "token SGVsbG8gV29ybGQgZnJvbSBsb2dz received",
+			expected: "token [Hello World from logs] received",
+		},
+		{
+			name:     "data URI",
+			input:    "body=data:application/json;base64,eyJhIjoxfQ== end",
+			expected: `body=[{"a":1}] end`,
+		},
+		{
+			name:     "identifier that is not base64 text",
+			input:    "handler ThisIsAVeryLongIdentifierName failed",
+			expected: "handler ThisIsAVeryLongIdentifierName failed",
+		},
+		{
+			name:     "text without candidates",
+			input:    "nothing to see here",
+			expected: "nothing to see here",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := decoder.DecodeText(testCase.input, markers)
+			if actual != testCase.expected {
+				t.Errorf("Expected: %s, Got: %s", testCase.expected, actual)
+			}
+		})
+	}
+}
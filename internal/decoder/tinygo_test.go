@@ -0,0 +1,120 @@
+package decoder_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+)
+
+// outcome is everything observable about one decoding
+type outcome struct {
+	Value       any
+	Annotations []decoder.Annotation
+	Diagnostics []string
+	Err         string
+	Text        string
+}
+
+// decodeOutcome decodes document and text with opts
+func decodeOutcome(t *testing.T, opts decoder.Options, document, text string) outcome {
+	t.Helper()
+
+	var data any
+	if err := json.Unmarshal([]byte(document), &data); err != nil {
+		t.Fatalf("invalid test document %s: %v", document, err)
+	}
+
+	d, err := decoder.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var o outcome
+	result, err := d.Decode(data)
+	if err != nil {
+		o.Err = err.Error()
+	}
+	o.Value, o.Annotations = result.Value, result.Annotations
+	for _, diagnostic := range result.Diagnostics {
+		o.Diagnostics = append(o.Diagnostics, fmt.Sprintf("%s %s %s", diagnostic.Path, diagnostic.Codec, diagnostic.Reason.Code()))
+	}
+
+	if o.Text, err = d.DecodeText(text, decoder.Markers{}); err != nil {
+		o.Text = "error: " + err.Error()
+	}
+	return o
+}
+
+func Test_Decoder_JSONLite_Parity(t *testing.T) {
+	b64 := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+	testCases := []struct {
+		name     string
+		options  decoder.Options
+		document string
+		text     string
+	}{
+		{
+			name:     "nested JSON",
+			document: `{"data": "` + b64(`{"message": "distance", "n": [1, 2.5, -0, 1e21, 1e-7, true, null]}`) + `"}`,
+			text:     "payload=" + b64(`{"b": 1, "a": "<&>"}`),
+		},
+		{
+			name:     "escapes and unicode",
+			document: `{"s": "` + b64(`{"html": "<a href=\"x\">&amp;</a>", "ls": "  ", "emoji": "😀", "lone": "\ud800", "ctl": "\u0001\b\f"}`) + `"}`,
+			text:     "value " + b64(`["é", "😀"]`) + " end",
+		},
+		{
+			name:     "layers",
+			document: `[` + `"` + b64(`"`+b64(`{"deep": {"deeper": []}}`)+`"`) + `", "plain", 42]`,
+			text:     b64(`{}`),
+		},
+		{
+			name:     "data URI and encoded words",
+			document: `{"uri": "data:application/json;base64,` + b64(`{"k": "v"}`) + `", "words": "=?UTF-8?B?` + b64("Olá") + `?="}`,
+			text:     "data:application/json;base64," + b64(`[1,2]`),
+		},
+		{
+			name:     "invalid nested JSON stays text",
+			options:  decoder.Options{Explain: true},
+			document: `{"a": "` + b64(`{"broken": `) + `", "b": "not base64!"}`,
+			text:     b64(`{"broken": `),
+		},
+		{
+			name:     "codecs and filters",
+			options:  decoder.Options{Codecs: []string{decoder.CodecBase64}, Exclude: []string{"$.skip"}},
+			document: `{"keep": "` + b64(`{"x": 1}`) + `", "skip": "` + b64("hidden text") + `"}`,
+			text:     b64(`{"x": 1}`),
+		},
+		{
+			name:     "depth limit",
+			options:  decoder.Options{MaxDepth: 1},
+			document: `{"a": "` + b64(`"`+b64(`{"x": 1}`)+`"`) + `"}`,
+			text:     "nothing to decode",
+		},
+		{
+			name:     "truncated limit",
+			options:  decoder.Options{MaxFieldSize: 8, Truncate: true, Explain: true},
+			document: `{"a": "` + b64(`{"long": "value exceeding the limit"}`) + `", "b": "` + b64("ok") + `"}`,
+			text:     b64(`{"long": "value exceeding the limit"}`),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			std := decodeOutcome(t, tc.options, tc.document, tc.text)
+
+			restore := decoder.UseJSONLite()
+			defer restore()
+			lite := decodeOutcome(t, tc.options, tc.document, tc.text)
+
+			if !reflect.DeepEqual(std, lite) {
+				t.Errorf("jsonlite differs from encoding/json\nstd:  %#v\nlite: %#v", std, lite)
+			}
+		})
+	}
+}
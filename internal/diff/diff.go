@@ -0,0 +1,324 @@
+// Package diff compares two decoded documents structurally, reporting the
+// JSONPaths that were added, removed or changed along with the encoded
+// payloads the changes are nested in. Changes are written as text, as JSON
+// or as a JSON Patch turning one decoded document into the other
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+)
+
+// Kind is the kind of a change
+type Kind string
+
+// Kinds of changes
+const (
+	Added   Kind = "added"
+	Removed Kind = "removed"
+	Changed Kind = "changed"
+)
+
+// Options configures Compare
+type Options struct {
+	// Ignore leaves out changes at or below paths matching one of these
+	// patterns, e.g. "$..timestamp"
+	Ignore []decoder.Pattern
+}
+
+// Payload is a decoded value enclosing a change
+type Payload struct {
+	Path  string `json:"path"`
+	Codec string `json:"codec"`
+}
+
+// Change is a difference between two documents at a JSONPath. Old is unset
+// for added values and New for removed ones
+type Change struct {
+	Kind Kind
+	Path string
+
+	// Pointer is the JSON Pointer (RFC 6901) of Path
+	Pointer string
+
+	Old any
+	New any
+
+	// Payloads are the decoded values the change is inside of, outermost
+	// first. The change is to the decoded value itself when the last one
+	// has the path of the change
+	Payloads []Payload
+}
+
+// Compare returns the changes between the decoded documents old and new in
+// document order, except that elements removed from the end of an array are
+// reported last first, so that the changes apply in order as a JSON Patch.
+// A nil result stands for a missing document, e.g. a file that was created
+// or deleted, whose root is then reported as added or removed. Object
+// members are compared by name and array elements by index
+func Compare(old, new *decoder.Result, opts Options) []Change {
+	c := &comparison{payloads: make(map[string]string), ignore: opts.Ignore}
+	for _, result := range []*decoder.Result{old, new} {
+		if result == nil {
+			continue
+		}
+		for _, annotation := range result.Annotations {
+			if annotation.Codec != "" {
+				c.payloads[annotation.Path] = annotation.Codec
+			}
+		}
+	}
+
+	switch {
+	case old == nil && new == nil:
+	case old == nil:
+		c.add(Added, location{"$", ""}, nil, new.Value)
+	case new == nil:
+		c.add(Removed, location{"$", ""}, old.Value, nil)
+	default:
+		c.compare(location{"$", ""}, old.Value, new.Value)
+	}
+	return c.changes
+}
+
+// comparison collects the changes between two documents
+type comparison struct {
+	// payloads maps the paths of values decoded in either document to
+	// their codec
+	payloads map[string]string
+	ignore   []decoder.Pattern
+
+	changes []Change
+}
+
+// location is the JSONPath and JSON Pointer of a value
+type location struct {
+	path    string
+	pointer string
+}
+
+// member returns the location of member name of the value at l
+func (l location) member(name string) location {
+	escaped := strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+	return location{decoder.MemberPath(l.path, name), l.pointer + "/" + escaped}
+}
+
+// index returns the location of element i of the array at l
+func (l location) index(i int) location {
+	return location{decoder.IndexPath(l.path, i), l.pointer + "/" + strconv.Itoa(i)}
+}
+
+// compare compares the values at l
+func (c *comparison) compare(l location, old, new any) {
+	if c.ignored(l) {
+		return
+	}
+
+	switch o := old.(type) {
+	case map[string]any:
+		if n, ok := new.(map[string]any); ok {
+			c.compareObjects(l, o, n)
+			return
+		}
+	case []any:
+		if n, ok := new.([]any); ok {
+			c.compareArrays(l, o, n)
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(old, new) {
+		c.add(Changed, l, old, new)
+	}
+}
+
+// ignored reports whether changes at l are left out
+func (c *comparison) ignored(l location) bool {
+	for _, p := range c.ignore {
+		if p.Covers(l.path) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareObjects compares the members of two objects in order of their names
+func (c *comparison) compareObjects(l location, old, new map[string]any) {
+	names := make([]string, 0, len(old)+len(new))
+	for name := range old {
+		names = append(names, name)
+	}
+	for name := range new {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		member := l.member(name)
+		o, inOld := old[name]
+		n, inNew := new[name]
+		switch {
+		case !inNew:
+			c.add(Removed, member, o, nil)
+		case !inOld:
+			c.add(Added, member, nil, n)
+		default:
+			c.compare(member, o, n)
+		}
+	}
+}
+
+// compareArrays compares the elements of two arrays index by index
+func (c *comparison) compareArrays(l location, old, new []any) {
+	for i := range min(len(old), len(new)) {
+		c.compare(l.index(i), old[i], new[i])
+	}
+	for i := len(old); i < len(new); i++ {
+		c.add(Added, l.index(i), nil, new[i])
+	}
+	// Removing the last element first keeps the indexes of the others valid
+	for i := len(old) - 1; i >= len(new); i-- {
+		c.add(Removed, l.index(i), old[i], nil)
+	}
+}
+
+// add records a change along with the payloads enclosing it, unless it is
+// ignored
+func (c *comparison) add(kind Kind, l location, old, new any) {
+	if c.ignored(l) {
+		return
+	}
+	c.changes = append(c.changes, Change{
+		Kind:     kind,
+		Path:     l.path,
+		Pointer:  l.pointer,
+		Old:      old,
+		New:      new,
+		Payloads: c.enclosing(l.path),
+	})
+}
+
+// enclosing returns the decoded values at path or above it, outermost first
+func (c *comparison) enclosing(path string) []Payload {
+	var payloads []Payload
+	for p, codec := range c.payloads {
+		if within(path, p) {
+			payloads = append(payloads, Payload{Path: p, Codec: codec})
+		}
+	}
+	slices.SortFunc(payloads, func(a, b Payload) int { return len(a.Path) - len(b.Path) })
+	return payloads
+}
+
+// within reports whether path is ancestor or one of its descendants
+func within(path, ancestor string) bool {
+	rest, ok := strings.CutPrefix(path, ancestor)
+	return ok && (rest == "" || rest[0] == '.' || rest[0] == '[')
+}
+
+// WriteText writes changes one per line, marked with "+" when added, "-"
+// when removed and "~" when changed, followed by the decoded payloads they
+// are inside of:
+//
+//	~ $.event.user.role: "viewer" → "admin"  (in $.event base64|json)
+func WriteText(w io.Writer, changes []Change) error {
+	for _, change := range changes {
+		var line string
+		switch change.Kind {
+		case Added:
+			line = fmt.Sprintf("+ %s: %s", change.Path, compact(change.New))
+		case Removed:
+			line = fmt.Sprintf("- %s: %s", change.Path, compact(change.Old))
+		default:
+			line = fmt.Sprintf("~ %s: %s → %s", change.Path, compact(change.Old), compact(change.New))
+		}
+
+		if len(change.Payloads) > 0 {
+			payloads := make([]string, len(change.Payloads))
+			for i, payload := range change.Payloads {
+				payloads[i] = payload.Path + " " + payload.Codec
+			}
+			line += "  (in " + strings.Join(payloads, ", ") + ")"
+		}
+
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalJSON renders a change as {"kind", "path", "pointer", "old", "new",
+// "payloads"}, leaving out old for added values and new for removed ones
+func (c Change) MarshalJSON() ([]byte, error) {
+	fields := []string{
+		`"kind":` + compact(c.Kind),
+		`"path":` + compact(c.Path),
+		`"pointer":` + compact(c.Pointer),
+	}
+	if c.Kind != Added {
+		fields = append(fields, `"old":`+compact(c.Old))
+	}
+	if c.Kind != Removed {
+		fields = append(fields, `"new":`+compact(c.New))
+	}
+	if len(c.Payloads) > 0 {
+		fields = append(fields, `"payloads":`+compact(c.Payloads))
+	}
+	return []byte("{" + strings.Join(fields, ",") + "}"), nil
+}
+
+// WriteJSON writes changes as a JSON array of the objects of MarshalJSON
+func WriteJSON(w io.Writer, changes []Change) error {
+	if changes == nil {
+		changes = []Change{}
+	}
+	_, err := fmt.Fprintln(w, compact(changes))
+	return err
+}
+
+// operation is a JSON Patch operation
+type operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// WritePatch writes changes as a JSON Patch (RFC 6902) turning the decoded
+// old document into the decoded new one, except for ignored paths
+func WritePatch(w io.Writer, changes []Change) error {
+	ops := make([]operation, 0, len(changes))
+	for _, change := range changes {
+		switch change.Kind {
+		case Added:
+			ops = append(ops, operation{Op: "add", Path: change.Pointer, Value: json.RawMessage(compact(change.New))})
+		case Removed:
+			ops = append(ops, operation{Op: "remove", Path: change.Pointer})
+		default:
+			ops = append(ops, operation{Op: "replace", Path: change.Pointer, Value: json.RawMessage(compact(change.New))})
+		}
+	}
+	_, err := fmt.Fprintln(w, compact(ops))
+	return err
+}
+
+// compact renders a value as compact JSON
+func compact(value any) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return fmt.Sprint(value)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
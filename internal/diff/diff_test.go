@@ -0,0 +1,152 @@
+package diff_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+	"github.com/vitorhrmiranda/jbdecoder/internal/diff"
+)
+
+// decode decodes a document built around an encoded payload
+func decode(t *testing.T, document, payload string) *decoder.Result {
+	t.Helper()
+
+	var data any
+	encoded := base64.StdEncoding.EncodeToString([]byte(payload))
+	if err := json.Unmarshal([]byte(strings.ReplaceAll(document, "PAYLOAD", encoded)), &data); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	d, err := decoder.New(decoder.Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result, err := d.Decode(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return &result
+}
+
+func Test_Compare(t *testing.T) {
+	old := decode(t, `{"id": 1, "event": "PAYLOAD", "gone": true}`,
+		`{"user": {"role": "viewer", "name": "ana"}, "tags": ["a", "b"]}`)
+	new := decode(t, `{"id": 1, "event": "PAYLOAD", "added": [1]}`,
+		`{"user": {"role": "admin", "name": "ana"}, "tags": ["a"]}`)
+
+	testCases := []struct {
+		name     string
+		old      *decoder.Result
+		new      *decoder.Result
+		expected string
+	}{
+		{
+			name: "changes inside payload",
+			old:  old,
+			new:  new,
+			expected: `+ $.added: [1]
+- $.event.tags[1]: "b"  (in $.event base64|json)
+~ $.event.user.role: "viewer" → "admin"  (in $.event base64|json)
+- $.gone: true
+`,
+		},
+		{
+			name:     "identical",
+			old:      old,
+			new:      old,
+			expected: "",
+		},
+		{
+			name:     "created",
+			old:      nil,
+			new:      decode(t, `{"a": 1}`, ""),
+			expected: "+ $: {\"a\":1}\n",
+		},
+		{
+			name:     "deleted",
+			old:      decode(t, `[1]`, ""),
+			new:      nil,
+			expected: "- $: [1]\n",
+		},
+		{
+			name:     "type change",
+			old:      decode(t, `{"a": {"b": 1}}`, ""),
+			new:      decode(t, `{"a": ["<b>"]}`, ""),
+			expected: "~ $.a: {\"b\":1} → [\"<b>\"]\n",
+		},
+		{
+			name:     "decoded value itself",
+			old:      decode(t, `{"note": "PAYLOAD"}`, "first version of the note"),
+			new:      decode(t, `{"note": "PAYLOAD"}`, "second version of the note"),
+			expected: "~ $.note: \"first version of the note\" → \"second version of the note\"  (in $.note base64)\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := diff.WriteText(&b, diff.Compare(tc.old, tc.new, diff.Options{})); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if b.String() != tc.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s", tc.expected, b.String())
+			}
+		})
+	}
+}
+
+func Test_Compare_Ignore(t *testing.T) {
+	old := decode(t, `{"ts": 1, "event": "PAYLOAD"}`, `{"sent": "10:00", "items": [{"at": 1, "id": "a"}]}`)
+	new := decode(t, `{"ts": 2, "event": "PAYLOAD"}`, `{"sent": "10:05", "items": [{"at": 2, "id": "b"}]}`)
+
+	var ignore []decoder.Pattern
+	for _, pattern := range []string{"$.ts", "$..sent", "$.event.items[*].at"} {
+		p, err := decoder.ParsePattern(pattern)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		ignore = append(ignore, p)
+	}
+
+	changes := diff.Compare(old, new, diff.Options{Ignore: ignore})
+	if len(changes) != 1 || changes[0].Path != "$.event.items[0].id" {
+		t.Errorf("Expected only $.event.items[0].id to change, Got: %+v", changes)
+	}
+}
+
+func Test_WriteJSON_WritePatch(t *testing.T) {
+	old := decode(t, `{"a/b": "PAYLOAD", "list": [1, 2, 3], "gone": null}`, `{"n": false}`)
+	new := decode(t, `{"a/b": "PAYLOAD", "list": [1], "new~": null}`, `{"n": true}`)
+	changes := diff.Compare(old, new, diff.Options{})
+
+	var b strings.Builder
+	if err := diff.WriteJSON(&b, changes); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `[{"kind":"changed","path":"$['a/b'].n","pointer":"/a~1b/n","old":false,"new":true,"payloads":[{"path":"$['a/b']","codec":"base64|json"}]},` +
+		`{"kind":"removed","path":"$.gone","pointer":"/gone","old":null},` +
+		`{"kind":"removed","path":"$.list[2]","pointer":"/list/2","old":3},` +
+		`{"kind":"removed","path":"$.list[1]","pointer":"/list/1","old":2},` +
+		`{"kind":"added","path":"$['new~']","pointer":"/new~0","new":null}]` + "\n"
+	if b.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, b.String())
+	}
+
+	b.Reset()
+	if err := diff.WritePatch(&b, changes); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected = `[{"op":"replace","path":"/a~1b/n","value":true},{"op":"remove","path":"/gone"},` +
+		`{"op":"remove","path":"/list/2"},{"op":"remove","path":"/list/1"},{"op":"add","path":"/new~0","value":null}]` + "\n"
+	if b.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, b.String())
+	}
+
+	b.Reset()
+	if err := diff.WriteJSON(&b, nil); err != nil || b.String() != "[]\n" {
+		t.Errorf("Expected an empty array, Got: %s (%v)", b.String(), err)
+	}
+}
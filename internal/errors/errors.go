@@ -1,5 +1,12 @@
 package errors
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/jsonlite"
+)
+
 // ArgumentError represents an error related to command-line arguments
 type ArgumentError struct {
 	message string
@@ -20,3 +27,178 @@ var (
 	ErrEmptyInput      = NewArgumentError("empty input provided")
 	ErrNoInputProvided = NewArgumentError("no input provided")
 )
+
+// Names of the processing limits reported by LimitError
+const (
+	LimitDepth      = "max depth"
+	LimitInputSize  = "max input size"
+	LimitFieldSize  = "max field size"
+	LimitOutputSize = "max output size"
+)
+
+// LimitError represents a configured processing limit being exceeded
+type LimitError struct {
+	limit string
+	max   int64
+	path  string
+}
+
+// NewLimitError creates a new LimitError for the named limit and its value
+func NewLimitError(limit string, max int64) LimitError {
+	return LimitError{limit: limit, max: max}
+}
+
+// At returns a copy of the error located at the given JSON path
+func (e LimitError) At(path string) LimitError {
+	e.path = path
+	return e
+}
+
+// Limit returns the name of the exceeded limit
+func (e LimitError) Limit() string {
+	return e.limit
+}
+
+// Path returns the JSON path where the limit was exceeded, if known
+func (e LimitError) Path() string {
+	return e.path
+}
+
+// Error implements the error interface for LimitError
+func (e LimitError) Error() string {
+	message := e.limit + " exceeded"
+	if e.max > 0 {
+		message = fmt.Sprintf("%s of %d exceeded", e.limit, e.max)
+	}
+	if e.path != "" {
+		message += " at " + e.path
+	}
+	return message
+}
+
+// Is reports whether target is a LimitError for the same limit, so that
+// errors.Is matches the sentinels regardless of value and path
+func (e LimitError) Is(target error) bool {
+	t, ok := target.(LimitError)
+	return ok && t.limit == e.limit
+}
+
+// Sentinels for errors.Is comparisons against limit errors
+var (
+	ErrMaxDepth       = NewLimitError(LimitDepth, 0)
+	ErrInputTooLarge  = NewLimitError(LimitInputSize, 0)
+	ErrFieldTooLarge  = NewLimitError(LimitFieldSize, 0)
+	ErrOutputTooLarge = NewLimitError(LimitOutputSize, 0)
+)
+
+// RejectReason classifies why a codec did not decode a value
+type RejectReason struct {
+	code    string
+	message string
+}
+
+// NewRejectReason creates a new RejectReason with a stable code and a message
+func NewRejectReason(code, message string) RejectReason {
+	return RejectReason{code: code, message: message}
+}
+
+// Code returns the stable machine-readable identifier of the reason
+func (r RejectReason) Code() string {
+	return r.code
+}
+
+// Error implements the error interface for RejectReason
+func (r RejectReason) Error() string {
+	return r.message
+}
+
+// Reasons reported in decode diagnostics
+var (
+	ErrTooShort             = NewRejectReason("too_short", "shorter than the minimum Base64 length")
+	ErrBadLength            = NewRejectReason("bad_length", "length is not a multiple of 4")
+	ErrInvalidEncoding      = NewRejectReason("invalid_encoding", "invalid encoding")
+	ErrNotText              = NewRejectReason("not_text", "decoded bytes are not text")
+	ErrInvalidJSON          = NewRejectReason("invalid_json", "decoded text looks like JSON but does not parse")
+	ErrUnsupportedMediaType = NewRejectReason("unsupported_media_type", "media type is neither text nor JSON")
+	ErrLimitExceeded        = NewRejectReason("limit_exceeded", "processing limit exceeded")
+	ErrNotString            = NewRejectReason("not_string", "value is not a string")
+)
+
+// DecodeError describes a value at a JSON path that a codec did not decode
+type DecodeError struct {
+	Path   string
+	Codec  string
+	Reason RejectReason
+	Err    error
+}
+
+// NewDecodeError creates a new DecodeError, where err optionally holds the
+// underlying cause such as a Base64 or JSON syntax error
+func NewDecodeError(path, codec string, reason RejectReason, err error) DecodeError {
+	return DecodeError{Path: path, Codec: codec, Reason: reason, Err: err}
+}
+
+// Error implements the error interface for DecodeError
+func (e DecodeError) Error() string {
+	message := fmt.Sprintf("%s: %s: %s", e.Path, e.Codec, e.Reason.Error())
+	if e.Err != nil {
+		message += ": " + e.Err.Error()
+	}
+	return message
+}
+
+// Unwrap exposes both the reason and the cause to errors.Is and errors.As
+func (e DecodeError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Reason}
+	}
+	return []error{e.Reason, e.Err}
+}
+
+// MarshalJSON renders the error as an object with the reason code
+func (e DecodeError) MarshalJSON() ([]byte, error) {
+	// Written by hand so that TinyGo builds need no encoding/json
+	b := []byte(`{"path":`)
+	b = jsonlite.AppendString(b, e.Path)
+	b = append(b, `,"codec":`...)
+	b = jsonlite.AppendString(b, e.Codec)
+	b = append(b, `,"reason":`...)
+	b = jsonlite.AppendString(b, e.Reason.Code())
+	b = append(b, `,"message":`...)
+	b = jsonlite.AppendString(b, e.Reason.Error())
+	if e.Err != nil && e.Err.Error() != "" {
+		b = append(b, `,"detail":`...)
+		b = jsonlite.AppendString(b, e.Err.Error())
+	}
+	return append(b, '}'), nil
+}
+
+// RuleError reports a value that the codec chain declared by a rule did not
+// decode. It names the pattern and chain of the rule along with the failing
+// step, and unwraps to a DecodeError whose Codec is the chain up to that step
+type RuleError struct {
+	DecodeError
+	Rule  string
+	Chain string
+}
+
+// NewRuleError creates a RuleError for the rule declaring chain at the paths
+// matching pattern
+func NewRuleError(pattern, chain string, err DecodeError) RuleError {
+	return RuleError{DecodeError: err, Rule: pattern, Chain: chain}
+}
+
+// Error implements the error interface for RuleError
+func (e RuleError) Error() string {
+	step := e.Codec[strings.LastIndexByte(e.Codec, '|')+1:]
+	cause := e.Reason.Error()
+	if e.Err != nil {
+		cause = e.Err.Error()
+	}
+	return fmt.Sprintf("%s: rule %s=%s: %s step failed: %s", e.Path, e.Rule, e.Chain, step, cause)
+}
+
+// Unwrap exposes the DecodeError, and through it the reason and cause
+func (e RuleError) Unwrap() error {
+	return e.DecodeError
+}
@@ -0,0 +1,64 @@
+package errors_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	errs "github.com/vitorhrmiranda/jbdecoder/internal/errors"
+)
+
+func Test_LimitError(t *testing.T) {
+	err := errs.NewLimitError(errs.LimitFieldSize, 1024).At("$.payload")
+
+	expected := "max field size of 1024 exceeded at $.payload"
+	if err.Error() != expected {
+		t.Errorf("Expected: %s, Got: %s", expected, err.Error())
+	}
+	if !errors.Is(err, errs.ErrFieldTooLarge) {
+		t.Errorf("Expected the error to match ErrFieldTooLarge")
+	}
+	if errors.Is(err, errs.ErrMaxDepth) {
+		t.Errorf("Expected the error not to match ErrMaxDepth")
+	}
+}
+
+func Test_DecodeError(t *testing.T) {
+	_, cause := base64.StdEncoding.DecodeString("SGVs!G8=")
+	err := errs.NewDecodeError("$.data", "base64", errs.ErrInvalidEncoding, cause)
+
+	expected := "$.data: base64: invalid encoding: illegal base64 data at input byte 4"
+	if err.Error() != expected {
+		t.Errorf("Expected: %s, Got: %s", expected, err.Error())
+	}
+
+	var corrupt base64.CorruptInputError
+	if !errors.Is(err, errs.ErrInvalidEncoding) || !errors.As(err, &corrupt) {
+		t.Errorf("Expected the error to unwrap to its reason and cause")
+	}
+
+	output, _ := json.Marshal(err)
+	expectedJSON := `{"path":"$.data","codec":"base64","reason":"invalid_encoding",` +
+		`"message":"invalid encoding","detail":"illegal base64 data at input byte 4"}`
+	if string(output) != expectedJSON {
+		t.Errorf("Expected: %s, Got: %s", expectedJSON, output)
+	}
+}
+
+func Test_RuleError(t *testing.T) {
+	var syntax *json.SyntaxError
+	cause := json.Unmarshal([]byte("Hello"), new(any))
+	err := errs.NewRuleError("$.events[*].body", "base64|json",
+		errs.NewDecodeError("$.events[0].body", "base64|json", errs.ErrInvalidJSON, cause))
+
+	expected := "$.events[0].body: rule $.events[*].body=base64|json: json step failed: invalid character 'H' looking for beginning of value"
+	if err.Error() != expected {
+		t.Errorf("Expected: %s, Got: %s", expected, err.Error())
+	}
+
+	var decodeErr errs.DecodeError
+	if !errors.As(err, &decodeErr) || !errors.Is(err, errs.ErrInvalidJSON) || !errors.As(err, &syntax) {
+		t.Errorf("Expected the error to unwrap to its DecodeError, reason and cause")
+	}
+}
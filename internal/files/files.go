@@ -0,0 +1,156 @@
+// Package files resolves command-line inputs to files and rewrites them
+// safely
+package files
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Extensions are the file extensions picked up when walking directories
+var Extensions = []string{".json"}
+
+// globChars are the characters that make an argument a glob pattern
+const globChars = "*?["
+
+// BackupSuffix is appended to the name of a file's backup copy
+const BackupSuffix = ".bak"
+
+// IsPattern reports whether an argument is a glob pattern or a directory,
+// i.e. whether it may stand for more than one file
+func IsPattern(arg string) bool {
+	if strings.ContainsAny(arg, globChars) {
+		return true
+	}
+	info, err := os.Stat(arg)
+	return err == nil && info.IsDir()
+}
+
+// Expand resolves arguments to a list of files in argument order. Glob
+// patterns are expanded, directories are walked recursively for files with
+// one of the Extensions and other arguments are taken as file names. Each
+// file is listed once, even when several arguments match it
+func Expand(args []string) ([]string, error) {
+	var paths []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	for _, arg := range args {
+		matches := []string{arg}
+		if strings.ContainsAny(arg, globChars) {
+			var err error
+			matches, err = filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern '%s': %w", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match '%s'", arg)
+			}
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open file '%s': %w", match, err)
+			}
+			if !info.IsDir() {
+				add(match)
+				continue
+			}
+
+			found, err := walk(match)
+			if err != nil {
+				return nil, err
+			}
+			for _, path := range found {
+				add(path)
+			}
+		}
+	}
+
+	return paths, nil
+}
+
+// walk lists the files with one of the Extensions below dir in lexical
+// order, skipping hidden directories such as .git
+func walk(dir string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != dir && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if slices.Contains(Extensions, strings.ToLower(filepath.Ext(path))) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory '%s': %w", dir, err)
+	}
+	return paths, nil
+}
+
+// Rewrite replaces the contents of a file with data, keeping its
+// permissions. The data is written to a temporary file that is renamed over
+// the original, so the file is never left half-written. A symbolic link is
+// followed, so that its target is rewritten rather than replaced by a
+// regular file. With backup the original is first copied to the file name
+// with BackupSuffix
+func Rewrite(path string, data []byte, backup bool) (err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if backup {
+		original, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path+BackupSuffix, original, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), target)
+}
@@ -0,0 +1,113 @@
+package files_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/files"
+)
+
+const testFilePerms = 0o640
+
+// writeFiles creates files with the given contents below dir
+func writeFiles(t *testing.T, dir string, contents map[string]string) {
+	t.Helper()
+	for name, content := range contents {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), testFilePerms); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func Test_Expand(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.json":          "{}",
+		"b.json":          "{}",
+		"notes.txt":       "",
+		"nested/c.json":   "{}",
+		"nested/d/e.JSON": "{}",
+		".git/f.json":     "{}",
+	})
+
+	paths, err := files.Expand([]string{
+		filepath.Join(dir, "b.json"),
+		filepath.Join(dir, "*.json"),
+		filepath.Join(dir, "nested"),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{
+		filepath.Join(dir, "b.json"),
+		filepath.Join(dir, "a.json"),
+		filepath.Join(dir, "nested", "c.json"),
+		filepath.Join(dir, "nested", "d", "e.JSON"),
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected: %v, Got: %v", expected, paths)
+	}
+
+	if _, err := files.Expand([]string{filepath.Join(dir, "*.yaml")}); err == nil {
+		t.Errorf("Expected an error for a pattern without matches")
+	}
+	if _, err := files.Expand([]string{filepath.Join(dir, "missing.json")}); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}
+
+func Test_Rewrite(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"data.json": `{"a":"b"}`})
+	path := filepath.Join(dir, "data.json")
+
+	if err := files.Rewrite(path, []byte(`{"a":"c"}`), true); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if data, _ := os.ReadFile(path); string(data) != `{"a":"c"}` {
+		t.Errorf("Expected the file to be rewritten, Got: %s", data)
+	}
+	if data, _ := os.ReadFile(path + files.BackupSuffix); string(data) != `{"a":"b"}` {
+		t.Errorf("Expected a backup of the original, Got: %s", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != testFilePerms {
+		t.Errorf("Expected permissions to be kept, Got: %v", info.Mode())
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("Expected no temporary files to be left, Got: %v", entries)
+	}
+}
+
+func Test_Rewrite_Symlink(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"data.json": `{"a":"b"}`})
+	target := filepath.Join(dir, "data.json")
+	link := filepath.Join(t.TempDir(), "link.json")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("Symbolic links are not supported: %v", err)
+	}
+
+	if err := files.Rewrite(link, []byte(`{"a":"c"}`), false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Expected the link to be kept, Got: %v (%v)", info, err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != `{"a":"c"}` {
+		t.Errorf("Expected the target to be rewritten, Got: %s", data)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected no temporary files to be left, Got: %v", entries)
+	}
+}
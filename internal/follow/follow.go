@@ -0,0 +1,174 @@
+// Package follow reads lines appended to a growing file, like tail -F
+package follow
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// DefaultInterval is how often the file is polled for changes when
+// Options.Interval is not set
+const DefaultInterval = 250 * time.Millisecond
+
+// Options configures a Follower
+type Options struct {
+	// Interval is how often the file is polled once all lines were read.
+	// Zero means DefaultInterval
+	Interval time.Duration
+
+	// FromStart reads the lines already in the file before following it,
+	// instead of starting at its current end
+	FromStart bool
+}
+
+// Follower reads the lines appended to a file. When the file is truncated
+// it is read again from the start, and when it is replaced (e.g. rotated by
+// logrotate) the rest of the old file is read before switching to the new
+// one
+type Follower struct {
+	path     string
+	interval time.Duration
+
+	file    *os.File
+	info    os.FileInfo
+	reader  *bufio.Reader
+	offset  int64
+	partial []byte
+}
+
+// Open starts following the file at path
+func Open(path string, opts Options) (*Follower, error) {
+	f := &Follower{path: path, interval: opts.Interval}
+	if f.interval <= 0 {
+		f.interval = DefaultInterval
+	}
+
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	if !opts.FromStart {
+		offset, err := f.file.Seek(0, io.SeekEnd)
+		if err != nil {
+			_ = f.file.Close()
+			return nil, err
+		}
+		f.offset = offset
+	}
+
+	return f, nil
+}
+
+// open (re)opens the file at the start
+func (f *Follower) open() error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	if f.file != nil {
+		_ = f.file.Close()
+	}
+	f.file, f.info, f.offset, f.partial = file, info, 0, nil
+	f.reader = bufio.NewReader(file)
+	return nil
+}
+
+// Close stops following and closes the file
+func (f *Follower) Close() error {
+	return f.file.Close()
+}
+
+// Lines calls handle for every complete line appended to the file, without
+// its line ending, until ctx is done or handle fails. A line without an
+// ending is only handed over once it is completed
+func (f *Follower) Lines(ctx context.Context, handle func(line []byte) error) error {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := f.drain(handle); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if err := f.check(handle); err != nil {
+			return err
+		}
+	}
+}
+
+// drain hands over every complete line available from the current offset
+func (f *Follower) drain(handle func(line []byte) error) error {
+	if _, err := f.file.Seek(f.offset, io.SeekStart); err != nil {
+		return err
+	}
+	f.reader.Reset(f.file)
+
+	for {
+		chunk, err := f.reader.ReadBytes('\n')
+		f.offset += int64(len(chunk))
+		f.partial = append(f.partial, chunk...)
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		line := bytes.TrimRight(f.partial, "\r\n")
+		f.partial = f.partial[:0]
+		if err := handle(line); err != nil {
+			return err
+		}
+	}
+}
+
+// check detects whether the file was replaced or truncated since it was
+// last read. A file that is briefly missing during rotation is waited for
+func (f *Follower) check(handle func(line []byte) error) error {
+	info, err := os.Stat(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !os.SameFile(info, f.info):
+		// Hand over what was written to the old file before it was
+		// replaced, including a last line that will never be completed
+		if err := f.drain(handle); err != nil {
+			return err
+		}
+		if len(f.partial) > 0 {
+			if err := handle(bytes.TrimRight(f.partial, "\r")); err != nil {
+				return err
+			}
+		}
+		return f.open()
+	case info.Size() < f.offset:
+		f.offset, f.partial = 0, f.partial[:0]
+	}
+
+	return nil
+}
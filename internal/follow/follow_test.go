@@ -0,0 +1,125 @@
+package follow_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/follow"
+)
+
+const (
+	testInterval  = 10 * time.Millisecond
+	testTimeout   = 2 * time.Second
+	testFilePerms = 0o600
+)
+
+// appendFile appends content to the file at path, creating it if needed
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, testFilePerms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expectLines waits until the follower handed over the expected lines
+func expectLines(t *testing.T, lines <-chan string, expected ...string) {
+	t.Helper()
+	var actual []string
+	timeout := time.After(testTimeout)
+	for len(actual) < len(expected) {
+		select {
+		case line := <-lines:
+			actual = append(actual, line)
+		case <-timeout:
+			t.Fatalf("Expected: %q, Got: %q", expected, actual)
+		}
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected: %q, Got: %q", expected, actual)
+	}
+}
+
+func Test_Follower(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "old line\n")
+
+	f, err := follow.Open(path, follow.Options{Interval: testInterval})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	lines := make(chan string, 16)
+	done := make(chan error)
+	go func() {
+		done <- f.Lines(ctx, func(line []byte) error {
+			lines <- string(line)
+			return nil
+		})
+	}()
+
+	t.Run("appended lines", func(t *testing.T) {
+		appendFile(t, path, "first\nsec")
+		appendFile(t, path, "ond\r\n")
+		expectLines(t, lines, "first", "second")
+	})
+
+	t.Run("truncation", func(t *testing.T) {
+		if err := os.Truncate(path, 0); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * testInterval)
+		appendFile(t, path, "after truncate\n")
+		expectLines(t, lines, "after truncate")
+	})
+
+	t.Run("rotation", func(t *testing.T) {
+		appendFile(t, path, "before rotate\nunterminated")
+		if err := os.Rename(path, path+".1"); err != nil {
+			t.Fatal(err)
+		}
+		appendFile(t, path, "after rotate\n")
+		expectLines(t, lines, "before rotate", "unterminated", "after rotate")
+	})
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func Test_Follower_FromStart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "existing\n")
+
+	f, err := follow.Open(path, follow.Options{Interval: testInterval, FromStart: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	lines := make(chan string, 1)
+	go func() {
+		_ = f.Lines(ctx, func(line []byte) error {
+			lines <- string(line)
+			return nil
+		})
+	}()
+	expectLines(t, lines, "existing")
+
+	if _, err := follow.Open(filepath.Join(t.TempDir(), "missing.log"), follow.Options{}); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}
@@ -0,0 +1,44 @@
+package jsonlite_test
+
+import (
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// reflectionHeavy are packages that defeat the purpose of the TinyGo build
+var reflectionHeavy = []string{"encoding/json", "text/template", "html/template", "encoding/gob"}
+
+// charsetTables are the charset packages of the standard build only, whose
+// tables would make up much of the TinyGo binary
+var charsetTables = []string{
+	"golang.org/x/text/encoding/htmlindex",
+	"golang.org/x/text/encoding/korean",
+	"golang.org/x/text/encoding/simplifiedchinese",
+	"golang.org/x/text/encoding/traditionalchinese",
+}
+
+func Test_TinyGoBuild_Dependencies(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not available")
+	}
+
+	cmd := exec.Command("go", "list", "-deps", "-tags", "tinygo", "../../cmd/wasm-tiny")
+	cmd.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go list failed: %v\n%s", err, output)
+	}
+
+	deps := strings.Fields(string(output))
+	if !slices.Contains(deps, "github.com/vitorhrmiranda/jbdecoder/internal/decoder") {
+		t.Fatalf("expected the decoder among the dependencies, got %v", deps)
+	}
+	for _, pkg := range slices.Concat(reflectionHeavy, charsetTables) {
+		if slices.Contains(deps, pkg) {
+			t.Errorf("TinyGo build depends on %s", pkg)
+		}
+	}
+}
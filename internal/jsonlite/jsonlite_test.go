@@ -0,0 +1,126 @@
+package jsonlite_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/jsonlite"
+)
+
+// documents are parsed by both jsonlite and encoding/json, valid or not
+var documents = []string{
+	`null`, `true`, `false`, `0`, `-0`, `1`, `-12.5e-3`, `1E+2`, `123456789012345678901234567890`,
+	`1e400`, `-1e-400`, `0.1`, `1e-7`, `1e21`, `999999999999999999999`,
+	`""`, `"plain"`, `"esc \" \\ \/ \b \f \n \r \t"`, `"é中😀"`,
+	`"\ud800"`, `"\udc00x"`, `"\ud800A"`, `"\ud800𐀀"`, "\"\xff\xfe invalid\"", "\"caf\xc3\xa9\"",
+	`"<html> & </html>"`, "\"  \"", `"\u0000\u001f\u007f"`,
+	`{}`, `[]`, ` { "a" : [ 1 , 2 , { "b" : null } ] , "c" : "d" } `, `{"a":1,"a":2}`,
+	`[[[[[]]]]]`, `{"":{"":""}}`,
+	``, ` `, `{`, `[`, `"`, `"abc`, `{"a"}`, `{"a":}`, `{"a":1,}`, `[1,]`, `[1 2]`, `{a:1}`,
+	`tru`, `nul`, `falsey`, `01`, `-`, `1.`, `.5`, `1e`, `1e+`, `+1`, `0x10`, `NaN`, `Infinity`,
+	"\"tab\tinside\"", `"\x"`, `"\u12"`, `"\u12G4"`, `[1]x`, `{} {}`, "\x00", `'single'`,
+}
+
+func Test_Parse_Parity(t *testing.T) {
+	for _, document := range documents {
+		assertParseParity(t, []byte(document))
+	}
+
+	deep := strings.Repeat("[", 10000) + strings.Repeat("]", 10000)
+	assertParseParity(t, []byte(deep))
+	assertParseParity(t, []byte("["+deep+"]"))
+}
+
+// assertParseParity checks that jsonlite accepts exactly what encoding/json
+// accepts and produces the same value
+func assertParseParity(t *testing.T, data []byte) {
+	t.Helper()
+
+	var expected any
+	expectedErr := json.Unmarshal(data, &expected)
+	actual, actualErr := jsonlite.Parse(data)
+
+	name := string(data)
+	if len(name) > 40 {
+		name = name[:40] + "..."
+	}
+
+	switch {
+	case (expectedErr == nil) != (actualErr == nil):
+		t.Errorf("%q: Expected error %v, Got: %v", name, expectedErr, actualErr)
+	case expectedErr == nil && !reflect.DeepEqual(expected, actual):
+		t.Errorf("%q: Expected: %#v, Got: %#v", name, expected, actual)
+	}
+}
+
+func Test_Marshal_Parity(t *testing.T) {
+	values := []any{
+		nil, true, false, "", "plain", "<script>alert('x') && \"y\"</script>",
+		"\x00\x01\b\f\n\r\t\x1f\x7f", "  é\U0001F600", "\xff\xfeinvalid\xc3",
+		0.0, math.Copysign(0, -1), 1.0, -1.5, 0.1, 1e-6, 1e-7, 123456789.0, 1e20, 1e21, 1.5e300, -2.5e-300,
+		math.MaxFloat64, math.SmallestNonzeroFloat64, 1.0 / 3,
+		[]any{}, map[string]any{}, []any{1.0, "two", nil, []any{}, map[string]any{"x": []any{}}},
+		map[string]any{"b": 1.0, "a": map[string]any{"z": true, "y": nil}, "<": "&", "": []any{"c"}},
+	}
+
+	for _, value := range values {
+		expected, _ := json.Marshal(value)
+		actual, err := jsonlite.Marshal(value)
+		if err != nil || !bytes.Equal(expected, actual) {
+			t.Errorf("Marshal %#v: Expected: %s, Got: %s (%v)", value, expected, actual, err)
+		}
+
+		expected, _ = json.MarshalIndent(value, "> ", "\t")
+		actual, err = jsonlite.MarshalIndent(value, "> ", "\t")
+		if err != nil || !bytes.Equal(expected, actual) {
+			t.Errorf("MarshalIndent %#v: Expected: %s, Got: %s (%v)", value, expected, actual, err)
+		}
+	}
+
+	for _, value := range []any{math.NaN(), math.Inf(1), struct{}{}, []any{math.Inf(-1)}} {
+		if _, err := jsonlite.Marshal(value); err == nil {
+			t.Errorf("Expected %#v to be rejected", value)
+		}
+	}
+}
+
+func Test_SyntaxError_Offset(t *testing.T) {
+	for _, document := range []string{`{"a": x}`, `[1, 2`, `{"a" 1}`, "\"a\x01\""} {
+		var expected *json.SyntaxError
+		err := json.Unmarshal([]byte(document), new(any))
+		if !errors.As(err, &expected) {
+			t.Fatalf("%q: Expected a json.SyntaxError, Got: %v", document, err)
+		}
+
+		_, err = jsonlite.Parse([]byte(document))
+		var actual *jsonlite.SyntaxError
+		if !errors.As(err, &actual) || actual.Offset != expected.Offset || actual.Error() != expected.Error() {
+			t.Errorf("%q: Expected %d %q, Got: %v", document, expected.Offset, expected.Error(), err)
+		}
+	}
+}
+
+func Fuzz_Parse(f *testing.F) {
+	for _, document := range documents {
+		f.Add([]byte(document))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		assertParseParity(t, data)
+
+		value, err := jsonlite.Parse(data)
+		if err != nil {
+			return
+		}
+		expected, _ := json.Marshal(value)
+		actual, err := jsonlite.Marshal(value)
+		if err != nil || !bytes.Equal(expected, actual) {
+			t.Errorf("Marshal: Expected: %s, Got: %s (%v)", expected, actual, err)
+		}
+	})
+}
@@ -0,0 +1,401 @@
+// Package jsonlite reads and writes JSON without reflection. For untyped
+// data it accepts the same documents and produces the same values and bytes
+// as encoding/json: objects become map[string]any, arrays []any and numbers
+// float64, and written objects have sorted keys and HTML-safe strings. It
+// keeps the decoder core buildable with TinyGo, where encoding/json makes
+// binaries several times larger
+package jsonlite
+
+import (
+	"fmt"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// maxDepth is the nesting limit of encoding/json
+const maxDepth = 10000
+
+// SyntaxError describes invalid JSON. Offset is the number of bytes read
+// before the error was detected, like json.SyntaxError.Offset
+type SyntaxError struct {
+	msg    string
+	Offset int64
+}
+
+// Error implements the error interface for SyntaxError
+func (e *SyntaxError) Error() string {
+	return e.msg
+}
+
+// parser reads one JSON document
+type parser struct {
+	data []byte
+	pos  int
+}
+
+// Parse parses a JSON document into untyped values
+func Parse(data []byte) (any, error) {
+	p := &parser{data: data}
+	p.skipSpace()
+
+	v, err := p.value(0)
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if p.pos < len(p.data) {
+		return nil, p.unexpected("after top-level value")
+	}
+	return v, nil
+}
+
+// errorf creates a SyntaxError at the current position
+func (p *parser) errorf(format string, args ...any) error {
+	return &SyntaxError{msg: fmt.Sprintf(format, args...), Offset: int64(p.pos)}
+}
+
+// unexpected reports the character at the current position, or the end of
+// the input
+func (p *parser) unexpected(context string) error {
+	if p.pos >= len(p.data) {
+		return p.errorf("unexpected end of JSON input")
+	}
+	p.pos++
+	return p.errorf("invalid character %s %s", quoteChar(p.data[p.pos-1]), context)
+}
+
+// skipSpace moves past JSON whitespace
+func (p *parser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+// value parses the value at the current position
+func (p *parser) value(depth int) (any, error) {
+	if p.pos >= len(p.data) {
+		return nil, p.unexpected("")
+	}
+
+	switch c := p.data[p.pos]; {
+	case c == '{':
+		return p.object(depth + 1)
+	case c == '[':
+		return p.array(depth + 1)
+	case c == '"':
+		return p.string()
+	case c == '-' || c >= '0' && c <= '9':
+		return p.number()
+	case c == 't':
+		return true, p.literal("true")
+	case c == 'f':
+		return false, p.literal("false")
+	case c == 'n':
+		return nil, p.literal("null")
+	default:
+		return nil, p.unexpected("looking for beginning of value")
+	}
+}
+
+// literal consumes the keyword true, false or null
+func (p *parser) literal(word string) error {
+	for i := range len(word) {
+		if p.pos >= len(p.data) || p.data[p.pos] != word[i] {
+			return p.unexpected("in literal " + word + " (expecting " + quoteChar(word[i]) + ")")
+		}
+		p.pos++
+	}
+	return nil
+}
+
+// object parses an object whose opening brace is at the current position
+func (p *parser) object(depth int) (any, error) {
+	if depth > maxDepth {
+		return nil, p.errorf("exceeded max depth")
+	}
+	p.pos++
+
+	m := make(map[string]any)
+	p.skipSpace()
+	if p.pos < len(p.data) && p.data[p.pos] == '}' {
+		p.pos++
+		return m, nil
+	}
+
+	for {
+		if p.pos >= len(p.data) || p.data[p.pos] != '"' {
+			return nil, p.unexpected("looking for beginning of object key string")
+		}
+		key, err := p.string()
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipSpace()
+		if p.pos >= len(p.data) || p.data[p.pos] != ':' {
+			return nil, p.unexpected("after object key")
+		}
+		p.pos++
+		p.skipSpace()
+
+		value, err := p.value(depth)
+		if err != nil {
+			return nil, err
+		}
+		m[key.(string)] = value
+
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return nil, p.unexpected("")
+		}
+		switch p.data[p.pos] {
+		case ',':
+			p.pos++
+			p.skipSpace()
+		case '}':
+			p.pos++
+			return m, nil
+		default:
+			return nil, p.unexpected("after object key:value pair")
+		}
+	}
+}
+
+// array parses an array whose opening bracket is at the current position
+func (p *parser) array(depth int) (any, error) {
+	if depth > maxDepth {
+		return nil, p.errorf("exceeded max depth")
+	}
+	p.pos++
+
+	s := []any{}
+	p.skipSpace()
+	if p.pos < len(p.data) && p.data[p.pos] == ']' {
+		p.pos++
+		return s, nil
+	}
+
+	for {
+		value, err := p.value(depth)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, value)
+
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return nil, p.unexpected("")
+		}
+		switch p.data[p.pos] {
+		case ',':
+			p.pos++
+			p.skipSpace()
+		case ']':
+			p.pos++
+			return s, nil
+		default:
+			return nil, p.unexpected("after array element")
+		}
+	}
+}
+
+// number parses a number at the current position as a float64
+func (p *parser) number() (any, error) {
+	start := p.pos
+	if p.data[p.pos] == '-' {
+		p.pos++
+	}
+
+	switch {
+	case p.pos < len(p.data) && p.data[p.pos] == '0':
+		p.pos++
+	case p.pos < len(p.data) && p.data[p.pos] >= '1' && p.data[p.pos] <= '9':
+		p.digits()
+	default:
+		return nil, p.unexpected("in numeric literal")
+	}
+
+	if p.pos < len(p.data) && p.data[p.pos] == '.' {
+		p.pos++
+		if p.pos >= len(p.data) || !isDigit(p.data[p.pos]) {
+			return nil, p.unexpected("after decimal point in numeric literal")
+		}
+		p.digits()
+	}
+
+	if p.pos < len(p.data) && (p.data[p.pos] == 'e' || p.data[p.pos] == 'E') {
+		p.pos++
+		if p.pos < len(p.data) && (p.data[p.pos] == '+' || p.data[p.pos] == '-') {
+			p.pos++
+		}
+		if p.pos >= len(p.data) || !isDigit(p.data[p.pos]) {
+			return nil, p.unexpected("in exponent of numeric literal")
+		}
+		p.digits()
+	}
+
+	literal := string(p.data[start:p.pos])
+	f, err := strconv.ParseFloat(literal, 64)
+	if err != nil {
+		return nil, &SyntaxError{msg: "number " + literal + " is not a float64", Offset: int64(p.pos)}
+	}
+	return f, nil
+}
+
+// digits moves past a run of decimal digits
+func (p *parser) digits() {
+	for p.pos < len(p.data) && isDigit(p.data[p.pos]) {
+		p.pos++
+	}
+}
+
+// isDigit reports whether c is a decimal digit
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// string parses a string whose opening quote is at the current position.
+// Invalid UTF-8 and unpaired surrogates become U+FFFD like in encoding/json
+func (p *parser) string() (any, error) {
+	p.pos++
+	start := p.pos
+
+	// Fast path for strings without escapes or non-ASCII characters
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if c == '"' {
+			p.pos++
+			return string(p.data[start : p.pos-1]), nil
+		}
+		if c == '\\' || c < ' ' || c >= utf8.RuneSelf {
+			break
+		}
+		p.pos++
+	}
+
+	b := make([]byte, 0, p.pos-start+16)
+	b = append(b, p.data[start:p.pos]...)
+
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			return string(b), nil
+
+		case c < ' ':
+			p.pos++
+			return nil, p.errorf("invalid character %s in string", quoteChar(c))
+
+		case c == '\\':
+			p.pos++
+			if p.pos >= len(p.data) {
+				return nil, p.unexpected("")
+			}
+
+			switch e := p.data[p.pos]; e {
+			case '"', '\\', '/':
+				b = append(b, e)
+			case 'b':
+				b = append(b, '\b')
+			case 'f':
+				b = append(b, '\f')
+			case 'n':
+				b = append(b, '\n')
+			case 'r':
+				b = append(b, '\r')
+			case 't':
+				b = append(b, '\t')
+			case 'u':
+				r, err := p.hex4()
+				if err != nil {
+					return nil, err
+				}
+				if utf16.IsSurrogate(r) {
+					r = p.lowSurrogate(r)
+				}
+				b = utf8.AppendRune(b, r)
+				continue
+			default:
+				return nil, p.unexpected("in string escape code")
+			}
+			p.pos++
+
+		case c < utf8.RuneSelf:
+			b = append(b, c)
+			p.pos++
+
+		default:
+			r, size := utf8.DecodeRune(p.data[p.pos:])
+			p.pos += size
+			b = utf8.AppendRune(b, r)
+		}
+	}
+
+	return nil, p.unexpected("")
+}
+
+// hex4 reads the four hex digits of a \u escape, leaving the position
+// after them
+func (p *parser) hex4() (rune, error) {
+	var r rune
+	for range 4 {
+		p.pos++
+		if p.pos >= len(p.data) {
+			return 0, p.unexpected("")
+		}
+
+		c := p.data[p.pos]
+		switch {
+		case c >= '0' && c <= '9':
+			c -= '0'
+		case c >= 'a' && c <= 'f':
+			c -= 'a' - 10
+		case c >= 'A' && c <= 'F':
+			c -= 'A' - 10
+		default:
+			return 0, p.unexpected("in \\u hexadecimal character escape")
+		}
+		r = r<<4 | rune(c)
+	}
+	p.pos++
+	return r, nil
+}
+
+// lowSurrogate combines a surrogate with the \u escape that follows it,
+// returning U+FFFD and leaving the escape unread when they do not pair
+func (p *parser) lowSurrogate(high rune) rune {
+	if p.pos+6 > len(p.data) || p.data[p.pos] != '\\' || p.data[p.pos+1] != 'u' {
+		return utf8.RuneError
+	}
+
+	saved := p.pos
+	p.pos++
+	low, err := p.hex4()
+	if err == nil {
+		if r := utf16.DecodeRune(high, low); r != utf8.RuneError {
+			return r
+		}
+	}
+	p.pos = saved
+	return utf8.RuneError
+}
+
+// quoteChar formats c for error messages like encoding/json
+func quoteChar(c byte) string {
+	switch c {
+	case '\'':
+		return `'\''`
+	case '"':
+		return `'"'`
+	}
+	s := strconv.Quote(string(rune(c)))
+	return "'" + s[1:len(s)-1] + "'"
+}
@@ -0,0 +1,221 @@
+package jsonlite
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+)
+
+// hexDigits are used for \u escapes
+const hexDigits = "0123456789abcdef"
+
+// Marshal writes untyped data as compact JSON like json.Marshal: object keys
+// are sorted and <, >, & and the line separators U+2028 and U+2029 are
+// escaped
+func Marshal(v any) ([]byte, error) {
+	w := writer{}
+	if err := w.value(v, 0); err != nil {
+		return nil, err
+	}
+	return w.buf, nil
+}
+
+// MarshalIndent writes untyped data like json.MarshalIndent, starting each
+// line after the first with prefix followed by one copy of indent per
+// nesting level
+func MarshalIndent(v any, prefix, indent string) ([]byte, error) {
+	w := writer{prefix: prefix, indent: indent, pretty: true}
+	if err := w.value(v, 0); err != nil {
+		return nil, err
+	}
+	return w.buf, nil
+}
+
+// AppendString appends s as a JSON string literal, escaped like json.Marshal
+func AppendString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= ' ' && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+
+			dst = append(dst, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				dst = append(dst, '\\', c)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, "\ufffd"...)
+		case r == '\u2028' || r == '\u2029':
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+		default:
+			i += size
+			continue
+		}
+		i += size
+		start = i
+	}
+
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
+
+// AppendFloat appends a number formatted like json.Marshal formats float64
+func AppendFloat(dst []byte, f float64) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return dst, fmt.Errorf("json: unsupported value: %s", strconv.FormatFloat(f, 'g', -1, 64))
+	}
+
+	// Like ES6, use exponents only for very small and very large numbers
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+
+	dst = strconv.AppendFloat(dst, f, format, -1, 64)
+	if format == 'e' {
+		// Clean up e-09 to e-9
+		n := len(dst)
+		if n >= 4 && dst[n-4] == 'e' && dst[n-3] == '-' && dst[n-2] == '0' {
+			dst[n-2] = dst[n-1]
+			dst = dst[:n-1]
+		}
+	}
+	return dst, nil
+}
+
+// writer accumulates the output of Marshal and MarshalIndent
+type writer struct {
+	buf    []byte
+	prefix string
+	indent string
+	pretty bool
+}
+
+// newline starts a new line at the given nesting level when indenting
+func (w *writer) newline(depth int) {
+	if !w.pretty {
+		return
+	}
+	w.buf = append(w.buf, '\n')
+	w.buf = append(w.buf, w.prefix...)
+	for range depth {
+		w.buf = append(w.buf, w.indent...)
+	}
+}
+
+// value writes any supported value
+func (w *writer) value(v any, depth int) error {
+	switch v := v.(type) {
+	case nil:
+		w.buf = append(w.buf, "null"...)
+	case bool:
+		w.buf = strconv.AppendBool(w.buf, v)
+	case string:
+		w.buf = AppendString(w.buf, v)
+	case float64:
+		var err error
+		w.buf, err = AppendFloat(w.buf, v)
+		return err
+	case int:
+		w.buf = strconv.AppendInt(w.buf, int64(v), 10)
+	case int64:
+		w.buf = strconv.AppendInt(w.buf, v, 10)
+	case map[string]any:
+		return w.object(v, depth)
+	case []any:
+		return w.array(v, depth)
+	default:
+		return fmt.Errorf("json: unsupported type: %T", v)
+	}
+	return nil
+}
+
+// object writes a map with sorted keys
+func (w *writer) object(m map[string]any, depth int) error {
+	if m == nil {
+		w.buf = append(w.buf, "null"...)
+		return nil
+	}
+	if len(m) == 0 {
+		w.buf = append(w.buf, "{}"...)
+		return nil
+	}
+
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	w.buf = append(w.buf, '{')
+	for i, key := range keys {
+		if i > 0 {
+			w.buf = append(w.buf, ',')
+		}
+		w.newline(depth + 1)
+		w.buf = AppendString(w.buf, key)
+		w.buf = append(w.buf, ':')
+		if w.pretty {
+			w.buf = append(w.buf, ' ')
+		}
+		if err := w.value(m[key], depth+1); err != nil {
+			return err
+		}
+	}
+	w.newline(depth)
+	w.buf = append(w.buf, '}')
+	return nil
+}
+
+// array writes a slice
+func (w *writer) array(s []any, depth int) error {
+	if s == nil {
+		w.buf = append(w.buf, "null"...)
+		return nil
+	}
+	if len(s) == 0 {
+		w.buf = append(w.buf, "[]"...)
+		return nil
+	}
+
+	w.buf = append(w.buf, '[')
+	for i, value := range s {
+		if i > 0 {
+			w.buf = append(w.buf, ',')
+		}
+		w.newline(depth + 1)
+		if err := w.value(value, depth+1); err != nil {
+			return err
+		}
+	}
+	w.newline(depth)
+	w.buf = append(w.buf, ']')
+	return nil
+}
@@ -0,0 +1,190 @@
+// Package learn infers codec rules from sample documents: it records which
+// values were decoded by guessing, under paths whose array indexes are
+// replaced with wildcards, and keeps the paths whose values were decoded
+// with the same codec chain consistently. The rules can be reviewed and
+// then used to decode documents of the same kind deterministically
+package learn
+
+import (
+	"fmt"
+	"io"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+)
+
+// DefaultMinSamples is the number of values a path needs by default before
+// a rule is inferred for it
+const DefaultMinSamples = 2
+
+// Options tune which paths rules are inferred for
+type Options struct {
+	// MinSamples is the number of values seen at a path below which no rule
+	// is inferred for it. Zero means DefaultMinSamples
+	MinSamples int
+
+	// MinRatio is the share of the values seen at a path that must have
+	// been decoded with the same codec chain. Zero means all of them
+	MinRatio float64
+}
+
+// Finding is a rule inferred for a path, along with the evidence for it
+type Finding struct {
+	decoder.Rule
+
+	// Decoded is the number of values decoded with the codecs of the rule,
+	// out of Seen values at the path
+	Decoded int `json:"decoded"`
+	Seen    int `json:"seen"`
+}
+
+// Learner accumulates the decoding results of sample documents
+type Learner struct {
+	opts      Options
+	documents int
+
+	// seen counts the values at each generalized path, and codecs how
+	// many of them were decoded with each codec chain
+	seen   map[string]int
+	codecs map[string]map[string]int
+}
+
+// New creates a Learner
+func New(opts Options) *Learner {
+	if opts.MinSamples <= 0 {
+		opts.MinSamples = DefaultMinSamples
+	}
+	if opts.MinRatio <= 0 || opts.MinRatio > 1 {
+		opts.MinRatio = 1
+	}
+	return &Learner{
+		opts:   opts,
+		seen:   make(map[string]int),
+		codecs: make(map[string]map[string]int),
+	}
+}
+
+// Add records the result of decoding a sample document, which must hold
+// its annotations
+func (l *Learner) Add(result decoder.Result) error {
+	l.documents++
+	l.count(result.Value, "$")
+
+	for _, annotation := range result.Annotations {
+		if annotation.Codec == "" {
+			// Values left undecoded because of a limit
+			continue
+		}
+		general, err := decoder.Generalize(annotation.Path)
+		if err != nil {
+			return err
+		}
+		if l.codecs[general] == nil {
+			l.codecs[general] = make(map[string]int)
+		}
+		l.codecs[general][annotation.Codec]++
+	}
+	return nil
+}
+
+// count records every value of a decoded document at its generalized path
+func (l *Learner) count(value any, path string) {
+	l.seen[path]++
+	switch v := value.(type) {
+	case map[string]any:
+		for name, member := range v {
+			l.count(member, decoder.MemberPath(path, name))
+		}
+	case []any:
+		for _, element := range v {
+			l.count(element, path+"[*]")
+		}
+	}
+}
+
+// Documents returns the number of documents added so far
+func (l *Learner) Documents() int {
+	return l.documents
+}
+
+// Findings returns the rules inferred so far, in order of their paths.
+// Codec chains that rules cannot declare, such as data URIs, are left out
+func (l *Learner) Findings() []Finding {
+	var findings []Finding
+	for path, chains := range l.codecs {
+		seen := l.seen[path]
+		if seen < l.opts.MinSamples {
+			continue
+		}
+
+		// The most frequent chain, the first in order on ties
+		var best string
+		for chain, n := range chains {
+			if n > chains[best] || n == chains[best] && chain < best {
+				best = chain
+			}
+		}
+		decoded := chains[best]
+		if float64(decoded) < l.opts.MinRatio*float64(seen) {
+			continue
+		}
+
+		rule := decoder.Rule{Path: path, Codecs: best}
+		if _, err := decoder.New(decoder.Options{Rules: []decoder.Rule{rule}}); err != nil {
+			continue
+		}
+		findings = append(findings, Finding{Rule: rule, Decoded: decoded, Seen: seen})
+	}
+
+	slices.SortFunc(findings, func(a, b Finding) int {
+		switch {
+		case a.Path < b.Path:
+			return -1
+		case a.Path > b.Path:
+			return 1
+		}
+		return 0
+	})
+	return findings
+}
+
+// WriteProfile writes findings as a configuration file holding a profile
+// with their rules, commented with the evidence for each, for review
+func WriteProfile(w io.Writer, profile string, documents int, findings []Finding) error {
+	rules := &yaml.Node{Kind: yaml.SequenceNode}
+	for _, f := range findings {
+		rules.Content = append(rules.Content, &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: "path"},
+			{Kind: yaml.ScalarNode, Value: f.Path},
+			{Kind: yaml.ScalarNode, Value: "codecs"},
+			{
+				Kind:        yaml.ScalarNode,
+				Value:       f.Codecs,
+				LineComment: fmt.Sprintf("%d of %d values", f.Decoded, f.Seen),
+			},
+		}})
+	}
+
+	settings := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: "rules"}, rules,
+	}}
+	root := &yaml.Node{
+		Kind:        yaml.MappingNode,
+		HeadComment: fmt.Sprintf("Rules learned from %d documents. Review them before use", documents),
+		Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: "profiles"},
+			{Kind: yaml.MappingNode, Content: []*yaml.Node{
+				{Kind: yaml.ScalarNode, Value: profile}, settings,
+			}},
+		},
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}}); err != nil {
+		return err
+	}
+	return enc.Close()
+}
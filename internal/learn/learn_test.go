@@ -0,0 +1,119 @@
+package learn_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/config"
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+	"github.com/vitorhrmiranda/jbdecoder/internal/learn"
+)
+
+// samples returns documents whose event bodies always hold Base64 JSON,
+// whose notes only sometimes hold Base64 text and whose avatar is a data
+// URI
+func samples() []string {
+	b64 := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	return []string{
+		`{"events": [{"body": "` + b64(`{"token": "`+b64("a secret token value")+`"}`) + `"}, {"body": "` + b64(`{"id": 1, "kind": "created"}`) + `"}],` +
+			` "note": "` + b64("an encoded note here") + `", "avatar": "data:text/plain;base64,SGVsbG8="}`,
+		`{"events": [{"body": "` + b64(`{"token": "`+b64("another secret value")+`"}`) + `"}],` +
+			` "note": "a plain note", "avatar": "data:text/plain;base64,SGVsbG8="}`,
+	}
+}
+
+func Test_Learner(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     learn.Options
+		expected string
+	}{
+		{
+			name: "consistent paths",
+			expected: `[{"path":"$.events[*].body","codecs":"base64|json","decoded":3,"seen":3},` +
+				`{"path":"$.events[*].body.token","codecs":"base64","decoded":2,"seen":2}]`,
+		},
+		{
+			name:     "more samples",
+			opts:     learn.Options{MinSamples: 3, MinRatio: 0.5},
+			expected: `[{"path":"$.events[*].body","codecs":"base64|json","decoded":3,"seen":3}]`,
+		},
+		{
+			name: "half of the values",
+			opts: learn.Options{MinRatio: 0.5},
+			expected: `[{"path":"$.events[*].body","codecs":"base64|json","decoded":3,"seen":3},` +
+				`{"path":"$.events[*].body.token","codecs":"base64","decoded":2,"seen":2},` +
+				`{"path":"$.note","codecs":"base64","decoded":1,"seen":2}]`,
+		},
+	}
+
+	d, err := decoder.New(decoder.Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			l := learn.New(testCase.opts)
+			for _, sample := range samples() {
+				var data any
+				if err := json.Unmarshal([]byte(sample), &data); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				result, err := d.Decode(data)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if err := l.Add(result); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+
+			actual, _ := json.Marshal(l.Findings())
+			if string(actual) != testCase.expected {
+				t.Errorf("Expected: %s, Got: %s", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func Test_WriteProfile(t *testing.T) {
+	findings := []learn.Finding{
+		{Rule: decoder.Rule{Path: "$.events[*].body", Codecs: "base64url|gzip|json"}, Decoded: 4, Seen: 4},
+		{Rule: decoder.Rule{Path: "$['e-mail']", Codecs: "base64"}, Decoded: 2, Seen: 2},
+	}
+
+	var b strings.Builder
+	if err := learn.WriteProfile(&b, "events", 3, findings); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `# Rules learned from 3 documents. Review them before use
+profiles:
+  events:
+    rules:
+      - path: $.events[*].body
+        codecs: base64url|gzip|json # 4 of 4 values
+      - path: $['e-mail']
+        codecs: base64 # 2 of 2 values
+`
+	if b.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, b.String())
+	}
+
+	// The profile is a valid configuration
+	path := filepath.Join(t.TempDir(), ".jbdecoder.yaml")
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	settings, err := config.Load(config.Options{Path: path, Profile: "events", Getenv: func(string) string { return "" }})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(settings.Rules) != 2 || settings.Rules[1] != (config.Rule{Path: "$['e-mail']", Codecs: "base64"}) {
+		t.Errorf("Expected the rules to be read back, Got: %v", settings.Rules)
+	}
+}
@@ -0,0 +1,411 @@
+package lsp_test
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+	"github.com/vitorhrmiranda/jbdecoder/internal/lsp"
+)
+
+// client is a scripted LSP client talking to a server over pipes
+type client struct {
+	t      *testing.T
+	w      io.WriteCloser
+	r      *textproto.Reader
+	nextID int
+	done   chan error
+}
+
+// start runs a server with opts and returns a client connected to it
+func start(t *testing.T, opts decoder.Options) *client {
+	t.Helper()
+
+	server, err := lsp.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	c := &client{t: t, w: clientW, r: textproto.NewReader(bufio.NewReader(clientR)), done: make(chan error, 1)}
+	go func() {
+		err := server.Serve(serverR, serverW)
+		_ = serverW.Close()
+		c.done <- err
+	}()
+
+	c.call("initialize", map[string]any{"capabilities": map[string]any{}})
+	c.notify("initialized", map[string]any{})
+	return c
+}
+
+// send writes a message
+func (c *client) send(message map[string]any) {
+	c.t.Helper()
+
+	message["jsonrpc"] = "2.0"
+	body, _ := json.Marshal(message)
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// notify sends a notification
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	c.send(map[string]any{"method": method, "params": params})
+}
+
+// response is the answer to a request
+type response struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// call sends a request and returns its response
+func (c *client) call(method string, params any) response {
+	c.t.Helper()
+
+	c.nextID++
+	c.send(map[string]any{"id": c.nextID, "method": method, "params": params})
+
+	header, err := c.r.ReadMIMEHeader()
+	if err != nil {
+		c.t.Fatalf("reading response header: %v", err)
+	}
+	length, _ := strconv.Atoi(header.Get("Content-Length"))
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r.R, body); err != nil {
+		c.t.Fatalf("reading response: %v", err)
+	}
+
+	var resp response
+	if err := json.Unmarshal(body, &resp); err != nil {
+		c.t.Fatalf("invalid response %s: %v", body, err)
+	}
+	if resp.ID != c.nextID {
+		c.t.Fatalf("expected the response to %d, got %s", c.nextID, body)
+	}
+	return resp
+}
+
+// result calls method and decodes its result into v
+func (c *client) result(method string, params any, v any) {
+	c.t.Helper()
+
+	resp := c.call(method, params)
+	if resp.Error != nil {
+		c.t.Fatalf("%s failed: %s", method, resp.Error.Message)
+	}
+	if err := json.Unmarshal(resp.Result, v); err != nil {
+		c.t.Fatalf("invalid %s result %s: %v", method, resp.Result, err)
+	}
+}
+
+// open opens a document
+func (c *client) open(uri, language, text string) {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "languageId": language, "version": 1, "text": text},
+	})
+}
+
+// stop shuts the server down and waits for it to exit
+func (c *client) stop() error {
+	c.t.Helper()
+	c.call("shutdown", nil)
+	c.notify("exit", nil)
+	return <-c.done
+}
+
+func at(line, character int) map[string]any {
+	return map[string]any{"line": line, "character": character}
+}
+
+func document(uri string) map[string]any {
+	return map[string]any{"uri": uri}
+}
+
+func b64(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+type hover struct {
+	Contents struct {
+		Kind  string `json:"kind"`
+		Value string `json:"value"`
+	} `json:"contents"`
+	Range struct {
+		Start struct{ Line, Character int } `json:"start"`
+		End   struct{ Line, Character int } `json:"end"`
+	} `json:"range"`
+}
+
+type codeAction struct {
+	Title string `json:"title"`
+	Edit  struct {
+		Changes map[string][]struct {
+			Range struct {
+				Start struct{ Line, Character int } `json:"start"`
+				End   struct{ Line, Character int } `json:"end"`
+			} `json:"range"`
+			NewText string `json:"newText"`
+		} `json:"changes"`
+	} `json:"edit"`
+}
+
+type inlayHint struct {
+	Position struct{ Line, Character int } `json:"position"`
+	Label    string                        `json:"label"`
+}
+
+func Test_Server_Hover(t *testing.T) {
+	c := start(t, decoder.Options{})
+	token := b64(`{"user":"john","roles":["admin"]}`)
+	c.open("file:///fixture.json", "json", "{\n  \"token\": \""+token+"\",\n  \"plain\": \"not base64!\",\n  \"émoji😀\": \""+b64("héllo wörld")+"\"\n}")
+
+	var h hover
+	c.result("textDocument/hover", map[string]any{"textDocument": document("file:///fixture.json"), "position": at(1, 14)}, &h)
+	if h.Contents.Kind != "markdown" || !strings.Contains(h.Contents.Value, "`base64|json`") ||
+		!strings.Contains(h.Contents.Value, "```json\n{\n  \"roles\": [\n    \"admin\"\n  ],\n  \"user\": \"john\"\n}\n```") {
+		t.Errorf("unexpected hover %q", h.Contents.Value)
+	}
+	if h.Range.Start.Line != 1 || h.Range.Start.Character != 11 || h.Range.End.Character != 13+len(token) {
+		t.Errorf("unexpected hover range %+v", h.Range)
+	}
+
+	// Columns count UTF-16 code units: é is one and 😀 two
+	c.result("textDocument/hover", map[string]any{"textDocument": document("file:///fixture.json"), "position": at(3, 14)}, &h)
+	if !strings.Contains(h.Contents.Value, "```text\nhéllo wörld\n```") || h.Range.Start.Character != 13 {
+		t.Errorf("unexpected hover %q at %+v", h.Contents.Value, h.Range)
+	}
+
+	for _, pos := range []map[string]any{at(2, 14), at(1, 4), at(0, 0), at(10, 0)} {
+		resp := c.call("textDocument/hover", map[string]any{"textDocument": document("file:///fixture.json"), "position": pos})
+		if resp.Error != nil || string(resp.Result) != "null" {
+			t.Errorf("expected no hover at %v, got %s", pos, resp.Result)
+		}
+	}
+
+	if err := c.stop(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func Test_Server_YAML(t *testing.T) {
+	c := start(t, decoder.Options{})
+	c.open("file:///values.yaml", "yaml", strings.Join([]string{
+		"# fixtures",
+		"secret: " + b64("top secret value") + " # comment",
+		"quoted: \"" + b64("double quoted") + "\"",
+		"items:",
+		"  - '" + b64("single quoted") + "'",
+		"  - name: " + b64("nested item"),
+		"block: |",
+		"  " + b64("line of a block scalar"),
+	}, "\n"))
+
+	var hints []inlayHint
+	c.result("textDocument/inlayHint", map[string]any{
+		"textDocument": document("file:///values.yaml"),
+		"range":        map[string]any{"start": at(0, 0), "end": at(8, 0)},
+	}, &hints)
+
+	var labels []string
+	for _, hint := range hints {
+		labels = append(labels, fmt.Sprintf("%d:%d %s", hint.Position.Line, hint.Position.Character, hint.Label))
+	}
+	expected := []string{
+		"1:32 ⇒ top secret value",
+		"2:30 ⇒ double quoted",
+		"4:26 ⇒ single quoted",
+		"5:26 ⇒ nested item",
+		"7:34 ⇒ line of a block scalar",
+	}
+	if strings.Join(labels, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected hints\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(labels, "\n"))
+	}
+
+	var actions []codeAction
+	c.result("textDocument/codeAction", map[string]any{
+		"textDocument": document("file:///values.yaml"),
+		"range":        map[string]any{"start": at(1, 10), "end": at(1, 10)},
+		"context":      map[string]any{"diagnostics": []any{}},
+	}, &actions)
+	if len(actions) != 1 || actions[0].Title != "Decode base64 value" ||
+		actions[0].Edit.Changes["file:///values.yaml"][0].NewText != `"top secret value"` {
+		t.Errorf("unexpected code actions %+v", actions)
+	}
+
+	if err := c.stop(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func Test_Server_Paths(t *testing.T) {
+	body, raw := b64(`{"user":"john"}`), b64("raw payload text")
+	opts := decoder.Options{
+		Include: []string{"$.events[*].body", "$['e-mail']"},
+		Exclude: []string{"$.events[1]"},
+	}
+
+	testCases := []struct {
+		uri      string
+		language string
+		lines    []string
+	}{
+		{
+			uri:      "file:///events.json",
+			language: "json",
+			lines: []string{
+				`{"events": [`,
+				`  {"body": "` + body + `", "raw": "` + raw + `"},`,
+				`  {"body": "` + body + `"}`,
+				`], "e-mail": "` + raw + `", "other": "` + raw + `"}`,
+			},
+		},
+		{
+			uri:      "file:///events.yaml",
+			language: "yaml",
+			lines: []string{
+				"events:",
+				"- body: " + body,
+				"  raw: " + raw,
+				"- body: " + body,
+				"'e-mail': " + raw,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.language, func(t *testing.T) {
+			c := start(t, opts)
+			c.open(tc.uri, tc.language, strings.Join(tc.lines, "\n"))
+
+			var hints []inlayHint
+			c.result("textDocument/inlayHint", map[string]any{
+				"textDocument": document(tc.uri),
+				"range":        map[string]any{"start": at(0, 0), "end": at(len(tc.lines), 0)},
+			}, &hints)
+
+			var labels []string
+			for _, hint := range hints {
+				labels = append(labels, fmt.Sprintf("%d %s", hint.Position.Line, hint.Label))
+			}
+			expected := []string{`1 ⇒ {"user":"john"}`, "3 ⇒ raw payload text"}
+			if tc.language == "yaml" {
+				expected[1] = "4 ⇒ raw payload text"
+			}
+			if strings.Join(labels, "\n") != strings.Join(expected, "\n") {
+				t.Errorf("expected hints\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(labels, "\n"))
+			}
+
+			if err := c.stop(); err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func Test_Server_CodeActions(t *testing.T) {
+	const uri = "file:///fixture.json"
+	c := start(t, decoder.Options{})
+	c.open(uri, "json", `{"token": "`+b64(`{"id":7,"tag":"<b>"}`)+`", "name": "John"}`)
+
+	actionsAt := func(start, end int) []codeAction {
+		t.Helper()
+		var actions []codeAction
+		c.result("textDocument/codeAction", map[string]any{
+			"textDocument": document(uri),
+			"range":        map[string]any{"start": at(0, start), "end": at(0, end)},
+			"context":      map[string]any{"diagnostics": []any{}},
+		}, &actions)
+		return actions
+	}
+
+	actions := actionsAt(12, 12)
+	if len(actions) != 1 || actions[0].Title != "Decode base64|json value" {
+		t.Fatalf("unexpected code actions %+v", actions)
+	}
+	edit := actions[0].Edit.Changes[uri][0]
+	if edit.NewText != `{"id":7,"tag":"<b>"}` || edit.Range.Start.Character != 10 {
+		t.Errorf("unexpected edit %+v", edit)
+	}
+
+	// Apply the edit, then encode the decoded object back
+	end := edit.Range.End.Character
+	c.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": 2},
+		"contentChanges": []any{map[string]any{"range": map[string]any{"start": at(0, 10), "end": at(0, end)}, "text": edit.NewText}},
+	})
+	actions = actionsAt(10, 10+len(edit.NewText))
+	if len(actions) != 1 || actions[0].Title != "Encode selection as Base64" ||
+		actions[0].Edit.Changes[uri][0].NewText != `"`+b64(`{"id":7,"tag":"<b>"}`)+`"` {
+		t.Errorf("unexpected code actions %+v", actions)
+	}
+
+	// Strings that hold nothing to decode can be encoded
+	c.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": 3},
+		"contentChanges": []any{map[string]any{"text": `{"name": "John"}`}},
+	})
+	actions = actionsAt(11, 11)
+	if len(actions) != 1 || actions[0].Title != "Encode value as Base64" ||
+		actions[0].Edit.Changes[uri][0].NewText != `"`+b64("John")+`"` {
+		t.Errorf("unexpected code actions %+v", actions)
+	}
+
+	// Keys are not values
+	if actions = actionsAt(3, 3); len(actions) != 0 {
+		t.Errorf("expected no code actions on a key, got %+v", actions)
+	}
+
+	if err := c.stop(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func Test_Server_Lifecycle(t *testing.T) {
+	server, _ := lsp.New(decoder.Options{})
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	c := &client{t: t, w: clientW, r: textproto.NewReader(bufio.NewReader(clientR)), done: make(chan error, 1)}
+	go func() {
+		c.done <- server.Serve(serverR, serverW)
+	}()
+
+	if resp := c.call("textDocument/hover", map[string]any{}); resp.Error == nil || resp.Error.Code != -32002 {
+		t.Errorf("expected a server not initialized error, got %+v", resp)
+	}
+	c.call("initialize", map[string]any{})
+	if resp := c.call("workspace/symbol", map[string]any{}); resp.Error == nil || resp.Error.Code != -32601 {
+		t.Errorf("expected a method not found error, got %+v", resp)
+	}
+	if resp := c.call("textDocument/hover", "not params"); resp.Error == nil || resp.Error.Code != -32602 {
+		t.Errorf("expected an invalid params error, got %+v", resp)
+	}
+
+	c.notify("exit", nil)
+	if err := <-c.done; !errors.Is(err, lsp.ErrExitWithoutShutdown) {
+		t.Errorf("expected ErrExitWithoutShutdown, got %v", err)
+	}
+}
+
+func Test_Server_InvalidOptions(t *testing.T) {
+	if _, err := lsp.New(decoder.Options{Charset: "klingon"}); err == nil {
+		t.Error("expected an error for an unknown charset")
+	}
+}
@@ -0,0 +1,428 @@
+package lsp
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+)
+
+// document is an open text document
+type document struct {
+	yaml  bool
+	lines []string
+
+	// index holds the string values of each line, scanned on first use
+	// after a change
+	index [][]scalar
+}
+
+// newDocument splits text into lines. YAML documents are recognized by
+// their language or file extension, everything else is read as JSON
+func newDocument(uri, languageID, text string) *document {
+	yaml := languageID == "yaml" || strings.HasSuffix(uri, ".yaml") || strings.HasSuffix(uri, ".yml")
+	return &document{yaml: yaml, lines: splitLines(text)}
+}
+
+// splitLines splits text at line breaks, dropping the carriage returns of
+// CRLF line endings
+func splitLines(text string) []string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
+}
+
+// text joins the lines of the document
+func (d *document) text() string {
+	return strings.Join(d.lines, "\n")
+}
+
+// apply applies a change to the document: a replacement of its whole text,
+// or of a range of it
+func (d *document) apply(change contentChange) {
+	d.index = nil
+	if change.Range == nil {
+		d.lines = splitLines(change.Text)
+		return
+	}
+
+	text := d.text()
+	start, end := d.offset(change.Range.Start), d.offset(change.Range.End)
+	if end < start {
+		start, end = end, start
+	}
+	d.lines = splitLines(text[:start] + change.Text + text[end:])
+}
+
+// offset converts a position to a byte offset in the text of the document,
+// clamping positions past the end of lines or of the document
+func (d *document) offset(p position) int {
+	offset := 0
+	for i := 0; i < p.Line && i < len(d.lines); i++ {
+		offset += len(d.lines[i]) + 1
+	}
+	if p.Line >= len(d.lines) {
+		return max(offset-1, 0)
+	}
+	return offset + byteColumn(d.lines[p.Line], p.Character)
+}
+
+// byteColumn converts a UTF-16 offset in line to a byte offset
+func byteColumn(line string, character int) int {
+	units := 0
+	for i, r := range line {
+		if units >= character {
+			return i
+		}
+		units += utf16.RuneLen(r)
+	}
+	return len(line)
+}
+
+// utf16Column converts a byte offset in line to a UTF-16 offset
+func utf16Column(line string, offset int) int {
+	units := 0
+	for _, r := range line[:offset] {
+		units += utf16.RuneLen(r)
+	}
+	return units
+}
+
+// scalar is a string value of a document, which is always on one line
+type scalar struct {
+	line       int
+	start, end int // byte offsets in the line, including quotes
+	value      string
+	quote      byte   // '"', '\'' or 0 for YAML plain scalars
+	path       string // JSONPath of the value in the document
+}
+
+// textRange returns the range of the scalar in the document
+func (s scalar) textRange(d *document) textRange {
+	line := d.lines[s.line]
+	return textRange{
+		Start: position{Line: s.line, Character: utf16Column(line, s.start)},
+		End:   position{Line: s.line, Character: utf16Column(line, s.end)},
+	}
+}
+
+// scalars returns the string values on a line of the document. Object keys
+// are not values and are left out
+func (d *document) scalars(line int) []scalar {
+	if line < 0 || line >= len(d.lines) {
+		return nil
+	}
+	if d.index == nil {
+		if d.yaml {
+			d.index = yamlIndex(d.lines)
+		} else {
+			d.index = jsonIndex(d.lines)
+		}
+	}
+	return d.index[line]
+}
+
+// scalarAt returns the string value at a position
+func (d *document) scalarAt(p position) (scalar, bool) {
+	if p.Line < 0 || p.Line >= len(d.lines) {
+		return scalar{}, false
+	}
+
+	offset := byteColumn(d.lines[p.Line], p.Character)
+	for _, s := range d.scalars(p.Line) {
+		if offset >= s.start && offset <= s.end {
+			return s, true
+		}
+	}
+	return scalar{}, false
+}
+
+// jsonFrame is an object or array around the scanned position of a JSON
+// document, with the member or element the position is in
+type jsonFrame struct {
+	array bool
+	key   string
+	index int
+}
+
+// jsonIndex finds the string values of each line of a JSON document along
+// with their paths. Strings cannot span lines in JSON, so a string is read
+// on its line, and documents being edited are scanned as far as they are
+// well-formed
+func jsonIndex(lines []string) [][]scalar {
+	index := make([][]scalar, len(lines))
+	var frames []jsonFrame
+	for number, line := range lines {
+	scan:
+		for i := 0; i < len(line); i++ {
+			switch line[i] {
+			case '{':
+				frames = append(frames, jsonFrame{})
+			case '[':
+				frames = append(frames, jsonFrame{array: true})
+			case '}', ']':
+				if len(frames) > 0 {
+					frames = frames[:len(frames)-1]
+				}
+			case ',':
+				if n := len(frames); n > 0 && frames[n-1].array {
+					frames[n-1].index++
+				}
+			case '"':
+				end := quotedEnd(line, i)
+				if end < 0 {
+					break scan
+				}
+
+				var value string
+				if json.Unmarshal([]byte(line[i:end]), &value) == nil {
+					if !isKey(line[end:]) {
+						sc := scalar{line: number, start: i, end: end, value: value, quote: '"', path: jsonPath(frames)}
+						index[number] = append(index[number], sc)
+					} else if n := len(frames); n > 0 && !frames[n-1].array {
+						frames[n-1].key = value
+					}
+				}
+				i = end - 1
+			}
+		}
+	}
+	return index
+}
+
+// jsonPath renders the path of the scanned position of a JSON document
+func jsonPath(frames []jsonFrame) string {
+	p := "$"
+	for _, f := range frames {
+		if f.array {
+			p = decoder.IndexPath(p, f.index)
+		} else {
+			p = decoder.MemberPath(p, f.key)
+		}
+	}
+	return p
+}
+
+// quotedEnd returns the offset after the closing quote of the double
+// quoted string starting at line[start], or -1 if it is not closed
+func quotedEnd(line string, start int) int {
+	for i := start + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// isKey reports whether the text following a string makes it a key
+func isKey(rest string) bool {
+	return strings.HasPrefix(strings.TrimLeft(rest, " \t"), ":")
+}
+
+// yamlScalars finds the string value of a line of YAML: the value of a
+// "key: value" mapping entry or a "- value" sequence item, when it is a
+// quoted or plain scalar on that line. Other lines, such as the lines of
+// block scalars, are read as plain scalars. Flow collections, aliases and
+// tagged values are not scanned
+func yamlScalars(number int, line string) []scalar {
+	i := skipSpaces(line, 0)
+	for strings.HasPrefix(line[i:], "- ") || line[i:] == "-" {
+		i = skipSpaces(line, i+1)
+	}
+	if i >= len(line) || line[i] == '#' || strings.HasPrefix(line[i:], "---") || strings.HasPrefix(line[i:], "...") {
+		return nil
+	}
+
+	if colon := yamlKeyEnd(line, i); colon >= 0 {
+		i = skipSpaces(line, colon+1)
+		if i >= len(line) {
+			return nil
+		}
+	}
+
+	switch c := line[i]; c {
+	case '"':
+		end := quotedEnd(line, i)
+		var value string
+		if end < 0 || json.Unmarshal([]byte(line[i:end]), &value) != nil {
+			return nil
+		}
+		return []scalar{{line: number, start: i, end: end, value: value, quote: '"'}}
+
+	case '\'':
+		for end := i + 1; end < len(line); end++ {
+			if line[end] != '\'' {
+				continue
+			}
+			if end+1 < len(line) && line[end+1] == '\'' {
+				end++
+				continue
+			}
+			value := strings.ReplaceAll(line[i+1:end], "''", "'")
+			return []scalar{{line: number, start: i, end: end + 1, value: value, quote: '\''}}
+		}
+		return nil
+
+	case '|', '>', '{', '[', '&', '*', '!', '%', '@', '`':
+		return nil
+
+	default:
+		end := len(line)
+		if comment := strings.Index(line[i:], " #"); comment >= 0 {
+			end = i + comment
+		}
+		value := strings.TrimRight(line[i:end], " \t")
+		if value == "" || !utf8.ValidString(value) {
+			return nil
+		}
+		return []scalar{{line: number, start: i, end: i + len(value), value: value}}
+	}
+}
+
+// yamlKeyEnd returns the offset of the colon ending the mapping key that
+// starts at line[start], or -1 if the line holds no key there
+func yamlKeyEnd(line string, start int) int {
+	i := start
+	if i < len(line) && (line[i] == '"' || line[i] == '\'') {
+		quote := line[i]
+		for i++; i < len(line) && line[i] != quote; i++ {
+			if quote == '"' && line[i] == '\\' {
+				i++
+			}
+		}
+		i++
+	}
+
+	for ; i < len(line); i++ {
+		if line[i] == ':' && (i+1 == len(line) || line[i+1] == ' ' || line[i+1] == '\t') {
+			return i
+		}
+		if line[i] == ' ' && i+1 < len(line) && line[i+1] == '#' {
+			return -1
+		}
+	}
+	return -1
+}
+
+// yamlFrame is a mapping or sequence around a line of a YAML document,
+// with the indentation of its keys or dashes and the entry the line is in
+type yamlFrame struct {
+	indent   int
+	sequence bool
+	key      string
+	index    int
+}
+
+// yamlIndex finds the string values of each line of a YAML document along
+// with their paths, following the block structure given by indentation.
+// The lines of a block scalar belong to its key
+func yamlIndex(lines []string) [][]scalar {
+	index := make([][]scalar, len(lines))
+	var frames []yamlFrame
+	block := -1 // indentation above which lines belong to a block scalar
+	for number, line := range lines {
+		i := skipSpaces(line, 0)
+		if i >= len(line) || line[i] == '#' {
+			continue
+		}
+		if block >= 0 && i > block {
+			index[number] = yamlPathed(yamlScalars(number, line), frames)
+			continue
+		}
+		block = -1
+		if strings.HasPrefix(line, "---") || strings.HasPrefix(line, "...") {
+			frames = nil
+			continue
+		}
+
+		for strings.HasPrefix(line[i:], "- ") || line[i:] == "-" {
+			frames = yamlPop(frames, i)
+			if n := len(frames); n > 0 && frames[n-1].sequence && frames[n-1].indent == i {
+				frames[n-1].index++
+			} else {
+				frames = append(frames, yamlFrame{indent: i, sequence: true})
+			}
+			i = skipSpaces(line, i+1)
+		}
+
+		value := i
+		if colon := yamlKeyEnd(line, i); colon >= 0 {
+			key := yamlKey(line[i:colon])
+			frames = yamlPop(frames, i)
+			if n := len(frames); n > 0 && frames[n-1].sequence && frames[n-1].indent == i {
+				// A key next to a sequence, e.g. after "key:\n- item"
+				frames = frames[:n-1]
+			}
+			if n := len(frames); n > 0 && !frames[n-1].sequence && frames[n-1].indent == i {
+				frames[n-1].key = key
+			} else {
+				frames = append(frames, yamlFrame{indent: i, key: key})
+			}
+			value = skipSpaces(line, colon+1)
+		}
+		if value < len(line) && (line[value] == '|' || line[value] == '>') && len(frames) > 0 {
+			block = frames[len(frames)-1].indent
+		}
+
+		index[number] = yamlPathed(yamlScalars(number, line), frames)
+	}
+	return index
+}
+
+// yamlPop drops the frames nested deeper than indent
+func yamlPop(frames []yamlFrame, indent int) []yamlFrame {
+	for len(frames) > 0 && frames[len(frames)-1].indent > indent {
+		frames = frames[:len(frames)-1]
+	}
+	return frames
+}
+
+// yamlKey returns the name of a mapping key, unquoting quoted keys
+func yamlKey(text string) string {
+	text = strings.TrimRight(text, " \t")
+	switch {
+	case strings.HasPrefix(text, `"`):
+		var key string
+		if json.Unmarshal([]byte(text), &key) == nil {
+			return key
+		}
+	case strings.HasPrefix(text, "'") && strings.HasSuffix(text, "'") && len(text) > 1:
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'")
+	}
+	return text
+}
+
+// yamlPathed sets the path of the scanned line of a YAML document on its
+// scalars
+func yamlPathed(scalars []scalar, frames []yamlFrame) []scalar {
+	if len(scalars) == 0 {
+		return nil
+	}
+	p := "$"
+	for _, f := range frames {
+		if f.sequence {
+			p = decoder.IndexPath(p, f.index)
+		} else {
+			p = decoder.MemberPath(p, f.key)
+		}
+	}
+	for i := range scalars {
+		scalars[i].path = p
+	}
+	return scalars
+}
+
+// skipSpaces returns the offset of the first non-blank byte at or after i
+func skipSpaces(line string, i int) int {
+	for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+		i++
+	}
+	return i
+}
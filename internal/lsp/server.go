@@ -0,0 +1,358 @@
+// Package lsp implements a Language Server Protocol server decoding the
+// values of JSON and YAML documents in editors: hovers show the decoded
+// value of the string under the cursor, code actions decode or encode it in
+// place, and inlay hints preview decoded values inline
+package lsp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+)
+
+const (
+	// maxHoverSize is the number of bytes of a decoded value shown in a
+	// hover
+	maxHoverSize = 16 * 1024
+
+	// maxHintLength is the number of bytes of a decoded value shown in an
+	// inlay hint
+	maxHintLength = 40
+)
+
+// ErrExitWithoutShutdown is returned by Serve when the client exits or
+// disconnects without asking the server to shut down first
+var ErrExitWithoutShutdown = errors.New("exit without shutdown")
+
+// Server answers the requests of one client
+type Server struct {
+	decoder *decoder.Decoder
+	docs    map[string]*document
+
+	initialized  bool
+	shuttingDown bool
+}
+
+// New creates a server decoding values with opts
+func New(opts decoder.Options) (*Server, error) {
+	opts.InPlace = true
+	d, err := decoder.New(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{decoder: d, docs: make(map[string]*document)}, nil
+}
+
+// Serve answers messages read from r on w until the client exits. It
+// returns nil if the client shut the server down before exiting
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	c := newConn(r, w)
+	for {
+		body, err := c.read()
+		if errors.Is(err, io.EOF) {
+			if s.shuttingDown {
+				return nil
+			}
+			return ErrExitWithoutShutdown
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			if err := c.reply(nil, nil, &rpcError{Code: codeParseError, Message: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if req.Method == "exit" {
+			if s.shuttingDown {
+				return nil
+			}
+			return ErrExitWithoutShutdown
+		}
+
+		result, err := s.handle(&req)
+		if req.notification() {
+			continue
+		}
+		if err := c.reply(req.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+// handle dispatches a request or notification to its handler
+func (s *Server) handle(req *request) (any, error) {
+	switch {
+	case req.Method == "initialize":
+		s.initialized = true
+		return initializeResult{
+			Capabilities: serverCapabilities{
+				PositionEncoding:   positionUTF16,
+				TextDocumentSync:   syncFull,
+				HoverProvider:      true,
+				CodeActionProvider: codeActionOptions{CodeActionKinds: []string{kindRefactorRewrite}},
+				InlayHintProvider:  true,
+			},
+			ServerInfo: serverInfo{Name: "jbdecoder"},
+		}, nil
+	case !s.initialized:
+		return nil, &rpcError{Code: codeServerNotInitialized, Message: "server not initialized"}
+	case s.shuttingDown:
+		return nil, &rpcError{Code: codeInvalidRequest, Message: "server is shutting down"}
+	}
+
+	switch req.Method {
+	case "shutdown":
+		s.shuttingDown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		return nil, s.params(req, &params, func() {
+			s.docs[params.TextDocument.URI] = newDocument(params.TextDocument.URI, params.TextDocument.LanguageID, params.TextDocument.Text)
+		})
+	case "textDocument/didChange":
+		var params didChangeParams
+		return nil, s.params(req, &params, func() {
+			if doc := s.docs[params.TextDocument.URI]; doc != nil {
+				for _, change := range params.ContentChanges {
+					doc.apply(change)
+				}
+			}
+		})
+	case "textDocument/didClose":
+		var params didCloseParams
+		return nil, s.params(req, &params, func() {
+			delete(s.docs, params.TextDocument.URI)
+		})
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		var result *hover
+		err := s.params(req, &params, func() {
+			result = s.hover(params)
+		})
+		return result, err
+	case "textDocument/codeAction":
+		var params codeActionParams
+		var result []codeAction
+		err := s.params(req, &params, func() {
+			result = s.codeActions(params)
+		})
+		return result, err
+	case "textDocument/inlayHint":
+		var params inlayHintParams
+		var result []inlayHint
+		err := s.params(req, &params, func() {
+			result = s.inlayHints(params)
+		})
+		return result, err
+	}
+
+	if req.notification() {
+		// Notifications such as initialized and $/cancelRequest need no
+		// handling
+		return nil, nil
+	}
+	return nil, &rpcError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+// params decodes the parameters of req into v and calls fn with them
+func (s *Server) params(req *request, v any, fn func()) error {
+	if err := json.Unmarshal(req.Params, v); err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: "invalid params: " + err.Error()}
+	}
+	fn()
+	return nil
+}
+
+// decode decodes a string value at its path in the document, so that the
+// filters, redactions and rules of the options apply, reporting false when
+// it holds nothing decodable
+func (s *Server) decode(sc scalar) (any, string, bool) {
+	result, err := s.decoder.DecodeAt(sc.path, sc.value)
+	if err != nil {
+		return nil, "", false
+	}
+	for _, annotation := range result.Annotations {
+		if annotation.Path == sc.path && annotation.Codec != "" {
+			return result.Value, annotation.Codec, true
+		}
+	}
+	return nil, "", false
+}
+
+// hover shows the decoded value of the string under the cursor
+func (s *Server) hover(params textDocumentPositionParams) *hover {
+	doc := s.docs[params.TextDocument.URI]
+	if doc == nil {
+		return nil
+	}
+	sc, ok := doc.scalarAt(params.Position)
+	if !ok {
+		return nil
+	}
+	value, codec, ok := s.decode(sc)
+	if !ok {
+		return nil
+	}
+
+	content, language := preview(value)
+	truncated := len(content) > maxHoverSize
+	if truncated {
+		content = truncate(content, maxHoverSize)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "**Decoded** `%s`\n\n", codec)
+	fence := strings.Repeat("`", max(3, longestRun(content, '`')+1))
+	fmt.Fprintf(&b, "%s%s\n%s\n%s", fence, language, content, fence)
+	if truncated {
+		fmt.Fprintf(&b, "\n\n_Truncated to %d bytes_", maxHoverSize)
+	}
+
+	return &hover{
+		Contents: markupContent{Kind: markdown, Value: b.String()},
+		Range:    sc.textRange(doc),
+	}
+}
+
+// codeActions offers to decode the string in the range or else to encode
+// it as Base64, and to encode a selected JSON value as a Base64 string
+func (s *Server) codeActions(params codeActionParams) []codeAction {
+	doc := s.docs[params.TextDocument.URI]
+	if doc == nil {
+		return nil
+	}
+	uri := params.TextDocument.URI
+	actions := []codeAction{}
+
+	edit := func(title string, r textRange, text string) {
+		actions = append(actions, codeAction{
+			Title: title,
+			Kind:  kindRefactorRewrite,
+			Edit:  workspaceEdit{Changes: map[string][]textEdit{uri: {{Range: r, NewText: text}}}},
+		})
+	}
+
+	if sc, ok := doc.scalarAt(params.Range.Start); ok {
+		if value, codec, ok := s.decode(sc); ok {
+			edit("Decode "+codec+" value", sc.textRange(doc), literal(value))
+			return actions
+		}
+
+		encoded := base64.StdEncoding.EncodeToString([]byte(sc.value))
+		if sc.quote != '"' {
+			// Base64 needs no quotes in YAML
+			edit("Encode value as Base64", sc.textRange(doc), encoded)
+		} else {
+			edit("Encode value as Base64", sc.textRange(doc), literal(encoded))
+		}
+		return actions
+	}
+
+	// A selected object or array, e.g. a value decoded before, is encoded
+	// as the Base64 of its compact JSON
+	start, end := doc.offset(params.Range.Start), doc.offset(params.Range.End)
+	if end <= start {
+		return actions
+	}
+	selection := bytes.TrimSpace([]byte(doc.text()[start:end]))
+	if len(selection) > 0 && (selection[0] == '{' || selection[0] == '[') && json.Valid(selection) {
+		var compact bytes.Buffer
+		_ = json.Compact(&compact, selection)
+		edit("Encode selection as Base64", params.Range, literal(base64.StdEncoding.EncodeToString(compact.Bytes())))
+	}
+	return actions
+}
+
+// inlayHints previews the decoded values of the strings in the range
+func (s *Server) inlayHints(params inlayHintParams) []inlayHint {
+	doc := s.docs[params.TextDocument.URI]
+	if doc == nil {
+		return nil
+	}
+
+	hints := []inlayHint{}
+	for line := max(params.Range.Start.Line, 0); line <= params.Range.End.Line && line < len(doc.lines); line++ {
+		for _, sc := range doc.scalars(line) {
+			value, codec, ok := s.decode(sc)
+			if !ok {
+				continue
+			}
+
+			label := literal(value)
+			if text, ok := value.(string); ok {
+				label = text
+			}
+			label = strings.Join(strings.Fields(label), " ")
+
+			hints = append(hints, inlayHint{
+				Position:    sc.textRange(doc).End,
+				Label:       "⇒ " + truncate(label, maxHintLength),
+				Tooltip:     "Decoded " + codec,
+				PaddingLeft: true,
+			})
+		}
+	}
+	return hints
+}
+
+// preview renders a decoded value for a hover along with the language of
+// its code block: indented JSON for structures and plain text for strings
+func preview(value any) (string, string) {
+	if text, ok := value.(string); ok {
+		return text, "text"
+	}
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(value)
+	return strings.TrimSuffix(b.String(), "\n"), "json"
+}
+
+// literal renders a value as compact JSON, which is valid YAML too
+func literal(value any) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(value)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// truncate cuts s to at most n bytes on a character boundary, marking the
+// cut with an ellipsis
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "…"
+}
+
+// longestRun returns the length of the longest run of c in s
+func longestRun(s string, c byte) int {
+	longest, run := 0, 0
+	for i := range len(s) {
+		if s[i] == c {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return longest
+}
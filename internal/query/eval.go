@@ -0,0 +1,509 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+)
+
+// item is a value flowing through an expression along with its JSONPath in
+// the document, which is empty for values built by the expression
+type item struct {
+	value any
+	path  string
+}
+
+// env holds what expressions can ask about the document
+type env struct {
+	// codecs maps the paths of decoded values to their codec chain
+	codecs map[string]string
+}
+
+// node is a parsed expression, producing any number of outputs per input.
+// On errors, the outputs produced before the error are returned along with
+// it, for ? to keep them
+type node interface {
+	eval(e *env, in item) ([]item, error)
+}
+
+type identityNode struct{}
+
+func (identityNode) eval(_ *env, in item) ([]item, error) {
+	return []item{in}, nil
+}
+
+// recurseNode outputs its input and every value nested in it, parents
+// before children
+type recurseNode struct{}
+
+func (recurseNode) eval(_ *env, in item) ([]item, error) {
+	var out []item
+	var walk func(it item)
+	walk = func(it item) {
+		out = append(out, it)
+		children, _ := iterate(it)
+		for _, child := range children {
+			walk(child)
+		}
+	}
+	walk(in)
+	return out, nil
+}
+
+type literalNode struct {
+	value any
+}
+
+func (n literalNode) eval(*env, item) ([]item, error) {
+	return []item{{value: n.value}}, nil
+}
+
+type pipeNode struct {
+	left, right node
+}
+
+func (n pipeNode) eval(e *env, in item) ([]item, error) {
+	left, err := n.left.eval(e, in)
+	var out []item
+	for _, it := range left {
+		right, err := n.right.eval(e, it)
+		out = append(out, right...)
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, err
+}
+
+type commaNode struct {
+	left, right node
+}
+
+func (n commaNode) eval(e *env, in item) ([]item, error) {
+	left, err := n.left.eval(e, in)
+	if err != nil {
+		return left, err
+	}
+	right, err := n.right.eval(e, in)
+	return append(left, right...), err
+}
+
+// indexNode selects a member of an object by name or an element of an
+// array by index, counting from the end when negative
+type indexNode struct {
+	target, key node
+}
+
+func (n indexNode) eval(e *env, in item) ([]item, error) {
+	keys, err := n.key.eval(e, in)
+	if err != nil {
+		return nil, err
+	}
+	targets, targetErr := n.target.eval(e, in)
+
+	var out []item
+	for _, target := range targets {
+		for _, key := range keys {
+			it, err := index(target, key.value)
+			if err != nil {
+				return out, err
+			}
+			out = append(out, it)
+		}
+	}
+	return out, targetErr
+}
+
+// index selects key of target
+func index(target item, key any) (item, error) {
+	switch v := target.value.(type) {
+	case nil:
+		return item{}, nil
+	case map[string]any:
+		if name, ok := key.(string); ok {
+			return item{value: v[name], path: member(target.path, name)}, nil
+		}
+	case []any:
+		if f, ok := key.(float64); ok && f == math.Trunc(f) {
+			i := int(f)
+			if i < 0 {
+				i += len(v)
+			}
+			if i < 0 || i >= len(v) {
+				return item{}, nil
+			}
+			return item{value: v[i], path: element(target.path, i)}, nil
+		}
+	}
+	return item{}, fmt.Errorf("cannot index %s with %s", typeName(target.value), describe(key))
+}
+
+// iterateNode outputs the elements of arrays and the member values of
+// objects, in order of their names
+type iterateNode struct {
+	target node
+}
+
+func (n iterateNode) eval(e *env, in item) ([]item, error) {
+	targets, targetErr := n.target.eval(e, in)
+	var out []item
+	for _, target := range targets {
+		children, err := iterate(target)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, children...)
+	}
+	return out, targetErr
+}
+
+// iterate returns the children of an array or object
+func iterate(it item) ([]item, error) {
+	switch v := it.value.(type) {
+	case map[string]any:
+		out := make([]item, 0, len(v))
+		for _, name := range sortedKeys(v) {
+			out = append(out, item{value: v[name], path: member(it.path, name)})
+		}
+		return out, nil
+	case []any:
+		out := make([]item, len(v))
+		for i, value := range v {
+			out[i] = item{value: value, path: element(it.path, i)}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("cannot iterate over %s", typeName(it.value))
+}
+
+// tryNode suppresses the errors of its expression, keeping the outputs
+// produced before them
+type tryNode struct {
+	body node
+}
+
+func (n tryNode) eval(e *env, in item) ([]item, error) {
+	out, _ := n.body.eval(e, in)
+	return out, nil
+}
+
+// arrayNode collects the outputs of its expression into an array
+type arrayNode struct {
+	body node
+}
+
+func (n arrayNode) eval(e *env, in item) ([]item, error) {
+	values := []any{}
+	if n.body != nil {
+		out, err := n.body.eval(e, in)
+		if err != nil {
+			return nil, err
+		}
+		for _, it := range out {
+			values = append(values, it.value)
+		}
+	}
+	return []item{{value: values}}, nil
+}
+
+// logicNode is "and" or "or" of the truthiness of both sides. The right
+// side is only evaluated when the left one does not decide the result
+type logicNode struct {
+	or          bool
+	left, right node
+}
+
+func (n logicNode) eval(e *env, in item) ([]item, error) {
+	left, err := n.left.eval(e, in)
+	if err != nil {
+		return nil, err
+	}
+	var out []item
+	for _, l := range left {
+		if truthy(l.value) == n.or {
+			out = append(out, item{value: n.or})
+			continue
+		}
+		right, err := n.right.eval(e, in)
+		if err != nil {
+			return out, err
+		}
+		for _, r := range right {
+			out = append(out, item{value: truthy(r.value)})
+		}
+	}
+	return out, nil
+}
+
+// compareNode compares every output of the left side with every output of
+// the right side
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n compareNode) eval(e *env, in item) ([]item, error) {
+	left, err := n.left.eval(e, in)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(e, in)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []item
+	for _, l := range left {
+		for _, r := range right {
+			c := compare(l.value, r.value)
+			var result bool
+			switch n.op {
+			case "==":
+				result = c == 0
+			case "!=":
+				result = c != 0
+			case "<":
+				result = c < 0
+			case "<=":
+				result = c <= 0
+			case ">":
+				result = c > 0
+			case ">=":
+				result = c >= 0
+			}
+			out = append(out, item{value: result})
+		}
+	}
+	return out, nil
+}
+
+// functions maps the names of the built-in functions to their number of
+// arguments
+var functions = map[string]int{
+	"select":   1,
+	"not":      0,
+	"empty":    0,
+	"length":   0,
+	"keys":     0,
+	"type":     0,
+	"decoded":  0,
+	"codec":    0,
+	"jsonpath": 0,
+}
+
+type callNode struct {
+	name string
+	arg  node
+}
+
+func (n callNode) eval(e *env, in item) ([]item, error) {
+	switch n.name {
+	case "select":
+		conditions, err := n.arg.eval(e, in)
+		if err != nil {
+			return nil, err
+		}
+		var out []item
+		for _, c := range conditions {
+			if truthy(c.value) {
+				out = append(out, in)
+			}
+		}
+		return out, nil
+
+	case "not":
+		return []item{{value: !truthy(in.value)}}, nil
+
+	case "empty":
+		return nil, nil
+
+	case "length":
+		switch v := in.value.(type) {
+		case nil:
+			return []item{{value: float64(0)}}, nil
+		case string:
+			return []item{{value: float64(utf8.RuneCountInString(v))}}, nil
+		case float64:
+			return []item{{value: math.Abs(v)}}, nil
+		case []any:
+			return []item{{value: float64(len(v))}}, nil
+		case map[string]any:
+			return []item{{value: float64(len(v))}}, nil
+		}
+		return nil, fmt.Errorf("%s has no length", typeName(in.value))
+
+	case "keys":
+		switch v := in.value.(type) {
+		case []any:
+			indexes := make([]any, len(v))
+			for i := range v {
+				indexes[i] = float64(i)
+			}
+			return []item{{value: indexes}}, nil
+		case map[string]any:
+			names := []any{}
+			for _, name := range sortedKeys(v) {
+				names = append(names, name)
+			}
+			return []item{{value: names}}, nil
+		}
+		return nil, fmt.Errorf("%s has no keys", typeName(in.value))
+
+	case "type":
+		return []item{{value: typeName(in.value)}}, nil
+
+	case "decoded":
+		_, ok := e.codecs[in.path]
+		return []item{{value: ok && in.path != ""}}, nil
+
+	case "codec":
+		if codec, ok := e.codecs[in.path]; ok && in.path != "" {
+			return []item{{value: codec}}, nil
+		}
+		return []item{{value: nil}}, nil
+
+	case "jsonpath":
+		if in.path == "" {
+			return []item{{value: nil}}, nil
+		}
+		return []item{{value: in.path}}, nil
+	}
+	return nil, fmt.Errorf("unknown function %s", n.name)
+}
+
+// member returns the path of member name of the value at parent, or no
+// path for values built by the expression
+func member(parent, name string) string {
+	if parent == "" {
+		return ""
+	}
+	return decoder.MemberPath(parent, name)
+}
+
+// element returns the path of element i of the array at parent, or no
+// path for values built by the expression
+func element(parent string, i int) string {
+	if parent == "" {
+		return ""
+	}
+	return decoder.IndexPath(parent, i)
+}
+
+// truthy reports whether a value counts as true: anything but false and
+// null
+func truthy(v any) bool {
+	return v != nil && v != false
+}
+
+// typeName returns the JSON type of a value
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// describe renders a value for error messages
+func describe(v any) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return typeName(v)
+}
+
+// sortedKeys returns the member names of an object in order
+func sortedKeys(object map[string]any) []string {
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// rank orders the JSON types: null, false, true, numbers, strings, arrays
+// and objects
+func rank(v any) int {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if v {
+			return 2
+		}
+		return 1
+	case float64:
+		return 3
+	case string:
+		return 4
+	case []any:
+		return 5
+	case map[string]any:
+		return 6
+	}
+	return 7
+}
+
+// compare orders two values like jq: by type, then numbers and strings by
+// value, arrays element by element, and objects by their sorted names
+// first and their member values second
+func compare(a, b any) int {
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return ra - rb
+	}
+
+	switch a := a.(type) {
+	case float64:
+		b := b.(float64)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	case string:
+		b := b.(string)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	case []any:
+		b := b.([]any)
+		for i := range min(len(a), len(b)) {
+			if c := compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+		return len(a) - len(b)
+	case map[string]any:
+		b := b.(map[string]any)
+		ka, kb := sortedKeys(a), sortedKeys(b)
+		if c := slices.Compare(ka, kb); c != 0 {
+			return c
+		}
+		for _, name := range ka {
+			if c := compare(a[name], b[name]); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
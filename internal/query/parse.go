@@ -0,0 +1,403 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// tokenKind is the kind of a token of an expression
+type tokenKind int
+
+const (
+	tokenEOF     tokenKind = iota
+	tokenDot               // .
+	tokenRecurse           // ..
+	tokenField             // .name
+	tokenIdent             // name, including the keywords and, or, true...
+	tokenString            // "text"
+	tokenNumber            // 1.5
+	tokenPunct             // [ ] ( ) | , ?
+	tokenCompare           // == != < <= > >=
+)
+
+// token is a lexed token and its offset in the expression
+type token struct {
+	kind   tokenKind
+	text   string
+	value  any
+	offset int
+}
+
+// SyntaxError reports an expression that cannot be parsed
+type SyntaxError struct {
+	Offset  int
+	Message string
+}
+
+// Error implements the error interface for SyntaxError
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid query at offset %d: %s", e.Offset, e.Message)
+}
+
+// lex splits an expression into tokens, ending with a tokenEOF
+func lex(expr string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+
+		case strings.HasPrefix(expr[i:], ".."):
+			tokens = append(tokens, token{kind: tokenRecurse, text: "..", offset: start})
+			i += 2
+
+		case c == '.' && i+1 < len(expr) && isIdentStart(expr[i+1]):
+			i++
+			for i < len(expr) && isIdentPart(expr[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenField, text: expr[start+1 : i], offset: start})
+
+		case c == '.':
+			tokens = append(tokens, token{kind: tokenDot, text: ".", offset: start})
+			i++
+
+		case isIdentStart(c):
+			for i < len(expr) && isIdentPart(expr[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: expr[start:i], offset: start})
+
+		case c == '"':
+			end := i + 1
+			for ; end < len(expr) && expr[end] != '"'; end++ {
+				if expr[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(expr) {
+				return nil, &SyntaxError{Offset: start, Message: "unterminated string"}
+			}
+			value, err := strconv.Unquote(expr[start : end+1])
+			if err != nil {
+				return nil, &SyntaxError{Offset: start, Message: "invalid string " + expr[start:end+1]}
+			}
+			tokens = append(tokens, token{kind: tokenString, text: expr[start : end+1], value: value, offset: start})
+			i = end + 1
+
+		case c >= '0' && c <= '9', c == '-' && i+1 < len(expr) && expr[i+1] >= '0' && expr[i+1] <= '9':
+			i++
+			for i < len(expr) && (expr[i] >= '0' && expr[i] <= '9' || expr[i] == '.' || expr[i] == 'e' || expr[i] == 'E') {
+				i++
+			}
+			value, err := strconv.ParseFloat(expr[start:i], 64)
+			if err != nil {
+				return nil, &SyntaxError{Offset: start, Message: "invalid number " + expr[start:i]}
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: expr[start:i], value: value, offset: start})
+
+		case strings.ContainsRune("[]()|,?", rune(c)):
+			tokens = append(tokens, token{kind: tokenPunct, text: string(c), offset: start})
+			i++
+
+		case c == '=' || c == '!' || c == '<' || c == '>':
+			op := string(c)
+			if i+1 < len(expr) && expr[i+1] == '=' {
+				op += "="
+			}
+			if op == "=" || op == "!" {
+				return nil, &SyntaxError{Offset: start, Message: fmt.Sprintf("unexpected %q", op)}
+			}
+			tokens = append(tokens, token{kind: tokenCompare, text: op, offset: start})
+			i += len(op)
+
+		default:
+			return nil, &SyntaxError{Offset: start, Message: fmt.Sprintf("unexpected %q", c)}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, offset: len(expr)}), nil
+}
+
+// isIdentStart reports whether c may start a name
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// isIdentPart reports whether c may continue a name
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9'
+}
+
+// parser builds the syntax tree of an expression by recursive descent.
+// From the loosest to the tightest binding, expressions are pipes,
+// comma-separated outputs, or, and, comparisons and paths
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// parse parses a whole expression
+func parse(expr string) (node, error) {
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	n, err := p.pipe()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.unexpected(t)
+	}
+	return n, nil
+}
+
+// peek returns the current token
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// next consumes and returns the current token
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the current token if it is the punctuation or keyword s
+func (p *parser) accept(s string) bool {
+	t := p.peek()
+	if (t.kind == tokenPunct || t.kind == tokenIdent) && t.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// expect consumes the punctuation s or fails
+func (p *parser) expect(s string) error {
+	if !p.accept(s) {
+		return p.unexpected(p.peek())
+	}
+	return nil
+}
+
+// unexpected reports an unexpected token
+func (p *parser) unexpected(t token) error {
+	if t.kind == tokenEOF {
+		return &SyntaxError{Offset: t.offset, Message: "unexpected end of query"}
+	}
+	return &SyntaxError{Offset: t.offset, Message: fmt.Sprintf("unexpected %q", t.text)}
+}
+
+// pipe parses a | b
+func (p *parser) pipe() (node, error) {
+	left, err := p.comma()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("|") {
+		right, err := p.comma()
+		if err != nil {
+			return nil, err
+		}
+		left = pipeNode{left, right}
+	}
+	return left, nil
+}
+
+// comma parses a, b
+func (p *parser) comma() (node, error) {
+	left, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(",") {
+		right, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		left = commaNode{left, right}
+	}
+	return left, nil
+}
+
+// or parses a or b
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = logicNode{or: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+// and parses a and b
+func (p *parser) and() (node, error) {
+	left, err := p.compare()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and") {
+		right, err := p.compare()
+		if err != nil {
+			return nil, err
+		}
+		left = logicNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+// compare parses a == b and the other comparisons, which do not chain
+func (p *parser) compare() (node, error) {
+	left, err := p.postfix()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind == tokenCompare {
+		p.next()
+		right, err := p.postfix()
+		if err != nil {
+			return nil, err
+		}
+		return compareNode{op: t.text, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+// postfix parses a term followed by member accesses, indexes, iterations
+// and ? operators
+func (p *parser) postfix() (node, error) {
+	n, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokenField:
+			p.next()
+			n = indexNode{target: n, key: literalNode{t.text}}
+		case t.kind == tokenDot && p.tokens[p.pos+1].kind == tokenString:
+			p.next()
+			n = indexNode{target: n, key: literalNode{p.next().value}}
+		case t.kind == tokenDot && p.tokens[p.pos+1].text == "[":
+			p.next()
+		case t.kind == tokenPunct && t.text == "[":
+			if n, err = p.bracket(n); err != nil {
+				return nil, err
+			}
+		case t.kind == tokenPunct && t.text == "?":
+			p.next()
+			n = tryNode{n}
+		default:
+			return n, nil
+		}
+	}
+}
+
+// bracket parses [] iterating over target or [key] indexing it
+func (p *parser) bracket(target node) (node, error) {
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	if p.accept("]") {
+		return iterateNode{target}, nil
+	}
+	key, err := p.pipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	return indexNode{target: target, key: key}, nil
+}
+
+// term parses the identity, recursion, a literal, a parenthesized
+// expression, an array construction or a function call
+func (p *parser) term() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenDot:
+		switch next := p.peek(); {
+		case next.kind == tokenString:
+			p.next()
+			return indexNode{target: identityNode{}, key: literalNode{next.value}}, nil
+		case next.kind == tokenPunct && next.text == "[":
+			return p.bracket(identityNode{})
+		}
+		return identityNode{}, nil
+
+	case tokenRecurse:
+		return recurseNode{}, nil
+
+	case tokenField:
+		return indexNode{target: identityNode{}, key: literalNode{t.text}}, nil
+
+	case tokenString, tokenNumber:
+		return literalNode{t.value}, nil
+
+	case tokenPunct:
+		switch t.text {
+		case "(":
+			n, err := p.pipe()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "[":
+			if p.accept("]") {
+				return arrayNode{}, nil
+			}
+			n, err := p.pipe()
+			if err != nil {
+				return nil, err
+			}
+			return arrayNode{n}, p.expect("]")
+		}
+
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return literalNode{true}, nil
+		case "false":
+			return literalNode{false}, nil
+		case "null":
+			return literalNode{nil}, nil
+		}
+
+		arity, ok := functions[t.text]
+		if !ok {
+			return nil, &SyntaxError{Offset: t.offset, Message: "unknown function " + t.text}
+		}
+		if arity == 0 {
+			return callNode{name: t.text}, nil
+		}
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		arg, err := p.pipe()
+		if err != nil {
+			return nil, err
+		}
+		return callNode{name: t.text, arg: arg}, p.expect(")")
+	}
+
+	return nil, p.unexpected(t)
+}
@@ -0,0 +1,55 @@
+// Package query evaluates a subset of the jq language over decoded
+// documents, with functions asking about decoding, e.g.
+//
+//	.records[].data.user.id
+//	.. | select(decoded) | jsonpath
+//	[.events[] | select(.payload | codec == "base64|json")] | length
+//
+// Supported are the identity ., member access .name, ."name" and .["name"],
+// indexes .[n] counting from the end when negative, iteration .[],
+// recursion .., the optional operator ?, pipes |, commas, array
+// construction [...], parentheses, literals, the comparisons == != < <= >
+// >=, and, or, and the functions select(f), not, empty, length, keys and
+// type. Decoding is described by decoded, true for values that were decoded
+// from an encoded string, codec, the codec chain that decoded them or null,
+// and jsonpath, the JSONPath of the value in the document or null for
+// values built by the query
+package query
+
+import "github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+
+// Query is a compiled expression
+type Query struct {
+	root node
+}
+
+// Compile parses an expression, failing with a *SyntaxError when it is not
+// valid
+func Compile(expr string) (*Query, error) {
+	root, err := parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &Query{root: root}, nil
+}
+
+// Run evaluates the query over the decoded document of result and returns
+// its outputs in order
+func (q *Query) Run(result decoder.Result) ([]any, error) {
+	e := &env{codecs: make(map[string]string)}
+	for _, annotation := range result.Annotations {
+		if annotation.Codec != "" {
+			e.codecs[annotation.Path] = annotation.Codec
+		}
+	}
+
+	out, err := q.root.eval(e, item{value: result.Value, path: "$"})
+	if err != nil {
+		return nil, err
+	}
+	values := make([]any, len(out))
+	for i, it := range out {
+		values[i] = it.value
+	}
+	return values, nil
+}
@@ -0,0 +1,122 @@
+package query_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+	"github.com/vitorhrmiranda/jbdecoder/internal/query"
+)
+
+func Test_Query(t *testing.T) {
+	b64 := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	input := `{
+		"records": [
+			{"id": 1, "data": "` + b64(`{"user": {"id": "u-1", "role": "admin"}}`) + `"},
+			{"id": 2, "data": "` + b64(`{"user": {"id": "u-2", "role": "viewer"}}`) + `"},
+			{"id": 3, "data": "plain text"}
+		],
+		"note": "` + b64("a note that was encoded") + `",
+		"a-b": [true, null]
+	}`
+
+	var data any
+	if err := json.Unmarshal([]byte(input), &data); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	d, err := decoder.New(decoder.Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result, err := d.Decode(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		expr     string
+		expected string
+	}{
+		{`.records[].data.user?.id`, `["u-1","u-2"]`},
+		{`[.records[].data.user.id?]`, `[["u-1","u-2"]]`},
+		{`.records[0].id, .records[-1].id`, `[1,3]`},
+		{`.["a-b"][0], ."a-b"[1]`, `[true,null]`},
+		{`.records[2].data.user?`, `[]`},
+		{`.missing.deeper`, `[null]`},
+		{`.. | select(decoded) | jsonpath`, `["$.note","$.records[0].data","$.records[1].data"]`},
+		{`.records[] | select(.data | codec == "base64|json") | .id`, `[1,2]`},
+		{`.note | codec, decoded, jsonpath`, `["base64",true,"$.note"]`},
+		{`[.records[] | .id] | length`, `[3]`},
+		{`[.records[] | .id][0] | decoded, jsonpath`, `[false,null]`},
+		{`.records[] | select(.id >= 2 and (.data | type) == "object") | .data.user.role`, `["viewer"]`},
+		{`.records[] | select(.id == 1 or .id == 3) | .id`, `[1,3]`},
+		{`.records[0].data.user | keys`, `[["id","role"]]`},
+		{`.records[0] | .data.user.role != "admin", (.id | not)`, `[false,false]`},
+		{`[] | length, ("héllo" | length)`, `[0,5]`},
+		{`.records[0].data.user[]`, `["u-1","admin"]`},
+		{`empty`, `[]`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			q, err := query.Compile(tc.expr)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			out, err := q.Run(result)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if out == nil {
+				out = []any{}
+			}
+			actual, err := json.Marshal(out)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if string(actual) != tc.expected {
+				t.Errorf("Expected %s, Got: %s", tc.expected, actual)
+			}
+		})
+	}
+
+	for _, expr := range []string{`.records[].data.user.id`, `.records[].id.x`, `.note[]`, `.records | not | length`} {
+		q, err := query.Compile(expr)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := q.Run(result); err == nil {
+			t.Errorf("Expected %s to fail", expr)
+		}
+	}
+}
+
+func Test_Compile_Invalid(t *testing.T) {
+	testCases := []struct {
+		expr   string
+		offset int
+	}{
+		{``, 0},
+		{`.a |`, 4},
+		{`.a[`, 3},
+		{`.a = 1`, 3},
+		{`select .a`, 7},
+		{`unknown`, 0},
+		{`"open`, 0},
+		{`.a ]`, 3},
+	}
+
+	for _, tc := range testCases {
+		_, err := query.Compile(tc.expr)
+		var syntaxErr *query.SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Expected a syntax error for %q, Got: %v", tc.expr, err)
+			continue
+		}
+		if syntaxErr.Offset != tc.offset {
+			t.Errorf("Expected %q to fail at offset %d, Got: %v", tc.expr, tc.offset, err)
+		}
+	}
+}
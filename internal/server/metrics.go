@@ -0,0 +1,210 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	errs "github.com/vitorhrmiranda/jbdecoder/internal/errors"
+)
+
+// metricsPrefix starts the name of every exported metric
+const metricsPrefix = "jbdecoder_"
+
+// durationBuckets are the upper bounds in seconds of the request latency
+// histogram buckets
+var durationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// routes are the paths reported as metric labels; any other path is
+// reported as otherRoute so that scanners cannot inflate the label space
+var routes = []string{"/decode", "/encode", "/health", "/metrics"}
+
+// otherRoute labels requests to unknown paths
+const otherRoute = "other"
+
+// methods are the request methods reported as metric labels; any other
+// method is reported as otherMethod, since clients may send any token
+var methods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// otherMethod labels requests with unknown methods
+const otherMethod = "other"
+
+// maxChainSteps is the number of codecs of the longest chain reported as a
+// metric label. Rules of requests can declare chains of any length, which
+// are reported as otherChain
+const maxChainSteps = 4
+
+// otherChain labels codec chains longer than maxChainSteps
+const otherChain = "other"
+
+// histogram counts observations into cumulative buckets
+type histogram struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+// observe adds a value to the histogram
+func (h *histogram) observe(value float64) {
+	for i, bound := range durationBuckets {
+		if value <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+// metrics collects request and decoding statistics and renders them in the
+// Prometheus text exposition format. Series are keyed by their label values
+// joined with labelSeparator
+type metrics struct {
+	mu        sync.Mutex
+	requests  map[string]uint64
+	durations map[string]*histogram
+	bytesIn   map[string]uint64
+	bytesOut  map[string]uint64
+	decoded   map[string]uint64
+	rejected  map[string]uint64
+}
+
+// labelSeparator joins label values into series keys
+const labelSeparator = "\x00"
+
+// newMetrics creates an empty metrics registry
+func newMetrics() *metrics {
+	return &metrics{
+		requests:  make(map[string]uint64),
+		durations: make(map[string]*histogram),
+		bytesIn:   make(map[string]uint64),
+		bytesOut:  make(map[string]uint64),
+		decoded:   make(map[string]uint64),
+		rejected:  make(map[string]uint64),
+	}
+}
+
+// route returns the label for a request path
+func route(path string) string {
+	if slices.Contains(routes, path) {
+		return path
+	}
+	return otherRoute
+}
+
+// methodLabel returns the label for a request method
+func methodLabel(method string) string {
+	if slices.Contains(methods, method) {
+		return method
+	}
+	return otherMethod
+}
+
+// chainLabel returns the label for a codec chain, which is one of the
+// chains of up to maxChainSteps codecs
+func chainLabel(codec string) string {
+	if strings.Count(codec, "|") >= maxChainSteps {
+		return otherChain
+	}
+	return codec
+}
+
+// request records a finished request
+func (m *metrics) request(method, path string, status int, duration time.Duration, in, out int64) {
+	method, path = methodLabel(method), route(path)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[method+labelSeparator+path+labelSeparator+strconv.Itoa(status)]++
+	m.bytesIn[path] += uint64(in)
+	m.bytesOut[path] += uint64(out)
+
+	h, ok := m.durations[path]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(durationBuckets))}
+		m.durations[path] = h
+	}
+	h.observe(duration.Seconds())
+}
+
+// Decoded implements decoder.Observer
+func (m *metrics) Decoded(codec string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.decoded[chainLabel(codec)]++
+}
+
+// Rejected implements decoder.Observer
+func (m *metrics) Rejected(codec string, reason errs.RejectReason) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rejected[chainLabel(codec)+labelSeparator+reason.Code()]++
+}
+
+// write renders all metrics in the Prometheus text format, with series in
+// a stable order
+func (m *metrics) write(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	writeCounter(&b, "http_requests_total", "HTTP requests by method, path and status.",
+		[]string{"method", "path", "status"}, m.requests)
+
+	writeHeader(&b, "http_request_duration_seconds", "histogram", "HTTP request latency by path.")
+	for _, path := range slices.Sorted(maps.Keys(m.durations)) {
+		h := m.durations[path]
+		label := `path="` + escapeLabel(path) + `"`
+		for i, bound := range durationBuckets {
+			fmt.Fprintf(&b, "%shttp_request_duration_seconds_bucket{%s,le=%q} %d\n",
+				metricsPrefix, label, strconv.FormatFloat(bound, 'g', -1, 64), h.buckets[i])
+		}
+		fmt.Fprintf(&b, "%shttp_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", metricsPrefix, label, h.count)
+		fmt.Fprintf(&b, "%shttp_request_duration_seconds_sum{%s} %s\n",
+			metricsPrefix, label, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "%shttp_request_duration_seconds_count{%s} %d\n", metricsPrefix, label, h.count)
+	}
+
+	writeCounter(&b, "http_request_bytes_total", "Request body bytes read by path.",
+		[]string{"path"}, m.bytesIn)
+	writeCounter(&b, "http_response_bytes_total", "Response body bytes written by path.",
+		[]string{"path"}, m.bytesOut)
+	writeCounter(&b, "decoded_fields_total", "Values decoded by codec chain.",
+		[]string{"codec"}, m.decoded)
+	writeCounter(&b, "rejected_fields_total", "Values a codec did not decode by reason.",
+		[]string{"codec", "reason"}, m.rejected)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeHeader writes the HELP and TYPE lines of a metric
+func writeHeader(b *strings.Builder, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s%s %s\n# TYPE %s%s %s\n", metricsPrefix, name, help, metricsPrefix, name, kind)
+}
+
+// writeCounter writes a counter with one series per key of values
+func writeCounter(b *strings.Builder, name, help string, labels []string, values map[string]uint64) {
+	writeHeader(b, name, "counter", help)
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		pairs := make([]string, len(labels))
+		for i, value := range strings.Split(key, labelSeparator) {
+			pairs[i] = labels[i] + `="` + escapeLabel(value) + `"`
+		}
+		fmt.Fprintf(b, "%s%s{%s} %d\n", metricsPrefix, name, strings.Join(pairs, ","), values[key])
+	}
+}
+
+// escapeLabel escapes a label value for the text format
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,105 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/server"
+)
+
+func Test_Server_Metrics(t *testing.T) {
+	var logs bytes.Buffer
+	srv := server.New(server.Options{Logger: slog.New(slog.NewJSONHandler(&logs, nil))})
+
+	send := func(method, target, contentType, body string) {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		request.Header.Set("Content-Type", contentType)
+		srv.ServeHTTP(httptest.NewRecorder(), request)
+	}
+	send(http.MethodPost, "/decode", "application/json",
+		`{"json": "eyJ1c2VyIjoiam9obiJ9", "broken": "SGVs!G8gV29ybGQgd29ybGQ="}`)
+	send(http.MethodPost, "/decode", "text/plain", "id=SGVsbG8gV29ybGQgd29ybGQ=")
+	send(http.MethodGet, "/wp-login.php", "", "")
+
+	recorder := httptest.NewRecorder()
+	srv.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type: %s", contentType)
+	}
+
+	exposition := recorder.Body.String()
+	for _, expected := range []string{
+		"# TYPE jbdecoder_http_requests_total counter",
+		`jbdecoder_http_requests_total{method="POST",path="/decode",status="200"} 2`,
+		`jbdecoder_http_requests_total{method="GET",path="other",status="404"} 1`,
+		"# TYPE jbdecoder_http_request_duration_seconds histogram",
+		`jbdecoder_http_request_duration_seconds_bucket{path="/decode",le="+Inf"} 2`,
+		`jbdecoder_http_request_duration_seconds_count{path="/decode"} 2`,
+		`jbdecoder_http_request_bytes_total{path="/decode"} 97`,
+		`jbdecoder_decoded_fields_total{codec="base64"} 1`,
+		`jbdecoder_decoded_fields_total{codec="base64|json"} 1`,
+		`jbdecoder_rejected_fields_total{codec="base64",reason="invalid_encoding"} 1`,
+		`jbdecoder_rejected_fields_total{codec="base64",reason="too_short"} 1`,
+	} {
+		if !strings.Contains(exposition, expected+"\n") {
+			t.Errorf("Expected %q in:\n%s", expected, exposition)
+		}
+	}
+
+	var entry struct {
+		Msg      string `json:"msg"`
+		Method   string `json:"method"`
+		Path     string `json:"path"`
+		Status   int    `json:"status"`
+		BytesIn  int64  `json:"bytes_in"`
+		BytesOut int64  `json:"bytes_out"`
+	}
+	line, _, _ := bytes.Cut(logs.Bytes(), []byte("\n"))
+	if err := json.Unmarshal(line, &entry); err != nil {
+		t.Fatalf("Invalid access log entry %s: %v", line, err)
+	}
+	if entry.Msg != "request" || entry.Method != "POST" || entry.Path != "/decode" ||
+		entry.Status != http.StatusOK || entry.BytesIn != 70 || entry.BytesOut == 0 {
+		t.Errorf("Unexpected access log entry: %s", line)
+	}
+}
+
+func Test_Server_Metrics_Labels(t *testing.T) {
+	srv := server.New(server.Options{})
+
+	deep := "hello"
+	for range 5 {
+		deep = base64.StdEncoding.EncodeToString([]byte(deep))
+	}
+	target := "/decode?rule=$.p=base64%7Cprotobuf&rule=$.q=base64%7Cprotobuf" +
+		"&rule=$.deep=" + url.QueryEscape("base64|base64|base64|base64|base64")
+	request := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"p": "CJYB", "q": "CJYB", "deep": "`+deep+`"}`))
+	request.Header.Set("Content-Type", "application/json")
+	srv.ServeHTTP(httptest.NewRecorder(), request)
+	srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("X-SCAN-1234", "/health", nil))
+
+	recorder := httptest.NewRecorder()
+	srv.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	exposition := recorder.Body.String()
+
+	// Labels are drawn from fixed sets whatever the requests declare
+	for _, expected := range []string{
+		`jbdecoder_decoded_fields_total{codec="base64|protobuf"} 2`,
+		`jbdecoder_decoded_fields_total{codec="other"} 1`,
+		`jbdecoder_http_requests_total{method="other",path="/health",status="405"} 1`,
+	} {
+		if !strings.Contains(exposition, expected+"\n") {
+			t.Errorf("Expected %q in:\n%s", expected, exposition)
+		}
+	}
+	if strings.Contains(exposition, "X-SCAN-1234") {
+		t.Errorf("Expected no X-SCAN-1234 label in:\n%s", exposition)
+	}
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/config"
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+)
+
+// headerPrefix starts the request headers that carry options, e.g.
+// "Jbdecoder-Max-Depth: 4" for the max-depth query parameter
+const headerPrefix = "Jbdecoder-"
+
+// requestOptions are the settings of a single request
+type requestOptions struct {
+	decoder  decoder.Options
+	annotate bool
+	markers  decoder.Markers
+}
+
+// param returns an option by its CLI flag name from the query string or,
+// failing that, from its request header
+func param(r *http.Request, name string) string {
+	if value := r.URL.Query().Get(name); value != "" {
+		return value
+	}
+	return r.Header.Get(headerPrefix + name)
+}
+
+// listParam returns a repeatable option, from repeated query parameters or,
+// failing that, from comma-separated request header values
+func listParam(r *http.Request, name string) []string {
+	if values := r.URL.Query()[name]; len(values) > 0 {
+		return values
+	}
+
+	var values []string
+	for _, value := range r.Header.Values(headerPrefix + name) {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
+
+// boolParam parses a boolean option, false when it is missing
+func boolParam(r *http.Request, name string) (bool, error) {
+	value := param(r, name)
+	if value == "" {
+		return false, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return parsed, nil
+}
+
+// limitParam parses a size or depth limit. A request may only tighten the
+// server's limit, so larger values and zero (no limit) keep it
+func limitParam(r *http.Request, name string, limit int64) (int64, error) {
+	value := param(r, name)
+	if value == "" {
+		return limit, nil
+	}
+
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	if parsed == 0 || limit > 0 && parsed > limit {
+		return limit, nil
+	}
+	return parsed, nil
+}
+
+// parseOptions reads the options of a request on top of the server's
+// decoder options. They are named like the CLI flags: charset, codecs,
+// min-length, max-depth, max-field, max-output, truncate, explain,
+// annotate, marker-open, marker-close and the repeatable include, exclude,
+// redact and rule. Patterns add to those of the server, so a request cannot
+// lift its exclusions or redactions, and rules of a request come first but
+// never apply at paths the server excludes
+func parseOptions(r *http.Request, defaults decoder.Options) (requestOptions, error) {
+	opts := requestOptions{decoder: defaults, markers: decoder.DefaultMarkers}
+
+	if charset := param(r, "charset"); charset != "" {
+		opts.decoder.Charset = charset
+	}
+	var codecs []string
+	for _, value := range listParam(r, "codecs") {
+		codecs = append(codecs, strings.Split(value, ",")...)
+	}
+	if len(codecs) > 0 {
+		opts.decoder.Codecs = codecs
+	}
+	if minLength := param(r, "min-length"); minLength != "" {
+		parsed, err := strconv.Atoi(minLength)
+		if err != nil || parsed < 0 {
+			return opts, fmt.Errorf("invalid min-length %q", minLength)
+		}
+		opts.decoder.MinLength = parsed
+	}
+
+	opts.decoder.Include = append(slices.Clip(defaults.Include), listParam(r, "include")...)
+	opts.decoder.Exclude = append(slices.Clip(defaults.Exclude), listParam(r, "exclude")...)
+	opts.decoder.Redact = append(slices.Clip(defaults.Redact), listParam(r, "redact")...)
+
+	var rules []decoder.Rule
+	for _, value := range listParam(r, "rule") {
+		path, codecs, err := config.SplitRule(value)
+		if err != nil {
+			return opts, fmt.Errorf("invalid rule %q: %w", value, err)
+		}
+		rules = append(rules, decoder.Rule{Path: path, Codecs: codecs})
+	}
+	opts.decoder.Rules = append(rules, defaults.Rules...)
+	opts.decoder.RuleExclude = append(slices.Clip(defaults.RuleExclude), defaults.Exclude...)
+
+	if open := param(r, "marker-open"); open != "" {
+		opts.markers.Open = open
+	}
+	if closing := param(r, "marker-close"); closing != "" {
+		opts.markers.Close = closing
+	}
+
+	depth, err := limitParam(r, "max-depth", int64(defaults.MaxDepth))
+	if err != nil {
+		return opts, err
+	}
+	opts.decoder.MaxDepth = int(depth)
+
+	if opts.decoder.MaxFieldSize, err = limitParam(r, "max-field", defaults.MaxFieldSize); err != nil {
+		return opts, err
+	}
+	if opts.decoder.MaxOutputSize, err = limitParam(r, "max-output", defaults.MaxOutputSize); err != nil {
+		return opts, err
+	}
+
+	truncate, err := boolParam(r, "truncate")
+	if err != nil {
+		return opts, err
+	}
+	opts.decoder.Truncate = opts.decoder.Truncate || truncate
+
+	explain, err := boolParam(r, "explain")
+	if err != nil {
+		return opts, err
+	}
+	opts.decoder.Explain = opts.decoder.Explain || explain
+
+	if opts.annotate, err = boolParam(r, "annotate"); err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
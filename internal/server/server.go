@@ -0,0 +1,405 @@
+// Package server exposes the decoder as a local HTTP API
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+	errs "github.com/vitorhrmiranda/jbdecoder/internal/errors"
+)
+
+// Defaults applied when the corresponding Options field is not set
+const (
+	DefaultMaxBodySize     = 10 << 20
+	DefaultTimeout         = 30 * time.Second
+	DefaultShutdownTimeout = 10 * time.Second
+)
+
+// Media types of request and response bodies
+const (
+	mediaTypeJSON   = "application/json"
+	mediaTypeNDJSON = "application/x-ndjson"
+	mediaTypeText   = "text/plain; charset=utf-8"
+
+	// mediaTypeMetrics is the Prometheus text exposition format
+	mediaTypeMetrics = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// readHeaderTimeout bounds how long a client may take to send headers
+const readHeaderTimeout = 5 * time.Second
+
+// Options configures a Server
+type Options struct {
+	// Decoder holds the default decoder options. Requests may tighten its
+	// limits but not loosen them
+	Decoder decoder.Options
+
+	// MaxBodySize is the maximum request body size in bytes. Zero means
+	// DefaultMaxBodySize
+	MaxBodySize int64
+
+	// Timeout bounds reading, handling and answering a request. Zero means
+	// DefaultTimeout
+	Timeout time.Duration
+
+	// ShutdownTimeout is how long in-flight requests may take to finish
+	// once the server is asked to stop. Zero means DefaultShutdownTimeout
+	ShutdownTimeout time.Duration
+
+	// Logger receives an access log entry for every request. When nil no
+	// access log is written
+	Logger *slog.Logger
+}
+
+// Server answers decode and encode requests, exporting metrics about them
+type Server struct {
+	opts    Options
+	handler http.Handler
+	metrics *metrics
+	logger  *slog.Logger
+}
+
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+// Read implements io.Reader
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// responseRecorder captures the status and size of a response
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	n      int64
+}
+
+// WriteHeader implements http.ResponseWriter
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// errorResponse is the body of failed requests
+type errorResponse struct {
+	Error string `json:"error"`
+	Limit string `json:"limit,omitempty"`
+	Path  string `json:"path,omitempty"`
+}
+
+// annotatedResponse is returned instead of the bare result with annotate
+type annotatedResponse struct {
+	Result      any                  `json:"result"`
+	Annotations []decoder.Annotation `json:"annotations"`
+	Diagnostics []decoder.Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// New creates a Server with the given options
+func New(opts Options) *Server {
+	if opts.Decoder.MaxDepth <= 0 {
+		opts.Decoder.MaxDepth = decoder.DefaultMaxDepth
+	}
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = DefaultMaxBodySize
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.ShutdownTimeout <= 0 {
+		opts.ShutdownTimeout = DefaultShutdownTimeout
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = slog.New(slog.DiscardHandler)
+	}
+
+	s := &Server{opts: opts, metrics: newMetrics(), logger: opts.Logger}
+	// Every request decodes with the server's metrics as observer
+	s.opts.Decoder.Observer = s.metrics
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /decode", s.decode)
+	mux.HandleFunc("POST /encode", s.encode)
+	mux.HandleFunc("GET /health", s.health)
+	mux.HandleFunc("GET /metrics", s.serveMetrics)
+
+	timeout, _ := json.Marshal(errorResponse{Error: "request timed out"})
+	s.handler = http.TimeoutHandler(mux, opts.Timeout, string(timeout))
+	return s
+}
+
+// ServeHTTP implements http.Handler, recording metrics and an access log
+// entry for every request
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	body := &countingReader{ReadCloser: http.MaxBytesReader(w, r.Body, s.opts.MaxBodySize)}
+	r.Body = body
+	recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+	s.handler.ServeHTTP(recorder, r)
+
+	duration := time.Since(start)
+	s.metrics.request(r.Method, r.URL.Path, recorder.status, duration, body.n, recorder.n)
+	s.logger.LogAttrs(r.Context(), slog.LevelInfo, "request",
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.String("query", r.URL.RawQuery),
+		slog.Int("status", recorder.status),
+		slog.Duration("duration", duration),
+		slog.Int64("bytes_in", body.n),
+		slog.Int64("bytes_out", recorder.n),
+		slog.String("remote_addr", r.RemoteAddr),
+		slog.String("user_agent", r.UserAgent()),
+	)
+}
+
+// ListenAndServe serves requests on addr until ctx is done, then stops
+// accepting connections and waits for in-flight requests to finish
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve is like ListenAndServe on an existing listener
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	srv := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       s.opts.Timeout,
+		WriteTimeout:      s.opts.Timeout + time.Second,
+		IdleTimeout:       s.opts.Timeout,
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Serve(listener)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), s.opts.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdown); err != nil {
+		return err
+	}
+	if err := <-done; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// decode answers POST /decode. JSON bodies are decoded as a document,
+// NDJSON bodies line by line and any other body as free-form text
+func (s *Server) decode(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseOptions(r, s.opts.Decoder)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	d, err := decoder.New(opts.decoder)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case mediaTypeJSON:
+		s.decodeJSON(w, r, d, opts)
+	case mediaTypeNDJSON:
+		s.decodeNDJSON(w, r, d, opts)
+	default:
+		s.decodeText(w, r, d, opts)
+	}
+}
+
+// decodeJSON decodes a JSON document
+func (s *Server) decodeJSON(w http.ResponseWriter, r *http.Request, d *decoder.Decoder, opts requestOptions) {
+	var data any
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&data); err != nil {
+		writeError(w, statusFor(err, http.StatusBadRequest), err)
+		return
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		if err == nil {
+			err = errors.New("invalid character after top-level value")
+		}
+		writeError(w, statusFor(err, http.StatusBadRequest), err)
+		return
+	}
+
+	response, err := decodeDocument(r.Context(), d, data, opts)
+	if err != nil {
+		writeError(w, statusFor(err, http.StatusUnprocessableEntity), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// decodeNDJSON decodes one JSON document per line. Lines that fail are
+// answered with an error object in their place
+func (s *Server) decodeNDJSON(w http.ResponseWriter, r *http.Request, d *decoder.Decoder, opts requestOptions) {
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(nil, int(s.opts.MaxBodySize))
+
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var data any
+		if err := json.Unmarshal(line, &data); err != nil {
+			_ = encoder.Encode(newErrorResponse(err))
+			continue
+		}
+
+		response, err := decodeDocument(r.Context(), d, data, opts)
+		if err := r.Context().Err(); err != nil {
+			// The client is gone or the request timed out
+			return
+		}
+		if err != nil {
+			_ = encoder.Encode(newErrorResponse(err))
+			continue
+		}
+		_ = encoder.Encode(response)
+	}
+	if err := scanner.Err(); err != nil {
+		writeError(w, statusFor(err, http.StatusBadRequest), err)
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaTypeNDJSON)
+	_, _ = w.Write(out.Bytes())
+}
+
+// decodeText decodes Base64 embedded in a free-form text body
+func (s *Server) decodeText(w http.ResponseWriter, r *http.Request, d *decoder.Decoder, opts requestOptions) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, statusFor(err, http.StatusBadRequest), err)
+		return
+	}
+
+	text, err := d.DecodeTextContext(r.Context(), string(body), opts.markers)
+	if err != nil {
+		writeError(w, statusFor(err, http.StatusUnprocessableEntity), err)
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaTypeText)
+	_, _ = io.WriteString(w, text)
+}
+
+// decodeDocument decodes a parsed document into the response value,
+// stopping once the request is canceled or times out
+func decodeDocument(ctx context.Context, d *decoder.Decoder, data any, opts requestOptions) (any, error) {
+	result, err := d.DecodeContext(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.annotate {
+		return annotatedResponse{
+			Result:      result.Value,
+			Annotations: result.Annotations,
+			Diagnostics: result.Diagnostics,
+		}, nil
+	}
+	return result.Value, nil
+}
+
+// encode answers POST /encode with the Base64 encoding of the body as a
+// JSON string
+func (s *Server) encode(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, statusFor(err, http.StatusBadRequest), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, base64.StdEncoding.EncodeToString(body))
+}
+
+// health answers GET /health
+func (s *Server) health(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// serveMetrics answers GET /metrics in the Prometheus text format
+func (s *Server) serveMetrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", mediaTypeMetrics)
+	_ = s.metrics.write(w)
+}
+
+// statusFor maps an error to an HTTP status, using fallback for errors
+// that are not about the size of the request
+func statusFor(err error, fallback int) int {
+	var tooLarge *http.MaxBytesError
+	var limit errs.LimitError
+	switch {
+	case errors.As(err, &tooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.As(err, &limit):
+		return http.StatusUnprocessableEntity
+	default:
+		return fallback
+	}
+}
+
+// newErrorResponse describes an error, including the exceeded limit and
+// its location for limit errors
+func newErrorResponse(err error) errorResponse {
+	response := errorResponse{Error: err.Error()}
+
+	var limit errs.LimitError
+	if errors.As(err, &limit) {
+		response.Limit, response.Path = limit.Limit(), limit.Path()
+	}
+	return response
+}
+
+// writeError answers with an error response
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, newErrorResponse(err))
+}
+
+// writeJSON answers with a JSON body
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", mediaTypeJSON)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
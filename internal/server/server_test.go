@@ -0,0 +1,339 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+	"github.com/vitorhrmiranda/jbdecoder/internal/server"
+)
+
+// nested holds Base64 JSON that holds Base64 JSON, two layers deep
+const nested = `{"data": "eyJuZXh0IjoiZXlKdVpYaDBJam9pYUdWc2JHOGdkMjl5YkdRc0lHNWxjM1JsWkNKOSJ9"}`
+
+func Test_Server(t *testing.T) {
+	srv := server.New(server.Options{
+		Decoder:     decoder.Options{MaxDepth: 1},
+		MaxBodySize: 256,
+	})
+
+	testCases := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		header      http.Header
+		body        string
+		status      int
+		expected    string
+	}{
+		{
+			name:        "decode JSON",
+			method:      http.MethodPost,
+			target:      "/decode",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"message": "SGVsbG8gV29ybGQgd29ybGQ=", "n": 1}`,
+			status:      http.StatusOK,
+			expected:    `{"message":"Hello World world","n":1}`,
+		},
+		{
+			name:        "decode NDJSON",
+			method:      http.MethodPost,
+			target:      "/decode",
+			contentType: "application/x-ndjson",
+			body:        "{\"a\": \"SGVsbG8gV29ybGQgd29ybGQ=\"}\n\n{bad\n[1]\n",
+			status:      http.StatusOK,
+			expected: `{"a":"Hello World world"}` + "\n" +
+				`{"error":"invalid character 'b' looking for beginning of object key string"}` + "\n[1]",
+		},
+		{
+			name:     "decode raw text",
+			method:   http.MethodPost,
+			target:   "/decode?marker-open=<&marker-close=>",
+			body:     "token=SGVsbG8gV29ybGQgd29ybGQ= ok",
+			status:   http.StatusOK,
+			expected: "token=<Hello World world> ok",
+		},
+		{
+			name:        "annotate via query",
+			method:      http.MethodPost,
+			target:      "/decode?annotate=true",
+			contentType: "application/json",
+			body:        `{"a": "SGVsbG8gV29ybGQgd29ybGQ="}`,
+			status:      http.StatusOK,
+			expected:    `{"result":{"a":"Hello World world"},"annotations":[{"path":"$.a","codec":"base64"}]}`,
+		},
+		{
+			name:        "limit via header",
+			method:      http.MethodPost,
+			target:      "/decode",
+			contentType: "application/json",
+			header:      http.Header{"Jbdecoder-Max-Field": {"4"}},
+			body:        nested,
+			status:      http.StatusUnprocessableEntity,
+			expected:    `{"error":"max field size of 4 exceeded at $.data","limit":"max field size","path":"$.data"}`,
+		},
+		{
+			name:        "requests cannot loosen limits",
+			method:      http.MethodPost,
+			target:      "/decode?max-depth=100&truncate=true",
+			contentType: "application/json",
+			body:        nested,
+			status:      http.StatusOK,
+			expected:    `{"data":{"next":"eyJuZXh0IjoiaGVsbG8gd29ybGQsIG5lc3RlZCJ9"}}`,
+		},
+		{
+			name:        "codecs via query",
+			method:      http.MethodPost,
+			target:      "/decode?codecs=datauri,json",
+			contentType: "application/json",
+			body:        `{"a": "SGVsbG8gV29ybGQgd29ybGQ="}`,
+			status:      http.StatusOK,
+			expected:    `{"a":"SGVsbG8gV29ybGQgd29ybGQ="}`,
+		},
+		{
+			name:        "min length via header",
+			method:      http.MethodPost,
+			target:      "/decode",
+			contentType: "application/json",
+			header:      http.Header{"Jbdecoder-Min-Length": {"32"}},
+			body:        `{"a": "SGVsbG8gV29ybGQgd29ybGQ="}`,
+			status:      http.StatusOK,
+			expected:    `{"a":"SGVsbG8gV29ybGQgd29ybGQ="}`,
+		},
+		{
+			name:        "include and exclude via query",
+			method:      http.MethodPost,
+			target:      "/decode?include=$.a&include=$.b&exclude=$.b",
+			contentType: "application/json",
+			body:        `{"a": "SGVsbG8gV29ybGQgd29ybGQ=", "b": "SGVsbG8gV29ybGQgd29ybGQ=", "c": "SGVsbG8gV29ybGQgd29ybGQ="}`,
+			status:      http.StatusOK,
+			expected:    `{"a":"Hello World world","b":"SGVsbG8gV29ybGQgd29ybGQ=","c":"SGVsbG8gV29ybGQgd29ybGQ="}`,
+		},
+		{
+			name:        "redact via header",
+			method:      http.MethodPost,
+			target:      "/decode",
+			contentType: "application/json",
+			header:      http.Header{"Jbdecoder-Redact": {"$.a, $.b"}},
+			body:        `{"a": "secret", "b": {"c": 1}, "d": "SGVsbG8gV29ybGQgd29ybGQ="}`,
+			status:      http.StatusOK,
+			expected:    `{"a":"[REDACTED]","b":"[REDACTED]","d":"Hello World world"}`,
+		},
+		{
+			name:        "rules via query",
+			method:      http.MethodPost,
+			target:      "/decode?rule=$.id=hex&rule=$.b=base64%7Cjson",
+			contentType: "application/json",
+			body:        `{"id": "6f726465722d31", "b": "eyJ1c2VyIjoiam9obiJ9"}`,
+			status:      http.StatusOK,
+			expected:    `{"b":{"user":"john"},"id":"order-1"}`,
+		},
+		{
+			name:        "invalid rule",
+			method:      http.MethodPost,
+			target:      "/decode?rule=$.id",
+			contentType: "application/json",
+			body:        `{}`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "invalid pattern",
+			method:      http.MethodPost,
+			target:      "/decode?exclude=a.b",
+			contentType: "application/json",
+			body:        `{}`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "invalid min length",
+			method:      http.MethodPost,
+			target:      "/decode?min-length=-1",
+			contentType: "application/json",
+			body:        `{}`,
+			status:      http.StatusBadRequest,
+			expected:    `{"error":"invalid min-length \"-1\""}`,
+		},
+		{
+			name:        "invalid option",
+			method:      http.MethodPost,
+			target:      "/decode?explain=maybe",
+			contentType: "application/json",
+			body:        `{}`,
+			status:      http.StatusBadRequest,
+			expected:    `{"error":"invalid explain \"maybe\""}`,
+		},
+		{
+			name:        "invalid JSON",
+			method:      http.MethodPost,
+			target:      "/decode",
+			contentType: "application/json",
+			body:        `{"a":`,
+			status:      http.StatusBadRequest,
+			expected:    `{"error":"unexpected EOF"}`,
+		},
+		{
+			name:        "trailing data",
+			method:      http.MethodPost,
+			target:      "/decode",
+			contentType: "application/json",
+			body:        `{"a": "SGVsbG8gV29ybGQgd29ybGQ="} {"b": 1}`,
+			status:      http.StatusBadRequest,
+			expected:    `{"error":"invalid character after top-level value"}`,
+		},
+		{
+			name:        "body too large",
+			method:      http.MethodPost,
+			target:      "/decode",
+			contentType: "application/json",
+			body:        `{"a": "` + strings.Repeat("A", 300) + `"}`,
+			status:      http.StatusRequestEntityTooLarge,
+			expected:    `{"error":"http: request body too large"}`,
+		},
+		{
+			name:     "encode",
+			method:   http.MethodPost,
+			target:   "/encode",
+			body:     `{"user":"john"}`,
+			status:   http.StatusOK,
+			expected: `"eyJ1c2VyIjoiam9obiJ9"`,
+		},
+		{
+			name:     "health",
+			method:   http.MethodGet,
+			target:   "/health",
+			status:   http.StatusOK,
+			expected: `{"status":"ok"}`,
+		},
+		{
+			name:   "wrong method",
+			method: http.MethodGet,
+			target: "/decode",
+			status: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			request := httptest.NewRequest(testCase.method, testCase.target, strings.NewReader(testCase.body))
+			for name, values := range testCase.header {
+				request.Header[name] = values
+			}
+			if testCase.contentType != "" {
+				request.Header.Set("Content-Type", testCase.contentType)
+			}
+
+			recorder := httptest.NewRecorder()
+			srv.ServeHTTP(recorder, request)
+
+			if recorder.Code != testCase.status {
+				t.Errorf("Expected status %d, Got: %d (%s)", testCase.status, recorder.Code, recorder.Body)
+			}
+			if testCase.expected != "" && strings.TrimSpace(recorder.Body.String()) != testCase.expected {
+				t.Errorf("Expected: %s, Got: %s", testCase.expected, recorder.Body)
+			}
+		})
+	}
+}
+
+func Test_Server_RequestRules(t *testing.T) {
+	srv := server.New(server.Options{Decoder: decoder.Options{
+		Exclude: []string{"$.internal"},
+		Redact:  []string{"$..password"},
+	}})
+
+	// Request rules decode other paths, but neither lift the server's
+	// exclusions nor reveal what it redacts
+	target := "/decode?rule=$.internal=base64&rule=$.nested.secret=base64&rule=$.auth=base64%7Cjson"
+	body := `{"internal": "SGVsbG8gV29ybGQ=", "nested": {"secret": "SGVsbG8gV29ybGQ="}, "auth": "eyJwYXNzd29yZCI6InNlY3JldCIsInVzZXIiOiJhbmEifQ=="}`
+	request := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	srv.ServeHTTP(recorder, request)
+
+	expected := `{"auth":{"password":"[REDACTED]","user":"ana"},"internal":"SGVsbG8gV29ybGQ=","nested":{"secret":"Hello World"}}`
+	if recorder.Code != http.StatusOK || strings.TrimSpace(recorder.Body.String()) != expected {
+		t.Errorf("Expected: %s, Got: %d %s", expected, recorder.Code, recorder.Body)
+	}
+}
+
+func Test_Server_Cancel(t *testing.T) {
+	values := make([]string, 5000)
+	for i := range values {
+		values[i] = `"SGVsbG8gV29ybGQgd29ybGQ="`
+	}
+	bodies := map[string]string{
+		"application/json":     "[" + strings.Join(values, ",") + "]",
+		"application/x-ndjson": "[" + strings.Join(values, ",") + "]",
+		"text/plain":           strings.Repeat("SGVsbG8gV29ybGQgd29ybGQ= ", len(values)),
+	}
+
+	for contentType, body := range bodies {
+		t.Run(contentType, func(t *testing.T) {
+			// Progress is reported every so many values, and the request is
+			// canceled on the first report, so decoding stops at the next one
+			ctx, cancel := context.WithCancel(t.Context())
+			defer cancel()
+			var reports atomic.Int32
+			srv := server.New(server.Options{Decoder: decoder.Options{
+				OnProgress: func(decoder.Progress) {
+					reports.Add(1)
+					cancel()
+				},
+			}})
+
+			request := httptest.NewRequestWithContext(ctx, http.MethodPost, "/decode", strings.NewReader(body))
+			request.Header.Set("Content-Type", contentType)
+			srv.ServeHTTP(httptest.NewRecorder(), request)
+
+			// The handler may outlive the canceled request
+			time.Sleep(100 * time.Millisecond)
+			if n := reports.Load(); n != 1 {
+				t.Errorf("Expected decoding to stop after the first progress report, Got: %d reports", n)
+			}
+		})
+	}
+}
+
+func Test_Server_GracefulShutdown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error)
+	go func() {
+		done <- server.New(server.Options{}).Serve(ctx, listener)
+	}()
+
+	response, err := http.Get("http://" + listener.Addr().String() + "/health")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	_ = response.Body.Close()
+
+	var health map[string]string
+	if err := json.Unmarshal(body, &health); err != nil || health["status"] != "ok" {
+		t.Errorf("Unexpected health response: %s", body)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown, Got: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the server to shut down")
+	}
+}
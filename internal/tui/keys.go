@@ -0,0 +1,89 @@
+package tui
+
+import "unicode/utf8"
+
+// key is a key press: a character, or one of the special keys below
+type key struct {
+	r       rune
+	special special
+}
+
+// special identifies keys that do not type a character
+type special int
+
+const (
+	keyNone special = iota
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyEnter
+	keyTab
+	keyBackspace
+	keyEscape
+	keyInterrupt
+)
+
+// sequences maps the escape sequences of terminals to special keys, in
+// both the normal and application cursor modes
+var sequences = map[string]special{
+	"[A": keyUp, "OA": keyUp,
+	"[B": keyDown, "OB": keyDown,
+	"[C": keyRight, "OC": keyRight,
+	"[D": keyLeft, "OD": keyLeft,
+	"[H": keyHome, "OH": keyHome, "[1~": keyHome, "[7~": keyHome,
+	"[F": keyEnd, "OF": keyEnd, "[4~": keyEnd, "[8~": keyEnd,
+	"[5~": keyPageUp,
+	"[6~": keyPageDown,
+}
+
+// parseKeys splits a chunk of terminal input into key presses. An escape
+// byte that does not start a known sequence is the Escape key
+func parseKeys(data []byte) []key {
+	var keys []key
+	for len(data) > 0 {
+		c := data[0]
+		switch {
+		case c == 0x1b:
+			k, size := parseEscape(data)
+			keys = append(keys, k)
+			data = data[size:]
+			continue
+		case c == '\r' || c == '\n':
+			keys = append(keys, key{special: keyEnter})
+		case c == '\t':
+			keys = append(keys, key{special: keyTab})
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, key{special: keyBackspace})
+		case c == 0x03:
+			keys = append(keys, key{special: keyInterrupt})
+		case c < ' ':
+			// Other control characters are ignored
+		default:
+			r, size := utf8.DecodeRune(data)
+			keys = append(keys, key{r: r})
+			data = data[size:]
+			continue
+		}
+		data = data[1:]
+	}
+	return keys
+}
+
+// parseEscape reads the key starting with the escape byte at data[0],
+// returning it and the number of bytes it spans
+func parseEscape(data []byte) (key, int) {
+	if len(data) > 1 && (data[1] == '[' || data[1] == 'O') {
+		// CSI and SS3 sequences end with a byte in the range @ to ~
+		for end := 2; end < len(data); end++ {
+			if data[end] >= '@' && data[end] <= '~' {
+				return key{special: sequences[string(data[1:end+1])]}, end + 1
+			}
+		}
+	}
+	return key{special: keyEscape}, 1
+}
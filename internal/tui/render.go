@@ -0,0 +1,158 @@
+package tui
+
+import (
+	"encoding/base64"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// draw writes a frame to out, along with the OSC 52 sequences setting the
+// clipboard to the paths copied since the last frame
+func (m *model) draw(out io.Writer, opts Options) error {
+	m.width, m.height = 80, 24
+	if opts.Size != nil {
+		if width, height := opts.Size(); width > 0 && height > 0 {
+			m.width, m.height = width, height
+		}
+	}
+	m.scroll()
+
+	var b strings.Builder
+	for _, path := range m.copied {
+		b.WriteString(osc52Prefix + base64.StdEncoding.EncodeToString([]byte(path)) + osc52Suffix)
+	}
+	m.copied = m.copied[:0]
+
+	b.WriteString(home)
+	for i := m.offset; i < m.offset+m.page(); i++ {
+		if i < len(m.rows) {
+			m.row(&b, m.rows[i], i == m.cursor, opts.Color)
+		}
+		b.WriteString(clearLine + "\r\n")
+	}
+	m.status(&b)
+	b.WriteString(clearBelow)
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// scroll moves the rows on screen so that the cursor is visible
+func (m *model) scroll() {
+	page := m.page()
+	m.offset = min(m.offset, m.cursor)
+	if m.cursor >= m.offset+page {
+		m.offset = m.cursor - page + 1
+	}
+	m.offset = max(min(m.offset, len(m.rows)-page), 0)
+}
+
+// row writes the line of n
+func (m *model) row(b *strings.Builder, n *node, selected, color bool) {
+	l := line{b: b, room: m.width, color: color}
+	if selected {
+		l.style(reverse)
+	}
+
+	l.add(strings.Repeat("  ", n.depth), "")
+	switch {
+	case !n.container():
+		l.add("  ", "")
+	case n.expanded:
+		l.add("▾ ", "")
+	default:
+		l.add("▸ ", "")
+	}
+
+	if n.parent != nil {
+		labelStyle := bold
+		if m.matches(n) {
+			labelStyle = yellow + bold
+		}
+		l.add(n.label, labelStyle)
+		l.add(": ", "")
+	}
+
+	valueStyle := ""
+	if n.decoded() && !n.showOriginal {
+		valueStyle = green
+	}
+	l.add(n.summary(), valueStyle)
+
+	switch {
+	case n.showOriginal:
+		l.add("  original, "+n.annotation.Codec, dim)
+	case n.decoded():
+		l.add("  "+n.annotation.Codec, magenta)
+	case n.annotation != nil && n.annotation.Limit != "":
+		l.add("  "+n.annotation.Limit+" exceeded", yellow)
+	}
+	if n.decodedBelow > 0 && !(n.expanded && n.container()) {
+		l.add("  "+plural(n.decodedBelow, "decoded value")+" inside", dim)
+	}
+
+	l.end()
+}
+
+// status writes the status bar: the query being typed, the last message,
+// or the path of the selected node
+func (m *model) status(b *strings.Builder) {
+	l := line{b: b, room: m.width, color: true}
+	l.style(reverse)
+
+	switch {
+	case m.searching:
+		l.add("/"+m.query, "")
+	case m.message != "":
+		l.add(m.message, "")
+	default:
+		l.add(m.selected().path+"  ", "")
+		l.add(help, "")
+	}
+
+	l.add(strings.Repeat(" ", max(l.room, 0)), "")
+	l.end()
+}
+
+// line writes one line of a frame, cut at the width of the terminal. Row
+// styles apply to the whole line while segment styles are only used with
+// colors
+type line struct {
+	b     *strings.Builder
+	room  int
+	color bool
+	base  string
+}
+
+// style sets the style of the whole line
+func (l *line) style(style string) {
+	l.base = style
+	l.b.WriteString(style)
+}
+
+// add writes a segment in the given style, cutting it to the room left
+func (l *line) add(text, style string) {
+	if l.room <= 0 {
+		return
+	}
+
+	if utf8.RuneCountInString(text) > l.room {
+		runes := []rune(text)
+		text = string(runes[:max(l.room-1, 0)]) + "…"
+	}
+	l.room -= utf8.RuneCountInString(text)
+
+	if style == "" || !l.color {
+		l.b.WriteString(text)
+		return
+	}
+	l.b.WriteString(style + text + reset + l.base)
+}
+
+// end resets the styles of the line
+func (l *line) end() {
+	if l.base != "" {
+		l.b.WriteString(reset)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package tui
+
+// notifyResize returns no notifications on systems without SIGWINCH such
+// as Windows; frames still follow the size of the console on the next key
+// press
+func notifyResize() (resize <-chan struct{}, stop func()) {
+	return nil, func() {}
+}
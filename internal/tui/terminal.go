@@ -0,0 +1,77 @@
+package tui
+
+import (
+	"errors"
+	"os"
+	"runtime"
+
+	"golang.org/x/term"
+)
+
+// Sequences switching to the alternate screen with a hidden cursor and back
+const (
+	enterScreen = "\x1b[?1049h\x1b[?25l"
+	leaveScreen = "\x1b[?25h\x1b[?1049l"
+)
+
+// ErrNoTerminal is returned by RunTerminal without a controlling terminal
+var ErrNoTerminal = errors.New("the interactive view needs a terminal")
+
+// RunTerminal shows doc on the controlling terminal until the user quits.
+// The terminal device is used rather than stdin and stdout, so that the
+// document can be piped in and the view still reads the keyboard
+func RunTerminal(doc Document) error {
+	in, out, err := openTerminal()
+	if err != nil {
+		return ErrNoTerminal
+	}
+	defer func() {
+		_ = in.Close()
+		if out != in {
+			_ = out.Close()
+		}
+	}()
+
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return ErrNoTerminal
+	}
+	defer func() { _ = term.Restore(int(in.Fd()), state) }()
+
+	if _, err := out.WriteString(enterScreen); err != nil {
+		return err
+	}
+	defer func() { _, _ = out.WriteString(leaveScreen) }()
+
+	resize, stop := notifyResize()
+	defer stop()
+
+	return Run(doc, in, out, Options{
+		Size: func() (int, int) {
+			width, height, err := term.GetSize(int(out.Fd()))
+			if err != nil {
+				return 0, 0
+			}
+			return width, height
+		},
+		Resize: resize,
+		Color:  os.Getenv("NO_COLOR") == "",
+	})
+}
+
+// openTerminal opens the controlling terminal for reading and writing
+func openTerminal() (in, out *os.File, err error) {
+	if runtime.GOOS == "windows" {
+		if in, err = os.OpenFile("CONIN$", os.O_RDWR, 0); err != nil {
+			return nil, nil, err
+		}
+		if out, err = os.OpenFile("CONOUT$", os.O_RDWR, 0); err != nil {
+			_ = in.Close()
+			return nil, nil, err
+		}
+		return in, out, nil
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	return tty, tty, err
+}
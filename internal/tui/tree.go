@@ -0,0 +1,185 @@
+package tui
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+)
+
+// node is a value of the document, shown as one row of the tree
+type node struct {
+	parent   *node
+	children []*node
+	depth    int
+
+	// label is the member name or index, empty for the root
+	label string
+	path  string
+
+	// value is the decoded value. For values found in the input, original
+	// is the value before decoding
+	value       any
+	original    any
+	hasOriginal bool
+
+	// annotation is set for values that were decoded or left undecoded
+	// because of a limit
+	annotation *decoder.Annotation
+
+	// decodedBelow counts the decoded values nested below the node
+	decodedBelow int
+
+	expanded     bool
+	showOriginal bool
+}
+
+// buildTree creates the tree of the decoded document, pairing every value
+// with the input value at the same path
+func buildTree(doc Document) *node {
+	annotations := make(map[string]*decoder.Annotation, len(doc.Result.Annotations))
+	for i := range doc.Result.Annotations {
+		annotations[doc.Result.Annotations[i].Path] = &doc.Result.Annotations[i]
+	}
+
+	root := newNode(nil, "", "$", doc.Result.Value, doc.Original, true, annotations)
+	root.expanded = true
+	return root
+}
+
+// newNode creates the node of value and its descendants. original is the
+// input value at the same path, if hasOriginal
+func newNode(parent *node, label, path string, value, original any, hasOriginal bool, annotations map[string]*decoder.Annotation) *node {
+	n := &node{
+		parent:      parent,
+		label:       label,
+		path:        path,
+		value:       value,
+		original:    original,
+		hasOriginal: hasOriginal,
+		annotation:  annotations[path],
+	}
+	if parent != nil {
+		n.depth = parent.depth + 1
+	}
+
+	// Below a decoded value the input holds an encoded string
+	if n.decoded() {
+		hasOriginal = false
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		originals, _ := original.(map[string]any)
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			childOriginal, ok := originals[key]
+			n.add(newNode(n, key, decoder.MemberPath(path, key), v[key], childOriginal, hasOriginal && ok, annotations))
+		}
+
+	case []any:
+		originals, _ := original.([]any)
+		for i, element := range v {
+			var childOriginal any
+			ok := i < len(originals)
+			if ok {
+				childOriginal = originals[i]
+			}
+			n.add(newNode(n, strconv.Itoa(i), decoder.IndexPath(path, i), element, childOriginal, hasOriginal && ok, annotations))
+		}
+	}
+
+	return n
+}
+
+// add appends a child, counting its decoded values
+func (n *node) add(child *node) {
+	n.children = append(n.children, child)
+	n.decodedBelow += child.decodedBelow
+	if child.decoded() {
+		n.decodedBelow++
+	}
+}
+
+// decoded reports whether a codec decoded the value
+func (n *node) decoded() bool {
+	return n.annotation != nil && n.annotation.Codec != ""
+}
+
+// container reports whether the node can be expanded. Nodes showing their
+// original value are leaves
+func (n *node) container() bool {
+	return len(n.children) > 0 && !n.showOriginal
+}
+
+// shown is the value displayed for the node
+func (n *node) shown() any {
+	if n.showOriginal {
+		return n.original
+	}
+	return n.value
+}
+
+// summary renders the value of the node on one line
+func (n *node) summary() string {
+	switch v := n.shown().(type) {
+	case map[string]any:
+		return "{" + plural(len(v), "key") + "}"
+	case []any:
+		return "[" + plural(len(v), "item") + "]"
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return "?"
+		}
+		return string(encoded)
+	}
+}
+
+// plural formats a count of things
+func plural(count int, thing string) string {
+	if count == 1 {
+		return "1 " + thing
+	}
+	return strconv.Itoa(count) + " " + thing + "s"
+}
+
+// walk calls fn for n and its descendants in document order until fn
+// returns false
+func (n *node) walk(fn func(*node) bool) bool {
+	if !fn(n) {
+		return false
+	}
+	for _, child := range n.children {
+		if !child.walk(fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// visible appends the rows shown for n: the node and, when expanded, the
+// visible rows of its children
+func (n *node) visible(rows []*node) []*node {
+	rows = append(rows, n)
+	if n.expanded && n.container() {
+		for _, child := range n.children {
+			rows = child.visible(rows)
+		}
+	}
+	return rows
+}
+
+// reveal expands the ancestors of n so that it is visible
+func (n *node) reveal() {
+	for p := n.parent; p != nil; p = p.parent {
+		p.expanded = true
+		p.showOriginal = false
+	}
+}
@@ -0,0 +1,337 @@
+// Package tui implements the interactive terminal view of decoded
+// documents: a collapsible tree highlighting decoded values, which can be
+// toggled back to their original form, searched, and whose JSONPath can be
+// copied to the clipboard
+package tui
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+)
+
+// Document is what the view shows: the input document as parsed and the
+// result of decoding a copy of it
+type Document struct {
+	Original any
+	Result   decoder.Result
+}
+
+// Options configures Run
+type Options struct {
+	// Size returns the width and height of the terminal. It is called
+	// before every frame so that frames follow resizes
+	Size func() (width, height int)
+
+	// Resize delivers a value whenever the terminal was resized, to redraw
+	// without waiting for a key press. It may be nil
+	Resize <-chan struct{}
+
+	// Color enables colors; without it only the cursor row is highlighted
+	Color bool
+}
+
+// Terminal control sequences
+const (
+	home        = "\x1b[H"
+	clearLine   = "\x1b[K"
+	clearBelow  = "\x1b[J"
+	reset       = "\x1b[0m"
+	reverse     = "\x1b[7m"
+	bold        = "\x1b[1m"
+	dim         = "\x1b[2m"
+	green       = "\x1b[32m"
+	yellow      = "\x1b[33m"
+	magenta     = "\x1b[35m"
+	osc52Prefix = "\x1b]52;c;"
+	osc52Suffix = "\a"
+)
+
+// help is shown in the status bar when there is nothing else to say
+const help = "↑↓ move  ←→ fold  o original  / search  n next  y copy path  q quit"
+
+// model is the state of the view
+type model struct {
+	root *node
+	rows []*node
+
+	// cursor is the selected row and offset the first row on screen
+	cursor int
+	offset int
+
+	// width and height are the size of the last frame
+	width  int
+	height int
+
+	// searching is set while typing the query
+	searching bool
+	query     string
+
+	message string
+	quit    bool
+
+	// copied are the paths copied by the user, sent to the terminal with
+	// the next frame
+	copied []string
+}
+
+// newModel creates the view of doc with the root expanded
+func newModel(doc Document) *model {
+	m := &model{root: buildTree(doc)}
+	m.refresh()
+	return m
+}
+
+// Run shows doc, reading key presses from in and drawing frames to out
+// until the user quits or in ends. in is expected to be a terminal in raw
+// mode, and out to have been switched to the alternate screen
+func Run(doc Document, in io.Reader, out io.Writer, opts Options) error {
+	m := newModel(doc)
+
+	chunks := make(chan []byte)
+	failed := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			buf := make([]byte, 256)
+			n, err := in.Read(buf)
+			if n > 0 {
+				select {
+				case chunks <- buf[:n]:
+				case <-done:
+					return
+				}
+			}
+			if err != nil {
+				failed <- err
+				return
+			}
+		}
+	}()
+
+	for {
+		if err := m.draw(out, opts); err != nil {
+			return err
+		}
+		if m.quit {
+			return nil
+		}
+
+		select {
+		case chunk := <-chunks:
+			for _, k := range parseKeys(chunk) {
+				if m.update(k); m.quit {
+					break
+				}
+			}
+		case <-opts.Resize:
+		case err := <-failed:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// refresh recomputes the visible rows after nodes were expanded,
+// collapsed or toggled, keeping the cursor on the same node if possible
+func (m *model) refresh() {
+	var current *node
+	if m.cursor < len(m.rows) {
+		current = m.rows[m.cursor]
+	}
+
+	m.rows = m.root.visible(m.rows[:0])
+	m.cursor = min(m.cursor, len(m.rows)-1)
+	for i, row := range m.rows {
+		if row == current {
+			m.cursor = i
+			break
+		}
+	}
+}
+
+// selected is the node under the cursor
+func (m *model) selected() *node {
+	return m.rows[m.cursor]
+}
+
+// moveTo places the cursor on n, expanding its ancestors
+func (m *model) moveTo(n *node) {
+	n.reveal()
+	m.rows = m.root.visible(m.rows[:0])
+	for i, row := range m.rows {
+		if row == n {
+			m.cursor = i
+			return
+		}
+	}
+}
+
+// update applies a key press
+func (m *model) update(k key) {
+	if m.searching {
+		m.updateSearch(k)
+		return
+	}
+
+	m.message = ""
+	n := m.selected()
+
+	switch {
+	case k.special == keyInterrupt, k.r == 'q':
+		m.quit = true
+
+	case k.special == keyUp, k.r == 'k':
+		m.cursor = max(m.cursor-1, 0)
+	case k.special == keyDown, k.r == 'j':
+		m.cursor = min(m.cursor+1, len(m.rows)-1)
+	case k.special == keyPageUp:
+		m.cursor = max(m.cursor-m.page(), 0)
+	case k.special == keyPageDown, k.r == ' ':
+		m.cursor = min(m.cursor+m.page(), len(m.rows)-1)
+	case k.special == keyHome, k.r == 'g':
+		m.cursor = 0
+	case k.special == keyEnd, k.r == 'G':
+		m.cursor = len(m.rows) - 1
+
+	case k.special == keyLeft, k.r == 'h':
+		if n.container() && n.expanded {
+			n.expanded = false
+			m.refresh()
+		} else if n.parent != nil {
+			m.moveTo(n.parent)
+		}
+	case k.special == keyRight, k.r == 'l':
+		if n.container() {
+			if n.expanded {
+				m.cursor++
+			} else {
+				n.expanded = true
+				m.refresh()
+			}
+		}
+	case k.special == keyEnter:
+		if n.container() {
+			n.expanded = !n.expanded
+			m.refresh()
+		}
+	case k.r == 'E':
+		m.root.walk(func(n *node) bool { n.expanded = true; return true })
+		m.refresh()
+	case k.r == 'C':
+		m.root.walk(func(n *node) bool { n.expanded = n == m.root; return true })
+		m.refresh()
+
+	case k.special == keyTab, k.r == 'o':
+		m.toggleOriginal(n)
+
+	case k.r == '/':
+		m.searching, m.query = true, ""
+	case k.r == 'n':
+		m.search(true)
+	case k.r == 'N':
+		m.search(false)
+	case k.special == keyEscape:
+		m.query = ""
+
+	case k.r == 'y':
+		m.copied = append(m.copied, n.path)
+		m.message = "copied " + n.path
+	}
+}
+
+// updateSearch applies a key press while typing the query
+func (m *model) updateSearch(k key) {
+	switch {
+	case k.special == keyEnter:
+		m.searching = false
+		m.search(true)
+	case k.special == keyEscape, k.special == keyInterrupt:
+		m.searching, m.query = false, ""
+	case k.special == keyBackspace:
+		if _, size := utf8.DecodeLastRuneInString(m.query); size > 0 {
+			m.query = m.query[:len(m.query)-size]
+		}
+	case k.r != 0:
+		m.query += string(k.r)
+	}
+}
+
+// toggleOriginal switches a decoded node between its decoded and original
+// value
+func (m *model) toggleOriginal(n *node) {
+	switch {
+	case !n.decoded():
+		m.message = n.path + " was not decoded"
+	case !n.hasOriginal:
+		m.message = "the original of " + n.path + " is inside decoded content"
+	default:
+		n.showOriginal = !n.showOriginal
+		m.refresh()
+	}
+}
+
+// search moves the cursor to the next node matching the query, forward or
+// backward in document order and wrapping around. Collapsed nodes are
+// searched too and expanded when matched
+func (m *model) search(forward bool) {
+	if m.query == "" {
+		return
+	}
+
+	var all []*node
+	m.root.walk(func(n *node) bool { all = append(all, n); return true })
+
+	current := 0
+	for i, n := range all {
+		if n == m.selected() {
+			current = i
+			break
+		}
+	}
+
+	step := 1
+	if !forward {
+		step = len(all) - 1
+	}
+	for i := (current + step) % len(all); ; i = (i + step) % len(all) {
+		if m.matches(all[i]) {
+			m.moveTo(all[i])
+			return
+		}
+		if i == current {
+			break
+		}
+	}
+	m.message = "no match for " + m.query
+}
+
+// matches reports whether the label or scalar value of n contains the
+// query, ignoring case
+func (m *model) matches(n *node) bool {
+	if m.query == "" {
+		return false
+	}
+	query := strings.ToLower(m.query)
+	if strings.Contains(strings.ToLower(n.label), query) {
+		return true
+	}
+	if s, ok := n.original.(string); ok && n.decoded() && n.hasOriginal && strings.Contains(strings.ToLower(s), query) {
+		return true
+	}
+	if n.container() {
+		return false
+	}
+	return strings.Contains(strings.ToLower(n.summary()), query)
+}
+
+// page is the number of tree rows per screen
+func (m *model) page() int {
+	return max(m.height-1, 1)
+}
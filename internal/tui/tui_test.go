@@ -0,0 +1,258 @@
+package tui_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/vitorhrmiranda/jbdecoder/internal/decoder"
+	"github.com/vitorhrmiranda/jbdecoder/internal/tui"
+)
+
+// Keys sent by terminals
+const (
+	up    = "\x1b[A"
+	down  = "\x1b[B"
+	right = "\x1b[C"
+	left  = "\x1b[D"
+	enter = "\r"
+	esc   = "\x1b"
+)
+
+var (
+	csi   = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+	osc52 = regexp.MustCompile(`\x1b\]52;c;([A-Za-z0-9+/=]*)\a`)
+)
+
+// document decodes a test document into a copy
+func document(t *testing.T, input string) tui.Document {
+	t.Helper()
+
+	var original any
+	if err := json.Unmarshal([]byte(input), &original); err != nil {
+		t.Fatal(err)
+	}
+	d, _ := decoder.New(decoder.Options{})
+	result, err := d.Decode(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tui.Document{Original: original, Result: result}
+}
+
+// run types keys into the view and returns the lines of the last frame
+// without styles, along with the paths copied to the clipboard
+func run(t *testing.T, doc tui.Document, keys string, width, height int) ([]string, []string) {
+	t.Helper()
+
+	var out bytes.Buffer
+	err := tui.Run(doc, strings.NewReader(keys), &out, tui.Options{
+		Size:  func() (int, int) { return width, height },
+		Color: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var copied []string
+	for _, match := range osc52.FindAllStringSubmatch(out.String(), -1) {
+		path, _ := base64.StdEncoding.DecodeString(match[1])
+		copied = append(copied, string(path))
+	}
+
+	frames := strings.Split(osc52.ReplaceAllString(out.String(), ""), "\x1b[H")
+	frame := csi.ReplaceAllString(frames[len(frames)-1], "")
+	return strings.Split(frame, "\r\n"), copied
+}
+
+func b64(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func Test_Run(t *testing.T) {
+	doc := document(t, `{
+		"user": {"name": "John", "token": "`+b64(`{"id": 7, "roles": ["admin"], "inner": "`+b64("nested secret")+`"}`)+`"},
+		"note": "plain",
+		"list": ["`+b64("hello world")+`"]
+	}`)
+
+	testCases := []struct {
+		name   string
+		keys   string
+		want   []string
+		absent []string
+		copied []string
+	}{
+		{
+			name: "initial view",
+			want: []string{
+				"▾ {3 keys}",
+				"  ▸ list: [1 item]  1 decoded value inside",
+				"    note: \"plain\"",
+				"  ▸ user: {2 keys}  2 decoded values inside",
+				"$  ↑↓ move",
+			},
+		},
+		{
+			name: "expand with arrows",
+			keys: down + down + down + right + down + down + right,
+			want: []string{
+				"  ▾ user: {2 keys}",
+				"      name: \"John\"",
+				"    ▾ token: {3 keys}  base64|json",
+				"        inner: \"nested secret\"  base64",
+				"        id: 7",
+				"$.user.token",
+			},
+		},
+		{
+			name:   "collapse with left",
+			keys:   "jjjl" + left,
+			want:   []string{"  ▸ user: {2 keys}"},
+			absent: []string{"name:"},
+		},
+		{
+			name: "left moves to the parent",
+			keys: "jjjljj" + left,
+			want: []string{"$.user  "},
+		},
+		{
+			name: "toggle original",
+			keys: "jjjljjo",
+			want: []string{
+				"    token: \"" + b64(`{"id": 7, "roles": ["admin"], "inner": "` + b64("nested secret") + `"}`)[:20],
+			},
+			absent: []string{"roles", "▾ token"},
+		},
+		{
+			name: "toggle back to decoded",
+			keys: "jjjljjo\t" + right,
+			want: []string{"    ▾ token: {3 keys}  base64|json"},
+		},
+		{
+			name: "original inside decoded content",
+			keys: "/inner" + enter + "o",
+			want: []string{"the original of $.user.token.inner is inside decoded content"},
+		},
+		{
+			name: "original tag",
+			keys: "/list" + enter + right + down + "o",
+			want: []string{"      0: \"" + b64("hello world") + "\"  original, base64"},
+		},
+		{
+			name: "undecoded value",
+			keys: "jjo",
+			want: []string{"$.note was not decoded"},
+		},
+		{
+			name: "search expands collapsed nodes",
+			keys: "/ADMIN" + enter,
+			want: []string{
+				"      ▾ roles: [1 item]",
+				"          0: \"admin\"",
+				"$.user.token.roles[0]",
+			},
+		},
+		{
+			name: "search the original value",
+			keys: "/" + b64("hello")[:6] + enter,
+			want: []string{"$.list[0]"},
+		},
+		{
+			name: "next and previous match",
+			keys: "/o" + enter + "nnN",
+			want: []string{"$.note  "},
+		},
+		{
+			name: "no match",
+			keys: "/missing" + enter,
+			want: []string{"no match for missing"},
+		},
+		{
+			name: "cancel search",
+			keys: "/rol" + esc,
+			want: []string{"$  ↑↓ move"},
+		},
+		{
+			name: "backspace in search",
+			keys: "/namx\x7fe" + enter,
+			want: []string{"$.user.name"},
+		},
+		{
+			name:   "copy path",
+			keys:   "/roles" + enter + "y",
+			want:   []string{"copied $.user.token.roles"},
+			copied: []string{"$.user.token.roles"},
+		},
+		{
+			name: "expand and collapse all",
+			keys: "E",
+			want: []string{"          0: \"admin\"", "      0: \"hello world\"  base64"},
+		},
+		{
+			name:   "collapse all",
+			keys:   "EC",
+			absent: []string{"name:", "0:"},
+		},
+		{
+			name: "move to the end and back",
+			keys: "G" + up,
+			want: []string{"$.note  "},
+		},
+		{
+			name:   "quit",
+			keys:   "q" + down,
+			want:   []string{"$  ↑↓ move"},
+			absent: []string{"$.list"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lines, copied := run(t, doc, tc.keys, 80, 24)
+			frame := strings.Join(lines, "\n")
+
+			for _, want := range tc.want {
+				if !strings.Contains(frame, want) {
+					t.Errorf("expected %q in frame:\n%s", want, frame)
+				}
+			}
+			for _, absent := range tc.absent {
+				if strings.Contains(frame, absent) {
+					t.Errorf("unexpected %q in frame:\n%s", absent, frame)
+				}
+			}
+			if strings.Join(copied, ",") != strings.Join(tc.copied, ",") {
+				t.Errorf("expected copied paths %q, got %q", tc.copied, copied)
+			}
+		})
+	}
+}
+
+func Test_Run_Size(t *testing.T) {
+	values := make([]string, 50)
+	for i := range values {
+		values[i] = `"` + b64(strings.Repeat("a long decoded value ", 5)) + `"`
+	}
+	doc := document(t, `{"values": [`+strings.Join(values, ",")+`]}`)
+
+	lines, _ := run(t, doc, "jl"+strings.Repeat("j", 30), 40, 10)
+	if len(lines) != 10 {
+		t.Fatalf("expected 10 lines, got %d:\n%s", len(lines), strings.Join(lines, "\n"))
+	}
+	for _, line := range lines {
+		if utf8.RuneCountInString(line) > 40 {
+			t.Errorf("line wider than the terminal: %q", line)
+		}
+	}
+	if !strings.Contains(lines[8], "29:") {
+		t.Errorf("expected the cursor row at the bottom, got:\n%s", strings.Join(lines, "\n"))
+	}
+	if !strings.HasPrefix(lines[9], "$.values[29]") {
+		t.Errorf("expected the path of the cursor in the status bar, got %q", lines[9])
+	}
+}